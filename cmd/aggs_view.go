@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"shelastic/es"
+	"strconv"
+	"strings"
+
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+// maxBarWidth is a width of the longest bar of histogram chart
+const maxBarWidth = 40
+
+// histogramTypes are aggregations which buckets are shown as bar charts
+var histogramTypes = map[string]bool{
+	"histogram":                true,
+	"date_histogram":           true,
+	"auto_date_histogram":      true,
+	"variable_width_histogram": true,
+}
+
+// aggregationRow is a row of bucket aggregation table. Key is indented by depth of the bucket, metrics map
+// columns of metric sub-aggregations to their values. Rows of nested buckets are not top, neither are labels
+type aggregationRow struct {
+	key      string
+	docCount string
+	metrics  map[string]string
+	count    int64
+	top      bool
+}
+
+// printAggregations prints results of aggregations. Metric aggregations are printed with their values,
+// bucket aggregations as tables with a row per bucket and histograms as bar charts
+func printAggregations(c *ishell.Context, aggs []*es.Aggregation, indent string) {
+	for _, agg := range aggs {
+		title := indent + hbl("%s", agg.Name)
+		if agg.Type != "" {
+			title += bl(" (%s)", agg.Type)
+		}
+		switch {
+		case agg.Buckets != nil:
+			printMessage(c, title+"\n")
+			printBuckets(c, agg, indent+"  ")
+		case len(agg.Values) == 1 && agg.Values[0].Name == "value":
+			printMessage(c, title+": "+metricString(agg.Values[0].Value)+"\n")
+		case agg.Values != nil:
+			printMessage(c, title+"\n")
+			for _, v := range agg.Values {
+				printMessage(c, bl("%s  %s: ", indent, v.Name)+metricString(v.Value)+"\n")
+			}
+		case agg.DocCount != nil:
+			printMessage(c, title+fmt.Sprintf(": %d documents\n", *agg.DocCount))
+			printAggregations(c, agg.Aggregations, indent+"  ")
+		default:
+			printMessage(c, title+"\n")
+			data, err := json.MarshalIndent(agg.Raw, indent+"  ", "  ")
+			if err != nil {
+				printError(c, err)
+				continue
+			}
+			printMessage(c, indent+"  "+string(data)+"\n")
+		}
+	}
+}
+
+// printBuckets prints buckets as a table of keys, document counts and values of metric sub-aggregations.
+// Buckets of nested bucket aggregations follow their parent bucket with indented keys. Histograms have
+// a bar of document count in the last column
+func printBuckets(c *ishell.Context, agg *es.Aggregation, indent string) {
+	if len(agg.Buckets) == 0 {
+		cprintln(c, "%sNo buckets", indent)
+		return
+	}
+	var rows []aggregationRow
+	var metricNames []string
+	seen := make(map[string]bool)
+	var collect func(buckets []*es.Bucket, depth string)
+	collect = func(buckets []*es.Bucket, depth string) {
+		for _, bucket := range buckets {
+			row := aggregationRow{key: depth + bucket.Key, docCount: strconv.FormatInt(bucket.DocCount, 10),
+				metrics: make(map[string]string), count: bucket.DocCount, top: depth == ""}
+			for _, m := range metricColumns("", bucket.Aggregations) {
+				if !seen[m.name] {
+					seen[m.name] = true
+					metricNames = append(metricNames, m.name)
+				}
+				row.metrics[m.name] = m.value
+			}
+			rows = append(rows, row)
+			var children []*es.Aggregation
+			for _, sub := range bucket.Aggregations {
+				if sub.Buckets != nil {
+					children = append(children, sub)
+				}
+			}
+			for _, child := range children {
+				if len(children) > 1 {
+					// label tells buckets of sibling aggregations apart
+					rows = append(rows, aggregationRow{key: depth + "  " + child.Name + ":"})
+				}
+				collect(child.Buckets, depth+"  ")
+			}
+		}
+	}
+	collect(agg.Buckets, "")
+
+	chart := histogramTypes[agg.Type]
+	var maxCount int64
+	for _, row := range rows {
+		if row.top && row.count > maxCount {
+			maxCount = row.count
+		}
+	}
+	columns := append([]string{indent + "key", "doc_count"}, metricNames...)
+	if chart {
+		columns = append(columns, "")
+	}
+	table := make([][]string, len(rows))
+	for i, row := range rows {
+		line := []string{indent + row.key, row.docCount}
+		for _, name := range metricNames {
+			line = append(line, row.metrics[name])
+		}
+		if chart {
+			bar := ""
+			// bars are drawn for buckets of the histogram itself, counts of nested buckets are not comparable
+			if row.top {
+				bar = histogramBar(row.count, maxCount)
+			}
+			line = append(line, bar)
+		}
+		table[i] = line
+	}
+	barColumn := len(columns) - 1
+	printTable(c, columns, table, func(row int, column int, value string) string {
+		if chart && column == barColumn && value != "" {
+			return gre(value)
+		}
+		return value
+	})
+}
+
+// metricColumns lists values of metric aggregations as table cells. Multi-value metrics produce a column per
+// value and single bucket aggregations a column with document count followed by columns of their sub-aggregations.
+// Bucket aggregations are skipped
+func metricColumns(prefix string, aggs []*es.Aggregation) []field {
+	var result []field
+	for _, agg := range aggs {
+		name := prefix + agg.Name
+		switch {
+		case agg.Buckets != nil:
+			continue
+		case len(agg.Values) == 1 && agg.Values[0].Name == "value":
+			result = append(result, field{name, metricString(agg.Values[0].Value)})
+		case agg.Values != nil:
+			for _, v := range agg.Values {
+				result = append(result, field{name + "." + v.Name, metricString(v.Value)})
+			}
+		case agg.DocCount != nil:
+			result = append(result, field{name, strconv.FormatInt(*agg.DocCount, 10)})
+			result = append(result, metricColumns(name+".", agg.Aggregations)...)
+		case agg.Raw != nil:
+			data, _ := json.Marshal(agg.Raw)
+			result = append(result, field{name, truncate(string(data), maxCellWidth)})
+		}
+	}
+	return result
+}
+
+// histogramBar returns a bar of length proportional to count. Non-empty buckets have at least one mark
+func histogramBar(count int64, maxCount int64) string {
+	if count <= 0 || maxCount <= 0 {
+		return ""
+	}
+	width := int(count * maxBarWidth / maxCount)
+	if width == 0 {
+		width = 1
+	}
+	return strings.Repeat("#", width)
+}
+
+// metricString formats value of a metric. Numbers are rounded to three decimal places, missing values are shown
+// as dash
+func metricString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "-"
+	case float64:
+		return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+	}
+	return scalarString(value)
+}
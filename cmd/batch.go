@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	shlex "github.com/flynn-archive/go-shlex"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+var (
+	// batchInput supplies commands and their multi-line input in batch mode. It is nil in interactive mode
+	batchInput *bufio.Scanner
+	// commandFailed is set when command reports an error
+	commandFailed bool
+)
+
+// BatchInput returns source of commands for non-interactive mode: commands given with -c, script given with -f or
+// standard input if it is not a terminal. Returns nil if shell should run interactively. Input must be closed
+// after commands are executed
+func (s *Settings) BatchInput() (io.ReadCloser, error) {
+	if len(s.Command) > 0 {
+		return ioutil.NopCloser(strings.NewReader(strings.Join(s.Command, "\n"))), nil
+	}
+	if s.File == "-" {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	if s.File != "" {
+		return os.Open(s.File)
+	}
+	if stat, err := os.Stdin.Stat(); err == nil && stat.Mode()&os.ModeCharDevice == 0 {
+		return ioutil.NopCloser(os.Stdin), nil
+	}
+	return nil, nil
+}
+
+// Connected checks if shell is connected to a cluster
+func Connected() bool {
+	return context != nil
+}
+
+// StartBatch switches shell to batch mode with commands read from input. Commands executed after that, including
+// connect command run on start, never prompt for input
+func StartBatch(input io.Reader) {
+	batchInput = bufio.NewScanner(input)
+}
+
+// RunBatch executes commands from batch input one by one. Empty lines and lines starting with # are skipped.
+// Execution stops at the first failed command, in which case false is returned
+func RunBatch(shell *ishell.Shell) bool {
+	defer func() {
+		batchInput = nil
+	}()
+
+	for batchInput.Scan() {
+		line := strings.TrimSpace(batchInput.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		args, err := shlex.Split(line)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, red(fmt.Sprintf("Invalid command '%s': %s", line, err.Error())))
+			return false
+		}
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "exit" {
+			return true
+		}
+		commandFailed = false
+		if err := shell.Process(args...); err != nil {
+			fmt.Fprintln(os.Stderr, red(fmt.Sprintf("Failed to execute '%s': %s", line, err.Error())))
+			return false
+		}
+		if commandFailed {
+			return false
+		}
+	}
+	if err := batchInput.Err(); err != nil {
+		fmt.Fprintln(os.Stderr, red("Failed to read commands: "+err.Error()))
+		return false
+	}
+	return true
+}
+
+// isBatch checks if commands are executed non-interactively
+func isBatch() bool {
+	return batchInput != nil
+}
+
+// readMultiLines reads input until a line ending with terminator. In batch mode input is taken from the lines
+// following the command. Returned text includes terminator
+func readMultiLines(c *ishell.Context, terminator string) string {
+	if !isBatch() {
+		return c.ReadMultiLines(terminator)
+	}
+	var lines []string
+	for batchInput.Scan() {
+		line := batchInput.Text()
+		lines = append(lines, line)
+		if strings.HasSuffix(strings.TrimSpace(line), terminator) {
+			return strings.TrimRightFunc(strings.Join(lines, "\n"), func(r rune) bool { return r == ' ' || r == '\t' })
+		}
+	}
+	// input ended without terminator, everything read so far is used
+	return strings.Join(lines, "\n") + terminator
+}
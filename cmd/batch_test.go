@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"shelastic/test/esfake"
+	"strings"
+	"testing"
+
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+var personalities = []esfake.Personality{esfake.ES1, esfake.ES2, esfake.ES5, esfake.ES6, esfake.ES7, esfake.ES8, esfake.OpenSearch}
+
+// runScript connects to fake cluster and executes commands in batch mode as shelastic -f does.
+// Returns printed output and outcome of the batch
+func runScript(t *testing.T, fake *esfake.Server, script string) (string, bool) {
+	dir := t.TempDir()
+	Initialize(&Settings{NoColor: true, Output: "text", Config: filepath.Join(dir, "config.yaml"), DataDir: dir})
+	// configuration file is optional for connection to a host
+	configErr = nil
+	// every script starts with a single session of its own
+	closeSessions()
+	t.Cleanup(closeSessions)
+
+	var output bytes.Buffer
+	shell := ishell.New()
+	shell.SetOut(&output)
+	for _, c := range Commands {
+		shell.AddCmd(c)
+	}
+	StartBatch(strings.NewReader(script))
+	shell.Process("connect", fake.URL)
+	if !Connected() {
+		t.Fatalf("Connect failed: %s", output.String())
+	}
+	succeeded := RunBatch(shell)
+	return output.String(), succeeded
+}
+
+func closeSessions() {
+	for _, name := range sessionNames() {
+		closeSession(name)
+	}
+	pager = nil
+}
+
+// forEachPersonality runs test against fake cluster of every supported version
+func forEachPersonality(t *testing.T, test func(t *testing.T, fake *esfake.Server, doc string)) {
+	for _, personality := range personalities {
+		t.Run(personality.Distribution+personality.Version, func(t *testing.T) {
+			fake := esfake.New(personality)
+			defer fake.Close()
+			doc := ""
+			if !fake.Typeless() {
+				doc = "book"
+			}
+			test(t, fake, doc)
+		})
+	}
+}
+
+// docOption returns --doc option of document commands, it is only needed by clusters with document types
+func docOption(doc string) string {
+	if doc == "" {
+		return ""
+	}
+	return "--doc " + doc + " "
+}
+
+func expectOutput(t *testing.T, output string, expected ...string) {
+	for _, text := range expected {
+		if !strings.Contains(output, text) {
+			t.Errorf("Output does not contain %q:\n%s", text, output)
+		}
+	}
+}
+
+func TestConnectAndListIndices(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, doc string) {
+		fake.AddIndex("books", nil)
+		fake.AddAlias("books", "library")
+		fake.AddIndex("archive", nil)
+
+		output, ok := runScript(t, fake, "list indices\nuse library\n")
+		if !ok {
+			t.Fatalf("Batch failed:\n%s", output)
+		}
+		expectOutput(t, output, "Connected to fake-cluster", fake.Personality.Version, "archive", "books",
+			"For alias library selected index books")
+	})
+}
+
+func TestDocumentCommands(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, doc string) {
+		fake.AddIndex("books", nil)
+		script := fmt.Sprintf(`use books
+document put %[1]s1
+{"title": "Dune"};
+document get %[1]s1
+document delete %[1]s1
+`, docOption(doc))
+
+		output, ok := runScript(t, fake, script)
+		if !ok {
+			t.Fatalf("Batch failed:\n%s", output)
+		}
+		expectOutput(t, output, "created", "title: Dune", "Ok")
+		if documents := fake.Documents("books"); len(documents) != 0 {
+			t.Errorf("Document is not deleted: %v", documents)
+		}
+	})
+}
+
+func TestQueryPaging(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, doc string) {
+		for i := 1; i <= 5; i++ {
+			fake.AddDocument("books", doc, fmt.Sprint(i),
+				map[string]interface{}{"title": fmt.Sprintf("Book %d", i)})
+		}
+		script := fmt.Sprintf(`use books
+document query %s--size 2
+{"query": {"match_all": {}}};
+next
+next
+prev
+`, docOption(doc))
+
+		output, ok := runScript(t, fake, script)
+		if !ok {
+			t.Fatalf("Batch failed:\n%s", output)
+		}
+		expectOutput(t, output, "Showing 1-2 of 5 hits", "Showing 3-4 of 5 hits", "Showing 5-5 of 5 hits",
+			"Use next to see more", "Use prev to see previous hits")
+		if strings.Count(output, "Showing 3-4 of 5 hits") != 2 {
+			t.Errorf("Previous page is not shown again:\n%s", output)
+		}
+
+		// without index query runs across all indices
+		output, ok = runScript(t, fake, fmt.Sprintf("document query %s--size 2\n{};\nnext\n", docOption(doc)))
+		if !ok {
+			t.Fatalf("Query without index failed:\n%s", output)
+		}
+		expectOutput(t, output, "Showing 1-2 of 5 hits", "Showing 3-4 of 5 hits")
+		requests := fake.Requests()
+		if last := requests[len(requests)-1]; strings.Contains(last, "books") || strings.Contains(last, "pit") {
+			t.Errorf("Query without index is not sent to all indices, the last request is %s", last)
+		}
+	})
+}
+
+func TestErrorOutput(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, doc string) {
+		output, ok := runScript(t, fake, fmt.Sprintf("document get --index missing %s1\nlist indices\n", docOption(doc)))
+		if ok {
+			t.Fatalf("Batch with failed command succeeded:\n%s", output)
+		}
+		expectOutput(t, output, "index_not_found_exception: no such index [missing] [404]")
+		if fake.Personality != esfake.ES1 {
+			// errors of 1.x are plain strings without index name
+			expectOutput(t, output, "index: missing")
+		}
+		requests := fake.Requests()
+		if last := requests[len(requests)-1]; !strings.Contains(last, "/missing/") {
+			t.Errorf("Batch is not stopped at the failed command, the last request is %s", last)
+		}
+	})
+}
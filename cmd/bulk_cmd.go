@@ -25,7 +25,7 @@ func Bulk() *ishell.Cmd {
 
 	bulk.AddCmd(&ishell.Cmd{
 		Name: "export",
-		Help: "Exports data into file. Usage: export [--index <index-name>] [--doc <doc-type>] [--format ndjson|array] [--source] <filename>",
+		Help: "Exports data into file. Usage: export [--index <index-name>] [--doc <doc-type>] [--format ndjson|array] [--source] [--editor] <filename>",
 		Func: bulkExport,
 	})
 
@@ -51,7 +51,7 @@ func bulkImport(c *ishell.Context) {
 
 	slctr, err := parseDocumentArgsCustom(c.Args, &bulkArgs{})
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	selector := slctr.(*bulkArgs)
@@ -60,7 +60,7 @@ func bulkImport(c *ishell.Context) {
 		errorMsg(c, "Index not specified")
 		return
 	}
-	if selector.Document == "" && selector.Format != ndjson {
+	if missingDocument(selector) && selector.Format != ndjson {
 		errorMsg(c, "Document not specified")
 		return
 	}
@@ -84,8 +84,9 @@ func bulkImport(c *ishell.Context) {
 	}
 
 	if err != nil {
-		errorMsg(c, "Failed to bulk insert data from "+selector.Args[0]+" "+err.Error())
+		printError(c, err, "Failed to bulk insert data from %s", selector.Args[0])
 	} else {
+		metadata.refresh(metadataIndices)
 		cprintln(c, "Done")
 	}
 
@@ -100,12 +101,13 @@ func bulkExport(c *ishell.Context) {
 		documentSelectorData
 		Format string `long:"format" choice:"ndjson" choice:"array" default:"array" description:"Export file format"`
 		Source bool   `long:"source"  description:"Export only '_source' attribute"`
+		Editor bool   `long:"editor" description:"Edit query in external editor"`
 	}
 
 	slctr, err := parseDocumentArgsCustom(c.Args, &bulkArgs{})
 
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	selector := slctr.(*bulkArgs)
@@ -116,22 +118,11 @@ func bulkExport(c *ishell.Context) {
 
 	fileName := selector.Args[0]
 
-	cprintln(c, "Enter query, ending with ';'")
-	c.SetPrompt(">>> ")
-	defer restorePrompt(c)
-	q := c.ReadMultiLines(";")
-	if len(q) > 0 {
-		q = q[:len(q)-1]
-	} else {
-		errorMsg(c, "Invalid query")
+	q, ok := readQuery(c, selector.Index, selector.Document, selector.Editor)
+	if !ok {
 		return
 	}
 
-	if len(q) == 0 {
-		q = "{\"query\": {\"match_all\":{}}}"
-		cprintln(c, "Using match all query")
-	}
-
 	recChan := make(chan *es.BulkRecord, 50)
 	errChan := make(chan error)
 	finChan := make(chan error)
@@ -145,7 +136,7 @@ func bulkExport(c *ishell.Context) {
 	select {
 	case err = <-errChan:
 		if err != nil {
-			errorMsg(c, "ES read error: "+err.Error())
+			printError(c, err, "ES read error")
 		}
 		finChan <- fmt.Errorf("stop")
 	case err = <-finChan:
@@ -213,11 +204,14 @@ func recordWriter(c *ishell.Context, fileName string, source bool, format string
 				singleJSONString := strings.Replace(jsonString, "\n", "", -1)
 				if format == ndjson {
 					meta := make(map[string]interface{})
-					meta["index"] = map[string]interface{}{
+					action := map[string]interface{}{
 						"_index": rec.Index,
-						"_type":  rec.Document,
 						"_id":    rec.ID,
 					}
+					if rec.Document != "" && !context.Capabilities.Typeless {
+						action["_type"] = rec.Document
+					}
+					meta["index"] = action
 
 					metaStr, err := utils.MapToJSON(meta)
 					if err != nil {
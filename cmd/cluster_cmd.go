@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"shelastic/utils"
-
 	ishell "gopkg.in/abiosoft/ishell.v2"
 )
 
@@ -34,9 +32,11 @@ func health(c *ishell.Context) {
 	} else {
 		h, err := context.Health()
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
-			cprintln(c, h.String())
+			render(c, h, func() {
+				cprintln(c, h.String())
+			})
 		}
 	}
 }
@@ -47,12 +47,9 @@ func clusterSettings(c *ishell.Context) {
 	} else {
 		settings, err := context.GetSettings()
 		if err != nil {
-			errorMsg(c, err.Error())
-		}
-		yaml, err := utils.MapToYaml(settings)
-		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
+			return
 		}
-		cprintln(c, yaml)
+		renderYAML(c, settings)
 	}
 }
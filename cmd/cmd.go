@@ -4,14 +4,17 @@ import (
 	"fmt"
 	"reflect"
 	"shelastic/es"
+	"strconv"
 	"strings"
 
 	"github.com/fatih/color"
+	flags "github.com/jessevdk/go-flags"
 	"gopkg.in/abiosoft/ishell.v2"
 )
 
 var (
-	context *es.Es
+	context  *es.Es
+	settings = &Settings{}
 
 	// Commands contain list of available top-level shell commands
 	Commands = []*ishell.Cmd{
@@ -23,9 +26,18 @@ var (
 		Snapshot(),
 		Nodes(),
 		Debug(),
+		Set(),
+		Trace(),
+		Rest(),
+		RunConsole(),
 		UseIndex(),
 		Document(),
 		Bulk(),
+		Profiles(),
+		Session(),
+		Query(),
+		Next(),
+		Prev(),
 	}
 
 	bl   = color.New(color.FgBlue).SprintfFunc()
@@ -42,41 +54,205 @@ const (
 	errNotConnected = "Not connected to Elasticsearch cluster"
 )
 
+// TLSSettings contains TLS parameters used for connection to Elasticsearch cluster
+type TLSSettings struct {
+	CACert     string      `long:"ca-cert" description:"PEM file with CA certificates used to verify server certificate" value-name:"FILE" yaml:"ca-cert"`
+	ClientCert string      `long:"cert" description:"PEM file with client certificate for mutual TLS" value-name:"FILE" yaml:"cert"`
+	ClientKey  string      `long:"key" description:"PEM file with client private key for mutual TLS" value-name:"FILE" yaml:"key"`
+	ServerName string      `long:"server-name" description:"Server name used to verify server certificate" value-name:"NAME" yaml:"server-name"`
+	Insecure   *switchFlag `long:"insecure" description:"Do not verify server certificate. Use --insecure=false to verify certificate when profile disables verification" optional:"yes" optional-value:"true" yaml:"insecure"`
+}
+
+// switchFlag is a boolean option which accepts explicit value, e.g. --insecure=false. Option which is not given
+// is nil, so explicit false can be told apart from an unset option
+type switchFlag bool
+
+// UnmarshalFlag parses value of the option
+func (f *switchFlag) UnmarshalFlag(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return fmt.Errorf("Invalid boolean value: %s", value)
+	}
+	*f = switchFlag(b)
+	return nil
+}
+
+// enabled returns true if option is given and is not false
+func (f *switchFlag) enabled() bool {
+	return f != nil && bool(*f)
+}
+
+// AuthSettings contains credentials used for connection to Elasticsearch cluster
+type AuthSettings struct {
+	Username string `short:"u" long:"user" description:"User name for basic authentication. Password is requested if not specified" value-name:"USER" yaml:"user"`
+	Password string `long:"password" description:"Password for basic authentication" value-name:"PASSWORD" yaml:"password"`
+	APIKey   string `long:"api-key" description:"Elasticsearch API key, either base64-encoded or in <id>:<key> form" value-name:"KEY" yaml:"api-key"`
+	Token    string `long:"token" description:"Bearer token" value-name:"TOKEN" yaml:"token"`
+}
+
+// connectSettings contains all the parameters accepted by connect command
+type connectSettings struct {
+	TLSSettings
+	AuthSettings
+	Sniff   bool   `long:"sniff" description:"Discover all cluster nodes and distribute requests between them"`
+	Session string `long:"as" description:"Session name. Cluster name is used by default" value-name:"NAME"`
+	Profile string `long:"profile" description:"Connect using named profile from configuration file" value-name:"NAME"`
+}
+
 // Settings contains shelastic shell settings configured through command line parameters
 type Settings struct {
-	NoColor bool `short:"n" long:"no-color" description:"Do not use colors in terminal"`
+	NoColor bool   `short:"n" long:"no-color" description:"Do not use colors in terminal"`
+	Profile string `short:"p" long:"profile" description:"Connect to cluster using named profile from configuration file" value-name:"NAME"`
+	Config  string `long:"config" description:"Configuration file with connection profiles. Default is ~/.shelastic.yaml" value-name:"FILE"`
+	Host    string `long:"host" description:"Connect to cluster at given host(s) on start" value-name:"HOST"`
+	Output  string `short:"o" long:"output" description:"Output format of command results" choice:"text" choice:"json" choice:"yaml" choice:"table" choice:"csv" default:"text"`
+	Editor  bool   `long:"editor" description:"Edit queries, documents and index settings in $EDITOR instead of the prompt"`
+	DataDir string `long:"data-dir" description:"Directory with saved queries and query history. Default is ~/.shelastic" value-name:"DIR"`
+	// batch mode
+	Command []string `short:"c" long:"command" description:"Execute command and exit, can be repeated" value-name:"COMMAND"`
+	File    string   `short:"f" long:"file" description:"Execute commands from file and exit. Use - to read commands from standard input" value-name:"FILE"`
+	Yes     bool     `long:"yes" description:"Confirm dangerous operations in batch mode"`
+	TLSSettings
+	AuthSettings
 }
 
 // Initialize sets up internal state of the shell. Must be called before starting the shell
-func Initialize(settings *Settings) {
+func Initialize(s *Settings) {
+	settings = s
 	color.NoColor = settings.NoColor
+	loadProfiles(settings.Config)
+	registerCompleters(Commands, "")
+}
+
+// merge returns TLS settings where every unset parameter is taken from defaults
+func (t TLSSettings) merge(defaults TLSSettings) TLSSettings {
+	if t.CACert == "" {
+		t.CACert = defaults.CACert
+	}
+	if t.ClientCert == "" {
+		t.ClientCert = defaults.ClientCert
+	}
+	if t.ClientKey == "" {
+		t.ClientKey = defaults.ClientKey
+	}
+	if t.ServerName == "" {
+		t.ServerName = defaults.ServerName
+	}
+	if t.Insecure == nil {
+		t.Insecure = defaults.Insecure
+	}
+	return t
+}
+
+// toOptions converts TLS settings to es.TLSOptions
+func (t TLSSettings) toOptions() *es.TLSOptions {
+	return &es.TLSOptions{
+		CACert:     t.CACert,
+		ClientCert: t.ClientCert,
+		ClientKey:  t.ClientKey,
+		ServerName: t.ServerName,
+		Insecure:   t.Insecure.enabled(),
+	}
+}
+
+// merge returns authentication settings if any credentials are set, otherwise defaults are returned.
+// Credentials are never mixed between settings
+func (a AuthSettings) merge(defaults AuthSettings) AuthSettings {
+	if a.Username == "" && a.APIKey == "" && a.Token == "" {
+		return defaults
+	}
+	return a
+}
+
+// toOptions converts authentication settings to es.AuthOptions
+func (a AuthSettings) toOptions() *es.AuthOptions {
+	return &es.AuthOptions{
+		Username:    a.Username,
+		Password:    a.Password,
+		APIKey:      a.APIKey,
+		BearerToken: a.Token,
+	}
 }
 
 // Connect performs connection to Elasticsearh cluster
 func Connect() *ishell.Cmd {
 	return &ishell.Cmd{
 		Name: "connect",
-		Help: "Connect to ElasticSearch",
+		Help: "Connect to ElasticSearch. Usage: connect [--ca-cert <file>] [--cert <file> --key <file>] [--server-name <name>] [--insecure[=false]] [--user <user> [--password <password>] | --api-key <key> | --token <token>] [--sniff] [--as <session-name>] [--profile <profile> | profile | host[,host...]]",
 		Func: func(c *ishell.Context) {
-			if context != nil {
-				errorMsg(c, "Already connected to %s. Disconnect before connecting to another cluster", context.ClusterName)
+			args := &connectSettings{}
+			positional, err := flags.ParseArgs(args, c.Args)
+			if err != nil {
+				printError(c, err)
 				return
 			}
+			if _, ok := sessions[args.Session]; ok {
+				errorMsg(c, "Session %s already exists. Disconnect it before connecting to another cluster", args.Session)
+				return
+			}
+			defaults := connectSettings{
+				TLSSettings:  settings.TLSSettings,
+				AuthSettings: settings.AuthSettings,
+			}
 			var host string
-			if len(c.Args) < 1 {
+			var profile *Profile
+			if args.Profile != "" {
+				if len(positional) > 0 {
+					errorMsg(c, "Only one of --profile and host can be given")
+					return
+				}
+				if configErr != nil {
+					errorMsg(c, "Failed to read %s: %s", configPath, configErr.Error())
+					return
+				}
+				if profile = findProfile(args.Profile); profile == nil {
+					errorMsg(c, "Unknown profile %s", args.Profile)
+					return
+				}
+			} else if len(positional) < 1 {
 				host = "localhost"
-			} else {
-				host = c.Args[0]
+			} else if profile = findProfile(positional[0]); profile == nil {
+				if configErr != nil {
+					// argument may be a profile which could not be read, it is taken for a host name
+					printMessage(c, yel(fmt.Sprintf("Failed to read %s: %s", configPath, configErr.Error()))+"\n")
+				}
+				host = positional[0]
+			}
+			if profile != nil {
+				host = strings.Join(profile.Hosts, ",")
+				defaults.TLSSettings = profile.TLS.merge(defaults.TLSSettings)
+				defaults.AuthSettings = profile.Auth.merge(defaults.AuthSettings)
+				defaults.Sniff = profile.Sniff
+			}
+			options := &es.ConnectOptions{
+				TLS:     args.TLSSettings.merge(defaults.TLSSettings).toOptions(),
+				Auth:    args.AuthSettings.merge(defaults.AuthSettings).toOptions(),
+				Sniff:   args.Sniff || defaults.Sniff,
+				Tracer:  tracer,
+				OnQuery: recordQuery,
+			}
+			if options.Auth.Username != "" && options.Auth.Password == "" {
+				if isBatch() {
+					errorMsg(c, "Password for %s is not specified. Use --password option in batch mode", options.Auth.Username)
+					return
+				}
+				options.Auth.Password = readPassword(c, options.Auth.Username)
 			}
 			cprintln(c, "Connecting to %s", host)
-			var err error
-			var ping *es.PingResponse
-			context, ping, err = es.Connect(host)
+			conn, ping, err := es.Connect(host, options)
 			if err == nil {
-				cprintlist(c, "Connected to ", cyb(ping.ClusterName), " (version ", ping.Version, ")")
+				noColor := settings.NoColor
+				if profile != nil && profile.NoColor != nil {
+					noColor = *profile.NoColor
+				}
+				name := addSession(args.Session, conn, noColor)
+				cprintlist(c, "Connected to ", cyb(ping.ClusterName), " (", conn.DistributionName(), " ", ping.Version, ") as session ", cyb(name))
 				onConnect(context, c)
+				if profile != nil && profile.Index != "" {
+					useIndex(c, profile.Index)
+				}
 			} else {
-				errorMsg(c, fmt.Sprintf("Failed to connect to %s: %s", host, err.Error()))
+				printError(c, err, "Failed to connect to %s", host)
 			}
 		},
 	}
@@ -86,11 +262,23 @@ func Connect() *ishell.Cmd {
 func Disconnect() *ishell.Cmd {
 	return &ishell.Cmd{
 		Name: "disconnect",
-		Help: "Close connection to ElasticSearch",
+		Help: "Close connection to ElasticSearch. Usage: disconnect [<session-name>]",
 		Func: func(c *ishell.Context) {
-			cprintln(c, "Disconnected from %s", context.ClusterName)
-			context = nil
-			c.SetPrompt("$> ")
+			name := activeSession
+			if len(c.Args) > 0 {
+				name = c.Args[0]
+			}
+			conn, ok := sessions[name]
+			if !ok {
+				errorMsg(c, errNotConnected)
+				return
+			}
+			closeSession(name)
+			cprintln(c, "Disconnected from %s", conn.ClusterName)
+			if context != nil {
+				cprintlist(c, "Using session ", cyb(activeSession))
+			}
+			restorePrompt(c)
 		},
 	}
 }
@@ -104,23 +292,24 @@ func List() *ishell.Cmd {
 
 	list.AddCmd(&ishell.Cmd{
 		Name: "indices",
-		Help: "List indices",
+		Help: "List indices. " + listIndicesUsage,
+		Func: listIndices,
+	})
+
+	list.AddCmd(&ishell.Cmd{
+		Name: "nodes",
+		Help: "List nodes",
 		Func: func(c *ishell.Context) {
 			if context != nil {
-				result, err := context.ListIndices()
+				result, err := context.ListNodes()
 				if err != nil {
-					errorMsg(c, "Failed to retrieve list of indices: %s", err.Error())
-				}
-				for _, index := range result {
-					var aliases = make([]string, len(index.Aliases))
-					for i, alias := range index.Aliases {
-						aliases[i] = alias.Name
-					}
-					aliasesstr := strings.Join(aliases, ", ")
-					cprintlist(c, cyb(index.Name), " [",
-						fmt.Sprintf("docs: %d, bytes: %d, aliases: %s", index.DocumentCount, index.Size, hbl(aliasesstr)),
-						"]")
-
+					printError(c, err, "Failed to retrieve list of nodes")
+				} else {
+					render(c, result, func() {
+						for _, node := range result {
+							cprintlist(c, cyb(node.Name), " @ ", hbl(node.Host), " ["+node.IP+"]")
+						}
+					})
 				}
 			} else {
 				errorMsg(c, errNotConnected)
@@ -129,18 +318,20 @@ func List() *ishell.Cmd {
 	})
 
 	list.AddCmd(&ishell.Cmd{
-		Name: "nodes",
-		Help: "List nodes",
+		Name: "hosts",
+		Help: "List cluster nodes used to send requests to",
 		Func: func(c *ishell.Context) {
 			if context != nil {
-				result, err := context.ListNodes()
-				if err != nil {
-					errorMsg(c, "Failed to retrieve list of nodes: %s", err.Error())
-				} else {
-					for _, node := range result {
-						cprintlist(c, cyb(node.Name), " @ ", hbl(node.Host), " ["+node.IP+"]")
+				hosts := context.Hosts()
+				render(c, hosts, func() {
+					for _, host := range hosts {
+						if host.Alive {
+							cprintlist(c, cyb(host.URL), " [", gre("alive"), "]")
+						} else {
+							cprintlist(c, cyb(host.URL), " [", red("dead"), " until ", host.DeadUntil.Format("15:04:05"), "]")
+						}
 					}
-				}
+				})
 			} else {
 				errorMsg(c, errNotConnected)
 			}
@@ -154,7 +345,7 @@ func List() *ishell.Cmd {
 func Debug() *ishell.Cmd {
 	return &ishell.Cmd{
 		Name: "_debug",
-		Help: "Toggle debug mode. Requests sent to ES cluster as well as responses are printed on the screen",
+		Help: "Toggle debug mode. Progress of bulk operations is printed on the screen. Use trace command to record requests",
 		Func: func(c *ishell.Context) {
 			if context == nil {
 				errorMsg(c, errNotConnected)
@@ -171,47 +362,91 @@ func Debug() *ishell.Cmd {
 }
 
 func cprintln(c *ishell.Context, format string, params ...interface{}) {
-	c.Println(bl(format, params...))
+	printMessage(c, bl(format, params...)+"\n")
 }
 
 func cprintf(c *ishell.Context, format string, params ...interface{}) {
-	c.Printf(bl(format, params...))
+	printMessage(c, bl(format, params...))
 }
 
 // cprintlist prints list of parameters on a line. If parameter is a function it is printed as is, otherwise it is wrapped in default color
 // After all items are printed, new line is printed
 func cprintlist(c *ishell.Context, params ...interface{}) {
-	for _, item := range params {
-		if reflect.TypeOf(item).Kind() == reflect.Func {
-			c.Print(item)
-		} else {
-			c.Print(bl("%v", item))
-		}
-	}
-	c.Println()
+	printMessage(c, colorList(params)+"\n")
 }
 
 // cprintl prints list of parameters on a line. If parameter is a function it is printed as is, otherwise it is wrapped in default color
 // No new line after all is printed
 func cprintl(c *ishell.Context, params ...interface{}) {
+	printMessage(c, colorList(params)+" ")
+}
+
+func colorList(params []interface{}) string {
+	var result strings.Builder
 	for _, item := range params {
 		if reflect.TypeOf(item).Kind() == reflect.Func {
-			c.Print(item)
+			fmt.Fprint(&result, item)
 		} else {
-			c.Print(bl("%v", item))
+			result.WriteString(bl("%v", item))
 		}
 	}
-	c.Print(" ")
+	return result.String()
 }
 
 func errorMsg(c *ishell.Context, message string, params ...interface{}) {
-	c.Println(red(fmt.Sprintf(message, params...)))
+	commandFailed = true
+	printMessage(c, red(fmt.Sprintf(message, params...))+"\n")
+}
+
+// printError prints error message. Elasticsearch errors are printed with all the details reported by the cluster.
+// Optional message with format parameters is printed before the error
+func printError(c *ishell.Context, err error, message ...interface{}) {
+	var prefix string
+	if len(message) > 0 {
+		prefix = fmt.Sprintf(fmt.Sprint(message[0]), message[1:]...) + ": "
+	}
+	esErr, ok := es.AsError(err)
+	if !ok {
+		errorMsg(c, "%s%s", prefix, err.Error())
+		return
+	}
+	commandFailed = true
+	errorLine := func(text string) {
+		printMessage(c, text+"\n")
+	}
+	if esErr.Type != "" {
+		errorLine(red(prefix) + red(esErr.Type) + ": " + red(esErr.Reason) + yel(fmt.Sprintf(" [%d]", esErr.Status)))
+	} else {
+		errorLine(red(prefix) + red(esErr.Error()) + yel(fmt.Sprintf(" [%d]", esErr.Status)))
+	}
+	if esErr.Index != "" {
+		errorLine("  index: " + cy(esErr.Index))
+	}
+	for cause := esErr.CausedBy; cause != nil; cause = cause.CausedBy {
+		errorLine("  caused by: " + yel(cause.Type) + ": " + cause.Reason)
+	}
+	if len(esErr.RootCause) > 0 && (len(esErr.RootCause) > 1 || esErr.RootCause[0].Reason != esErr.Reason) {
+		errorLine("  root cause:")
+		for _, cause := range esErr.RootCause {
+			errorLine("    " + yel(cause.Type) + ": " + cause.Reason)
+		}
+	}
+	if len(esErr.ShardFailures) > 0 {
+		errorLine("  shard failures:")
+		for _, failure := range esErr.ShardFailures {
+			reason := ""
+			if failure.Reason != nil {
+				reason = yel(failure.Reason.Type) + ": " + failure.Reason.Reason
+			}
+			errorLine(fmt.Sprintf("    [%s][%d] %s %s", failure.Index, failure.Shard, failure.Node, reason))
+		}
+	}
 }
 
 func onConnect(es *es.Es, c *ishell.Context) {
 	health, err := es.Health()
 	if err != nil {
-		errorMsg(c, "Failed to retrieve Elastisearch cluster health: %s", err.Error())
+		printError(c, err, "Failed to retrieve Elastisearch cluster health")
 		return
 	}
 	var colorw func(...interface{}) string
@@ -224,18 +459,35 @@ func onConnect(es *es.Es, c *ishell.Context) {
 		colorw = gre
 	}
 	cprintln(c, "Status: %s", colorw(health.Status))
-	c.SetPrompt(health.ClusterName + " $> ")
+	restorePrompt(c)
 }
 
+func readPassword(c *ishell.Context, user string) string {
+	c.SetPrompt(fmt.Sprintf("Password for %s: ", user))
+	defer restorePrompt(c)
+	return c.ReadPassword()
+}
+
+// restorePrompt sets prompt showing active session and index in use
 func restorePrompt(c *ishell.Context) {
-	if context.ActiveIndex != "" {
-		c.SetPrompt(fmt.Sprintf("%s.%s $> ", context.ClusterName, context.ActiveIndex))
+	if context == nil {
+		c.SetPrompt("$> ")
+	} else if context.ActiveIndex != "" {
+		c.SetPrompt(fmt.Sprintf("%s.%s $> ", activeSession, context.ActiveIndex))
 	} else {
-		c.SetPrompt(fmt.Sprintf("%s $> ", context.ClusterName))
+		c.SetPrompt(fmt.Sprintf("%s $> ", activeSession))
 	}
 }
 
 func dangerousPrompt(c *ishell.Context, text string) bool {
+	if isBatch() {
+		if settings.Yes {
+			cprintlist(c, text+" ", hbl("Confirmed with --yes"))
+			return true
+		}
+		errorMsg(c, "%s Use --yes option to confirm in batch mode", text)
+		return false
+	}
 	cprintlist(c, text+" ", hbl("Do you want to proceed (yes/No)?"))
 	c.SetPrompt("? ")
 	defer restorePrompt(c)
@@ -1,6 +1,9 @@
 package cmd
 
 import (
+	"reflect"
+	"sort"
+
 	flags "github.com/jessevdk/go-flags"
 )
 
@@ -70,3 +73,19 @@ func parseDocumentArgsCustom(args []string, customOpts interface{}) (interface{}
 
 	return customOpts, nil
 }
+
+// missingDocument checks if document type is required but not specified. Clusters 7.x+ do not need document type
+func missingDocument(selector documentSelector) bool {
+	return selector.GetDocument() == "" && !context.Capabilities.Typeless
+}
+
+// sortedKeys returns sorted keys of a map with string keys
+func sortedKeys(m interface{}) []string {
+	keys := reflect.ValueOf(m).MapKeys()
+	result := make([]string, len(keys))
+	for i, key := range keys {
+		result[i] = key.String()
+	}
+	sort.Strings(result)
+	return result
+}
@@ -0,0 +1,34 @@
+package cmd
+
+import (
+	"testing"
+
+	flags "github.com/jessevdk/go-flags"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestInsecureOverridesProfile(t *testing.T) {
+	var profile Profile
+	if err := yaml.Unmarshal([]byte("tls:\n  insecure: true\n"), &profile); err != nil {
+		t.Fatal(err)
+	}
+	cases := map[string]bool{
+		"":                 true,
+		"--insecure":       true,
+		"--insecure=true":  true,
+		"--insecure=false": false,
+	}
+	for arg, expected := range cases {
+		args := &connectSettings{}
+		var argv []string
+		if arg != "" {
+			argv = []string{arg}
+		}
+		if _, err := flags.ParseArgs(args, argv); err != nil {
+			t.Fatalf("Failed to parse %q: %s", arg, err)
+		}
+		if insecure := args.TLSSettings.merge(profile.TLS).toOptions().Insecure; insecure != expected {
+			t.Errorf("Insecure is %v with %q, expected %v", insecure, arg, expected)
+		}
+	}
+}
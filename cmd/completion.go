@@ -0,0 +1,459 @@
+package cmd
+
+import (
+	"net/http"
+	"shelastic/es"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	shlex "github.com/flynn-archive/go-shlex"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+// metadataTTL is an age after which cached cluster metadata is refreshed in background
+const metadataTTL = 30 * time.Second
+
+// Keys of cached cluster metadata
+const (
+	metadataIndices      = "indices"
+	metadataRepositories = "repositories"
+)
+
+func typesKey(index string) string {
+	return "types/" + index
+}
+
+func snapshotsKey(repo string) string {
+	return "snapshots/" + repo
+}
+
+// metadataCache keeps names of indices, aliases, document types, repositories and snapshots of the active
+// session, used to complete command arguments. Value is retrieved from the cluster when it is requested for the
+// first time. Later on cached value is returned immediately and is refreshed in background once it gets older
+// than metadataTTL, so that completion does not wait for the cluster
+type metadataCache struct {
+	mutex   sync.Mutex
+	conn    *es.Es
+	entries map[string]*metadataEntry
+}
+
+type metadataEntry struct {
+	values     []string
+	fetch      func(conn es.Es) ([]string, error)
+	fetched    time.Time
+	refreshing bool
+}
+
+var metadata = &metadataCache{entries: make(map[string]*metadataEntry)}
+
+// get returns cached value. Cache is reset when active session changes
+func (mc *metadataCache) get(key string, fetch func(conn es.Es) ([]string, error)) []string {
+	if context == nil {
+		return nil
+	}
+	mc.mutex.Lock()
+	if mc.conn != context {
+		mc.conn = context
+		mc.entries = make(map[string]*metadataEntry)
+	}
+	entry, ok := mc.entries[key]
+	if ok {
+		if time.Since(entry.fetched) > metadataTTL {
+			mc.refreshEntry(entry)
+		}
+		values := entry.values
+		mc.mutex.Unlock()
+		return values
+	}
+	mc.mutex.Unlock()
+
+	values, err := fetch(mc.connection(context))
+	if err != nil {
+		return nil
+	}
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if mc.conn == context {
+		mc.entries[key] = &metadataEntry{values: values, fetch: fetch, fetched: time.Now()}
+	}
+	return values
+}
+
+// refresh updates cached values in background after commands which change them, e.g. after index is deleted
+func (mc *metadataCache) refresh(keys ...string) {
+	mc.mutex.Lock()
+	defer mc.mutex.Unlock()
+	if mc.conn != context {
+		return
+	}
+	for _, key := range keys {
+		if entry, ok := mc.entries[key]; ok {
+			mc.refreshEntry(entry)
+		}
+	}
+}
+
+// refreshEntry starts background update of cache entry. Must be called with mutex locked
+func (mc *metadataCache) refreshEntry(entry *metadataEntry) {
+	if entry.refreshing {
+		return
+	}
+	entry.refreshing = true
+	conn, owner := mc.connection(mc.conn), mc.conn
+	go func() {
+		values, err := entry.fetch(conn)
+		mc.mutex.Lock()
+		defer mc.mutex.Unlock()
+		entry.refreshing = false
+		if mc.conn != owner {
+			return
+		}
+		if err != nil {
+			// failed request is retried on next access
+			return
+		}
+		entry.values = values
+		entry.fetched = time.Now()
+	}()
+}
+
+// connection returns a copy of the connection used to fetch metadata. Copy is made before the request is started,
+// so that it is not affected by commands changing the connection concurrently. Metadata requests are not traced
+func (mc *metadataCache) connection(conn *es.Es) es.Es {
+	result := *conn
+	result.Tracer = nil
+	return result
+}
+
+// argumentCompleter suggests values of a command argument. args contain words typed after the command name
+type argumentCompleter func(args []string) []string
+
+// completion describes arguments of a command: flags with completers of their values and completer of positional
+// arguments. Flags without value, such as --force, have nil completer
+type completion struct {
+	flags      map[string]argumentCompleter
+	positional argumentCompleter
+}
+
+var (
+	indexFlags    = map[string]argumentCompleter{"--index": completeIndices}
+	documentFlags = map[string]argumentCompleter{"--index": completeIndices, "--doc": completeTypes}
+	editorFlag    = map[string]argumentCompleter{"--editor": nil}
+	hitsFlags     = map[string]argumentCompleter{
+		"--from": anyValue, "--size": anyValue, "--fields": completeFields, "--table": nil, "--highlight": completeFields,
+		"--aggs-only": nil,
+	}
+	connectFlags = map[string]argumentCompleter{
+		"--ca-cert": anyValue, "--cert": anyValue, "--key": anyValue, "--server-name": anyValue, "--insecure": nil,
+		"--user": anyValue, "--password": anyValue, "--api-key": anyValue, "--token": anyValue,
+		"--sniff": nil, "--as": anyValue, "--profile": completeProfiles,
+	}
+	repoAndSnapshot = positionals(completeRepositories, completeSnapshots)
+
+	// completions contains completion of arguments for commands by their full name
+	completions = map[string]completion{
+		"connect":     {connectFlags, positionals(completeProfiles)},
+		"disconnect":  {nil, positionals(completeSessions)},
+		"session use": {nil, positionals(completeSessions)},
+		"set output":  {nil, positionals(choices(outputFormats...))},
+		"set editor":  {nil, positionals(choices("on", "off"))},
+		"use":         {nil, positionals(completeIndices)},
+		"rest": {
+			map[string]argumentCompleter{"--body": nil, "--json": nil},
+			positionals(choices(http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodHead)),
+		},
+		"run-console": {map[string]argumentCompleter{"--dry-run": nil, "--continue": nil}, nil},
+		"trace on":    {map[string]argumentCompleter{"--format": choices("text", "curl", "har"), "--no-redact": nil}, nil},
+		"list indices": {
+			map[string]argumentCompleter{
+				"--sort": choices(indexColumns...), "--reverse": nil,
+				"--health": choices("green", "yellow", "red"), "--columns": choices(indexColumns...),
+			},
+			completeIndices,
+		},
+
+		"index view mapping":  {documentFlags, nil},
+		"index view settings": {indexFlags, nil},
+		"index view shards":   {withFlags(indexFlags, map[string]argumentCompleter{"--mode": choices("by-node", "by-shard")}), nil},
+		"index view segments": {indexFlags, nil},
+		"index flush":         {withFlags(indexFlags, map[string]argumentCompleter{"--wait": nil, "--force": nil}), nil},
+		"index clear-cache":   {indexFlags, nil},
+		"index refresh":       {indexFlags, nil},
+		"index force-merge":   {indexFlags, nil},
+		"index configure":     {withFlags(indexFlags, editorFlag), nil},
+		"index restrict":      {indexFlags, positionals(choices("name", "ip", "host"), completeRestrictTarget)},
+		"index truncate":      {indexFlags, positionals(completeIndices)},
+		"index delete":        {indexFlags, positionals(completeIndices)},
+		"index add-alias":     {indexFlags, nil},
+		"index delete-alias":  {indexFlags, positionals(completeAliases)},
+		"index open":          {indexFlags, nil},
+		"index close":         {indexFlags, nil},
+		"index lifecycle":     {indexFlags, positionals(completeIndices)},
+		"index copy":          {withFlags(indexFlags, map[string]argumentCompleter{"--target": completeIndices}), nil},
+
+		"document list":       {indexFlags, nil},
+		"document properties": {documentFlags, nil},
+		"document get":        {documentFlags, nil},
+		"document put":        {withFlags(documentFlags, editorFlag, map[string]argumentCompleter{"--create": nil, "--update": nil}), nil},
+		"document delete":     {documentFlags, nil},
+		"document search":     {withFlags(documentFlags, hitsFlags), nil},
+		"document query":      {withFlags(documentFlags, hitsFlags, editorFlag), nil},
+		"document skeleton":   {documentFlags, positionals(completeFields)},
+
+		"query save":    {withFlags(documentFlags, editorFlag, map[string]argumentCompleter{"--last": nil}), nil},
+		"query show":    {nil, positionals(completeSavedQueries)},
+		"query run":     {withFlags(documentFlags, hitsFlags), positionals(completeSavedQueries)},
+		"query delete":  {nil, positionals(completeSavedQueries)},
+		"query history": {map[string]argumentCompleter{"--all": nil, "--limit": anyValue}, nil},
+
+		"bulk export": {withFlags(documentFlags, map[string]argumentCompleter{"--format": choices("ndjson", "array"), "--source": nil}, editorFlag), nil},
+		"bulk import": {withFlags(documentFlags, map[string]argumentCompleter{"--format": choices("ndjson", "array"), "--id-field": anyValue}), nil},
+
+		"snapshot repo verify": {nil, positionals(completeRepositories)},
+		"snapshot create":      {nil, positionals(completeRepositories)},
+		"snapshot info":        {nil, repoAndSnapshot},
+		"snapshot delete":      {nil, repoAndSnapshot},
+		"snapshot restore":     {nil, repoAndSnapshot},
+
+		"node stats":       {nil, completeNodes},
+		"node environment": {nil, completeNodes},
+		"node shards":      {nil, positionals(completeNodes)},
+		"node decomission": {
+			map[string]argumentCompleter{"--selector": choices("node", "ip", "host"), "--clear": nil},
+			completeNodes,
+		},
+	}
+)
+
+// ShellCompleter completes command names and arguments. While query is entered in multi-line editor, field names
+// and Query DSL keywords are completed instead
+type ShellCompleter struct {
+	root *ishell.Cmd
+}
+
+// NewCompleter creates completer of shell input. builtins are names of commands provided by the shell itself
+func NewCompleter(builtins ...string) *ShellCompleter {
+	root := &ishell.Cmd{}
+	for _, command := range Commands {
+		root.AddCmd(command)
+	}
+	for _, name := range builtins {
+		root.AddCmd(&ishell.Cmd{Name: name})
+	}
+	return &ShellCompleter{root}
+}
+
+// Do returns suggested endings of the word at the cursor position and length of the word, as readline expects
+func (sc *ShellCompleter) Do(line []rune, pos int) ([][]rune, int) {
+	if activeQuery != nil {
+		return activeQuery.complete(line[:pos])
+	}
+	words, err := shlex.Split(string(line[:pos]))
+	if err != nil {
+		words = strings.Fields(string(line[:pos]))
+	}
+	prefix := ""
+	if len(words) > 0 && pos > 0 && line[pos-1] != ' ' {
+		prefix = words[len(words)-1]
+		words = words[:len(words)-1]
+	}
+
+	var candidates []string
+	command, args := sc.root.FindCmd(words)
+	if command == nil {
+		command, args = sc.root, words
+	}
+	if command.Completer != nil {
+		candidates = command.Completer(args)
+	} else {
+		for _, child := range command.Children() {
+			candidates = append(candidates, child.Name)
+		}
+	}
+
+	var suggestions [][]rune
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			suggestions = append(suggestions, []rune(strings.TrimPrefix(candidate, prefix)))
+		}
+	}
+	if len(suggestions) == 1 && prefix != "" && len(suggestions[0]) == 0 {
+		suggestions = [][]rune{[]rune(" ")}
+	}
+	return suggestions, len([]rune(prefix))
+}
+
+// registerCompleters sets completers of all commands listed in completions
+func registerCompleters(commands []*ishell.Cmd, prefix string) {
+	for _, command := range commands {
+		name := strings.TrimSpace(prefix + " " + command.Name)
+		if cmpl, ok := completions[name]; ok {
+			command.Completer = cmpl.complete
+		}
+		registerCompleters(command.Children(), name)
+	}
+}
+
+// complete suggests values of the flag if the last word is a flag which requires a value.
+// Otherwise names of the flags and values of positional argument are suggested
+func (cmpl completion) complete(args []string) []string {
+	if len(args) > 0 {
+		if values, ok := cmpl.flags[args[len(args)-1]]; ok && values != nil {
+			return values(args)
+		}
+	}
+	result := make([]string, 0, len(cmpl.flags))
+	for name := range cmpl.flags {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	if cmpl.positional != nil {
+		result = append(result, cmpl.positional(cmpl.positionalArgs(args))...)
+	}
+	return result
+}
+
+// positionalArgs removes flags and their values from the arguments
+func (cmpl completion) positionalArgs(args []string) []string {
+	var result []string
+	for i := 0; i < len(args); i++ {
+		if values, ok := cmpl.flags[args[i]]; ok {
+			if values != nil {
+				i++
+			}
+			continue
+		}
+		result = append(result, args[i])
+	}
+	return result
+}
+
+// positionals completes positional arguments one by one, e.g. repository name first and then snapshot name.
+// Nothing is suggested after all the arguments are given
+func positionals(completers ...argumentCompleter) argumentCompleter {
+	return func(args []string) []string {
+		if len(args) >= len(completers) {
+			return nil
+		}
+		return completers[len(args)](args)
+	}
+}
+
+func withFlags(flagSets ...map[string]argumentCompleter) map[string]argumentCompleter {
+	result := make(map[string]argumentCompleter)
+	for _, flagSet := range flagSets {
+		for name, values := range flagSet {
+			result[name] = values
+		}
+	}
+	return result
+}
+
+func choices(values ...string) argumentCompleter {
+	return func(args []string) []string {
+		return values
+	}
+}
+
+// anyValue is a completer of flags which take arbitrary value. Nothing is suggested
+func anyValue(args []string) []string {
+	return nil
+}
+
+// flagValue returns value of the flag given in arguments or empty string if there is no such flag
+func flagValue(args []string, name string) string {
+	for i := 0; i < len(args)-1; i++ {
+		if args[i] == name {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// completeIndices suggests names of indices, including closed ones, and aliases
+func completeIndices(args []string) []string {
+	return metadata.get(metadataIndices, func(conn es.Es) ([]string, error) {
+		indices, err := conn.ListIndexInfo()
+		if err != nil {
+			return nil, err
+		}
+		result := make([]string, len(indices))
+		for i, index := range indices {
+			result[i] = index.Name
+		}
+		// alias cache is refreshed by ListIndexInfo
+		return append(result, conn.Aliases()...), nil
+	})
+}
+
+func completeAliases(args []string) []string {
+	if context == nil {
+		return nil
+	}
+	return context.Aliases()
+}
+
+// completeTypes suggests document types of the index given with --index or of the index in use
+func completeTypes(args []string) []string {
+	if context == nil {
+		return nil
+	}
+	index := flagValue(args, "--index")
+	if index == "" {
+		index = context.ActiveIndex
+	}
+	if index == "" {
+		return nil
+	}
+	return metadata.get(typesKey(index), func(conn es.Es) ([]string, error) {
+		return conn.ListDocuments(index)
+	})
+}
+
+func completeNodes(args []string) []string {
+	if context == nil {
+		return nil
+	}
+	result := make([]string, 0, len(context.Nodes))
+	for _, node := range context.Nodes {
+		result = append(result, node.Name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// completeRestrictTarget suggests node names when shards are restricted to a node by name
+func completeRestrictTarget(args []string) []string {
+	if len(args) > 0 && args[0] == "name" {
+		return completeNodes(args)
+	}
+	return nil
+}
+
+func completeRepositories(args []string) []string {
+	return metadata.get(metadataRepositories, func(conn es.Es) ([]string, error) {
+		repositories, err := conn.ListRepository()
+		if err != nil {
+			return nil, err
+		}
+		return sortedKeys(*repositories), nil
+	})
+}
+
+// completeSnapshots suggests names of snapshots in the repository given as the first argument
+func completeSnapshots(args []string) []string {
+	repo := args[0]
+	return metadata.get(snapshotsKey(repo), func(conn es.Es) ([]string, error) {
+		return conn.ListSnapshots(repo)
+	})
+}
+
+func completeProfiles(args []string) []string {
+	return sortedKeys(config.Profiles)
+}
+
+func completeSessions(args []string) []string {
+	return sessionNames()
+}
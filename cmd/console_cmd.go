@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"shelastic/es"
+
+	flags "github.com/jessevdk/go-flags"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+const runConsoleUsage = "Usage: run-console [--dry-run] [--continue] <file>"
+
+// RunConsole executes requests from Kibana Dev Tools console script
+func RunConsole() *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "run-console",
+		Help: "Executes requests from Kibana console script. " + runConsoleUsage,
+		Func: runConsole,
+	}
+}
+
+func runConsole(c *ishell.Context) {
+	var args struct {
+		DryRun   bool `long:"dry-run" description:"Only print parsed requests"`
+		Continue bool `long:"continue" description:"Continue with the next request after error"`
+	}
+	positional, err := flags.ParseArgs(&args, c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if len(positional) == 0 {
+		errorMsg(c, "Script file is not specified. "+runConsoleUsage)
+		return
+	}
+	if context == nil && !args.DryRun {
+		errorMsg(c, errNotConnected)
+		return
+	}
+
+	script, err := ioutil.ReadFile(positional[0])
+	if err != nil {
+		printError(c, err, "Failed to read %s", positional[0])
+		return
+	}
+	requests, err := es.ParseConsole(string(script))
+	if err != nil {
+		printError(c, err, "Failed to parse %s", positional[0])
+		return
+	}
+
+	if args.DryRun {
+		for _, req := range requests {
+			printConsoleRequest(c, req)
+		}
+		cprintln(c, "%d requests parsed", len(requests))
+		return
+	}
+
+	failed := 0
+	for i, req := range requests {
+		resp, err := context.Rest(req.Method, req.Path, req.Body)
+		if err != nil {
+			failed++
+			cprintlist(c, hbl("[%d/%d] ", i+1, len(requests)), cy(req.Method), " ", req.Path, " ", red("failed"))
+			printError(c, err)
+			if !args.Continue {
+				errorMsg(c, "Stopped at line %d, %d requests not executed", req.Line, len(requests)-i-1)
+				return
+			}
+			continue
+		}
+		cprintlist(c, hbl("[%d/%d] ", i+1, len(requests)), cy(req.Method), " ", req.Path, " ", gre(resp.Status, " ", http.StatusText(resp.Status)))
+	}
+	if failed > 0 {
+		errorMsg(c, "%d of %d requests failed", failed, len(requests))
+	} else {
+		cprintln(c, "%d requests executed", len(requests))
+	}
+}
+
+func printConsoleRequest(c *ishell.Context, req *es.ConsoleRequest) {
+	cprintlist(c, hbl("%d: ", req.Line), cy(req.Method), " ", req.Path)
+	if req.Body == "" {
+		return
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(req.Body), "", "  "); err == nil {
+		cprintln(c, "%s", indented.String())
+	} else {
+		// NDJSON bodies are printed as is
+		cprintln(c, "%s", req.Body)
+	}
+}
@@ -1,7 +1,9 @@
 package cmd
 
 import (
-	"encoding/json"
+	"fmt"
+	"shelastic/es"
+	"shelastic/utils"
 
 	ishell "gopkg.in/abiosoft/ishell.v2"
 )
@@ -35,23 +37,29 @@ func UseIndex() *ishell.Cmd {
 
 					return
 				}
-				s, err := context.ResolveAndValidateIndex(c.Args[0])
-				if err != nil {
-					errorMsg(c, err.Error())
-					return
-				}
-				context.ActiveIndex = s
-				if s != c.Args[0] {
-					cprintlist(c, "For alias ", cyb(c.Args[0]), " selected index ", cy(s))
-				} else {
-					cprintlist(c, "Selected index ", cy(s))
-				}
+				useIndex(c, c.Args[0])
 			}
 		},
 	}
 
 }
 
+// useIndex validates index name and makes it active. Aliases are resolved to actual index names
+func useIndex(c *ishell.Context, indexName string) {
+	s, err := context.ResolveAndValidateIndex(indexName)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	context.ActiveIndex = s
+	if s != indexName {
+		cprintlist(c, "For alias ", cyb(indexName), " selected index ", cy(s))
+	} else {
+		cprintlist(c, "Selected index ", cy(s))
+	}
+	restorePrompt(c)
+}
+
 // Document is a container for document-related operations
 func Document() *ishell.Cmd {
 	document := &ishell.Cmd{
@@ -73,34 +81,40 @@ func Document() *ishell.Cmd {
 
 	document.AddCmd(&ishell.Cmd{
 		Name: "get",
-		Help: "Retrieves document by its id. Usage: get [--index <index-name>] --doc <type> <id>",
+		Help: "Retrieves document by its id. Usage: get [--index <index-name>] [--doc <type>] <id>",
 		Func: getDocument,
 	})
 
 	document.AddCmd(&ishell.Cmd{
 		Name: "put",
-		Help: "Inserts/updates document. Usage: put [--index <index-name>] --doc <type> <id>",
+		Help: "Inserts/updates document. Usage: put [--index <index-name>] [--doc <type>] [--create|--update] [--editor] <id>",
 		Func: putDocument,
 	})
 
 	document.AddCmd(&ishell.Cmd{
 		Name: "delete",
-		Help: "Deletes document by its id. Usage: delete [--index <index-name>] --doc <type> <id>",
+		Help: "Deletes document by its id. Usage: delete [--index <index-name>] [--doc <type>] <id>",
 		Func: deleteDocument,
 	})
 
 	document.AddCmd(&ishell.Cmd{
 		Name: "search",
-		Help: "Peforms simple search. Usage: search [--index <index-name>] [--doc <types>] <search string>",
+		Help: "Peforms simple search. Usage: search [--index <index-name>] [--doc <types>] [--from <offset>] [--size <count>] [--fields <fields>] [--table] [--highlight <field>] <search string>",
 		Func: searchDocument,
 	})
 
 	document.AddCmd(&ishell.Cmd{
 		Name: "query",
-		Help: "Peforms search using query DSL. Usage: query [--index <index-name>]",
+		Help: "Peforms search using query DSL. Usage: query [--index <index-name>] [--doc <type>] [--from <offset>] [--size <count>] [--fields <fields>] [--table] [--highlight <field>] [--aggs-only] [--editor]",
 		Func: queryDocument,
 	})
 
+	document.AddCmd(&ishell.Cmd{
+		Name: "skeleton",
+		Help: "Prints query for the field, chosen according to the field type. " + skeletonUsage,
+		Func: querySkeleton,
+	})
+
 	return document
 }
 
@@ -111,7 +125,7 @@ func showDocs(c *ishell.Context) {
 	}
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	if selector.Index == "" {
@@ -122,11 +136,13 @@ func showDocs(c *ishell.Context) {
 	docs, err := context.ListDocuments(selector.Index)
 
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
-		for _, doc := range docs {
-			cprintln(c, doc)
-		}
+		render(c, docs, func() {
+			for _, doc := range docs {
+				cprintln(c, doc)
+			}
+		})
 	}
 }
 
@@ -137,7 +153,7 @@ func showProperties(c *ishell.Context) {
 	}
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	if selector.Index == "" {
@@ -145,7 +161,7 @@ func showProperties(c *ishell.Context) {
 		return
 	}
 	var doc string
-	if context.Version[0] > 6 {
+	if context.Capabilities.Typeless {
 		doc = "_doc"
 	} else if selector.Document != "" {
 		doc = selector.Document
@@ -161,11 +177,13 @@ func showProperties(c *ishell.Context) {
 	props, err := context.ListProperties(selector.Index, doc)
 
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
-		for _, prop := range props {
-			cprintln(c, "%s: %s", prop.Name, prop.Type)
-		}
+		render(c, props, func() {
+			for _, prop := range props {
+				cprintln(c, "%s: %s", prop.Name, prop.Type)
+			}
+		})
 	}
 }
 
@@ -176,23 +194,23 @@ func getDocument(c *ishell.Context) {
 	}
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	if selector.Index == "" {
 		errorMsg(c, errIndexNotSelected)
 		return
 	}
-	if len(selector.Args) == 0 || selector.Document == "" {
-		errorMsg(c, "Not enough parameters. Usage: get [--index <index-name>] --doc <doc-type> <id>")
+	if len(selector.Args) == 0 || missingDocument(selector) {
+		errorMsg(c, "Not enough parameters. Usage: get [--index <index-name>] [--doc <doc-type>] <id>")
 		return
 	}
 	doc, err := context.GetDocument(selector.Index, selector.Document, selector.Args[0])
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
-	cprintln(c, doc)
+	renderYAML(c, doc)
 }
 
 func putDocument(c *ishell.Context) {
@@ -200,31 +218,48 @@ func putDocument(c *ishell.Context) {
 		errorMsg(c, errNotConnected)
 		return
 	}
-	selector, err := parseDocumentArgs(c.Args)
+	type putArgs struct {
+		documentSelectorData
+		Create bool `long:"create" description:"Fail if document already exists"`
+		Update bool `long:"update" description:"Merge fields into existing document"`
+		Editor bool `long:"editor" description:"Edit document in external editor"`
+	}
+	slctr, err := parseDocumentArgsCustom(c.Args, &putArgs{})
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
+	selector := slctr.(*putArgs)
 	if selector.Index == "" {
 		errorMsg(c, errIndexNotSelected)
 		return
 	}
-	if len(selector.Args) == 0 || selector.Document == "" {
-		errorMsg(c, "Not enough parameters. Usage: put [--index <index-name>] --doc <doc-type> <id>")
+	if len(selector.Args) == 0 || missingDocument(selector) {
+		errorMsg(c, "Not enough parameters. Usage: put [--index <index-name>] [--doc <doc-type>] [--create|--update] [--editor] <id>")
 		return
 	}
-	cprintln(c, "Enter document body, ending with ';':")
-	c.SetPrompt(">>> ")
-	json := c.ReadMultiLines(";")
-	if len(json) < 0 {
-		cprintln(c, "Cancelled")
+	if selector.Create && selector.Update {
+		errorMsg(c, "Only one of --create and --update can be used")
 		return
 	}
-	json = json[:len(json)-1]
-	restorePrompt(c)
-	response, err := context.PutDocument(selector.Index, selector.Document, selector.Args[0], json)
+	if selector.Update && selector.Args[0] == "-" {
+		errorMsg(c, "Document id is required for update")
+		return
+	}
+	json, ok := readPayload(c, documentInput, selector.Editor)
+	if !ok {
+		return
+	}
+	var response string
+	if selector.Create {
+		response, err = context.CreateDocument(selector.Index, selector.Document, selector.Args[0], json)
+	} else if selector.Update {
+		response, err = context.UpdateDocument(selector.Index, selector.Document, selector.Args[0], json)
+	} else {
+		response, err = context.PutDocument(selector.Index, selector.Document, selector.Args[0], json)
+	}
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	cprintln(c, response)
@@ -237,20 +272,20 @@ func deleteDocument(c *ishell.Context) {
 	}
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	if selector.Index == "" {
 		errorMsg(c, errIndexNotSelected)
 		return
 	}
-	if len(selector.Args) == 0 || selector.Document == "" {
-		errorMsg(c, "Not enough parameters. Usage: delete [--index <index-name>] --doc <doc-type> <id>")
+	if len(selector.Args) == 0 || missingDocument(selector) {
+		errorMsg(c, "Not enough parameters. Usage: delete [--index <index-name>] [--doc <doc-type>] <id>")
 		return
 	}
 	err = context.DeleteDocument(selector.Index, selector.Document, selector.Args[0])
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	cprintln(c, "Ok")
@@ -261,28 +296,30 @@ func searchDocument(c *ishell.Context) {
 		errorMsg(c, errNotConnected)
 		return
 	}
-	selector, err := parseDocumentArgs(c.Args)
+	type searchArgs struct {
+		documentSelectorData
+		hitsArgs
+	}
+	slctr, err := parseDocumentArgsCustom(c.Args, &searchArgs{})
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
+	selector := slctr.(*searchArgs)
 	if selector.Index == "" {
 		errorMsg(c, errIndexNotSelected)
 		return
 	}
 	if len(selector.Args) == 0 {
-		errorMsg(c, "Not enough parameters. Usage: search [--index <index-name>] [--doc <doc-types>] <search query>")
+		errorMsg(c, "Not enough parameters. Usage: search [--index <index-name>] [--doc <doc-types>] [--from <offset>] [--size <count>] <search query>")
 		return
 	}
-	sr, err := context.Search(selector.Index, selector.Document, selector.Args[0])
+	p, err := context.NewSearchPager(selector.Index, selector.Document, selector.Args[0], selector.options())
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
-	cprintln(c, "Total hits: %d\n", sr.Total)
-	for _, hit := range sr.Hits {
-		cprintln(c, hit)
-	}
+	startPaging(c, p, selector.hitsArgs)
 }
 
 func queryDocument(c *ishell.Context) {
@@ -290,51 +327,96 @@ func queryDocument(c *ishell.Context) {
 		errorMsg(c, errNotConnected)
 		return
 	}
-	selector, err := parseDocumentArgs(c.Args)
-
+	type queryArgs struct {
+		documentSelectorData
+		hitsArgs
+		Editor bool `long:"editor" description:"Edit query in external editor"`
+	}
+	slctr, err := parseDocumentArgsCustom(c.Args, &queryArgs{})
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
+	selector := slctr.(*queryArgs)
 
-	cprintln(c, "Enter query, ending with ';'")
-	defer restorePrompt(c)
-	c.SetPrompt(">>> ")
-	q := c.ReadMultiLines(";")
-	if len(q) > 0 {
-		q = q[:len(q)-1]
-	} else {
-		errorMsg(c, "Invalid query")
+	q, ok := readQuery(c, selector.Index, selector.Document, selector.Editor)
+	if !ok {
 		return
 	}
+	runQuery(c, selector.Index, selector.Document, q, selector.hitsArgs)
+}
 
-	if len(q) == 0 {
-		q = "{\"query\": {\"match_all\":{}}}"
-		cprintln(c, "Using match all query")
-	}
-
-	var body map[string]interface{}
-
-	if err := json.Unmarshal([]byte(q), &body); err != nil {
-		errorMsg(c, "Invalid query JSON: "+err.Error())
+// runQuery executes query and prints the first page of hits
+func runQuery(c *ishell.Context, index string, doc string, q string, args hitsArgs) {
+	p, err := context.NewQueryPager(index, doc, q, args.options())
+	if err != nil {
+		printError(c, err)
 		return
 	}
+	startPaging(c, p, args)
+}
 
-	body["size"] = 20
-	bytes, err := json.Marshal(body)
+// searchOutput is a search result as it is shown in structured output. Table and CSV formats show only hits,
+// or aggregations if there are no hits
+type searchOutput struct {
+	From          int                      `json:"from"`
+	Total         int                      `json:"total"`
+	TotalRelation string                   `json:"total_relation,omitempty"`
+	Hits          []map[string]interface{} `json:"hits"`
+	Aggregations  map[string]interface{}   `json:"aggregations,omitempty"`
+}
 
-	if err != nil {
-		errorMsg(c, "Failed to repack query JSON: "+err.Error())
-		return
+func (s searchOutput) rows() interface{} {
+	if len(s.Hits) == 0 && s.Aggregations != nil {
+		return s.Aggregations
 	}
+	return s.Hits
+}
 
-	sr, err := context.Query(selector.Index, selector.Document, string(bytes))
-	if err != nil {
-		errorMsg(c, err.Error())
-		return
-	}
-	cprintln(c, "Total hits: %d\n", sr.Total)
-	for _, hit := range sr.Hits {
-		cprintln(c, hit)
+// printHits prints hits in YAML, highlighted fragments follow the hit they belong to
+func printHits(c *ishell.Context, hits []map[string]interface{}) bool {
+	for _, hit := range hits {
+		highlight, _ := hit["highlight"].(map[string]interface{})
+		if highlight != nil {
+			hit = withoutField(hit, "highlight")
+		}
+		record, err := utils.MapToYaml(hit)
+		if err != nil {
+			printError(c, err)
+			return false
+		}
+		cprintln(c, "%s", record)
+		if highlight != nil {
+			printHighlight(c, highlight)
+		}
 	}
+	return true
+}
+
+func printSearchResult(c *ishell.Context, sr *es.SearchResult, view hitsArgs) {
+	render(c, searchOutput{sr.From, sr.Total, sr.TotalRelation, sr.Hits, sr.RawAggregations}, func() {
+		total := fmt.Sprintf("%d", sr.Total)
+		if sr.TotalRelation == "gte" {
+			total = "at least " + total
+		}
+		if view.AggsOnly {
+			cprintln(c, "Total hits: %s\n", total)
+			printAggregations(c, sr.Aggregations, "")
+			return
+		}
+		if len(sr.Hits) == 0 {
+			cprintln(c, "No hits to show, total hits: %s\n", total)
+		} else {
+			cprintln(c, "Showing %d-%d of %s hits\n", sr.From+1, sr.From+len(sr.Hits), total)
+		}
+		if view.Table {
+			printHitsTable(c, sr.Hits, splitList(view.Fields))
+			if len(sr.Hits) > 0 {
+				cprintln(c, "")
+			}
+		} else if !printHits(c, sr.Hits) {
+			return
+		}
+		printAggregations(c, sr.Aggregations, "")
+	})
 }
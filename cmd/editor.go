@@ -0,0 +1,218 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+
+	shlex "github.com/flynn-archive/go-shlex"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+// payloadInput describes multi-line input of a command, such as query or document
+type payloadInput struct {
+	// kind identifies input, last payload of the same kind is offered for editing
+	kind string
+	// prompt is a message printed before input is read at the prompt
+	prompt string
+	// template is an initial content of the editor if there is no last payload
+	template string
+	// validate checks payload before it is sent to the cluster
+	validate func(payload string) error
+}
+
+var (
+	queryInput = payloadInput{
+		kind:     "query",
+		prompt:   "Enter query, ending with ';'. Press Tab to complete field names and keywords",
+		template: "{\n  \"query\": {\n    \"match_all\": {}\n  }\n}\n",
+		validate: validateQuery,
+	}
+	documentInput = payloadInput{
+		kind:     "document",
+		prompt:   "Enter document body, ending with ';':",
+		template: "{\n}\n",
+		validate: validateJSON,
+	}
+	settingsInput = payloadInput{
+		kind:     "settings",
+		prompt:   "Enter configuration parameters, one per line. Finish with ;",
+		template: "index.number_of_replicas: 1\n",
+		validate: validateSettings,
+	}
+
+	// lastPayloads contains last entered payload of every kind
+	lastPayloads = make(map[string]string)
+)
+
+func setEditor(c *ishell.Context) {
+	if len(c.Args) > 0 {
+		switch c.Args[0] {
+		case "on":
+			settings.Editor = true
+		case "off":
+			settings.Editor = false
+		default:
+			errorMsg(c, "Usage: set editor [on|off]")
+			return
+		}
+	}
+	if settings.Editor {
+		cprintlist(c, "Editor: ", cyb(editorCommand()))
+	} else {
+		cprintlist(c, "Editor: ", cyb("off"))
+	}
+}
+
+// readPayload reads multi-line input of a command. Input is edited in external editor if useEditor is set, or if
+// editor setting is on and shell is interactive. Otherwise input is read at the prompt up to the line ending with ';'.
+// Payload is validated, returns false if input is cancelled or invalid
+func readPayload(c *ishell.Context, input payloadInput, useEditor bool) (string, bool) {
+	var payload string
+	if useEditor || (settings.Editor && !isBatch()) {
+		initial, ok := lastPayloads[input.kind]
+		if !ok {
+			initial = input.template
+		}
+		text, err := editPayload(initial)
+		if err != nil {
+			printError(c, err, "Failed to edit %s", input.kind)
+			return "", false
+		}
+		payload = strings.TrimSpace(text)
+		if strings.HasSuffix(payload, ";") {
+			// terminator is not needed in editor, but it is accepted
+			payload = strings.TrimSpace(strings.TrimSuffix(payload, ";"))
+		}
+	} else {
+		cprintln(c, "%s", input.prompt)
+		c.SetPrompt(">>> ")
+		text := readMultiLines(c, ";")
+		restorePrompt(c)
+		if len(text) == 0 {
+			cprintln(c, "Cancelled")
+			return "", false
+		}
+		payload = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(text), ";"))
+	}
+
+	lastPayloads[input.kind] = payload
+	if err := input.validate(payload); err != nil {
+		errorMsg(c, "%s", err.Error())
+		return "", false
+	}
+	return payload, true
+}
+
+// editorCommand returns command line of external editor, taken from VISUAL or EDITOR environment variables
+func editorCommand() string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := os.Getenv(name); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// editPayload opens text in external editor through a temporary file and returns edited text
+func editPayload(text string) (string, error) {
+	file, err := ioutil.TempFile("", "shelastic-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(text)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	args, err := shlex.Split(editorCommand())
+	if err != nil || len(args) == 0 {
+		return "", fmt.Errorf("Invalid editor command '%s'", editorCommand())
+	}
+	command := exec.Command(args[0], append(args[1:], file.Name())...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+	if err := command.Run(); err != nil {
+		return "", fmt.Errorf("%s: %s", args[0], err.Error())
+	}
+
+	edited, err := ioutil.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return string(edited), nil
+}
+
+// validateQuery checks that query is a JSON object. Empty query is replaced with match_all query by the commands
+func validateQuery(query string) error {
+	if query == "" {
+		return nil
+	}
+	return validateJSON(query)
+}
+
+// validateJSON checks that payload is a JSON object. Position of syntax error is reported as line and column
+func validateJSON(payload string) error {
+	var body map[string]interface{}
+	err := json.Unmarshal([]byte(payload), &body)
+	if err == nil {
+		return nil
+	}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		line, column := textPosition(payload, int(syntaxErr.Offset))
+		return fmt.Errorf("Invalid JSON at line %d, column %d: %s", line, column, err.Error())
+	}
+	return fmt.Errorf("Invalid JSON: %s", err.Error())
+}
+
+// validateSettings checks that settings are given one per line as name: value, where value is JSON
+func validateSettings(payload string) error {
+	values, err := parseSettings(payload)
+	if err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return fmt.Errorf("No settings to update")
+	}
+	return nil
+}
+
+// parseSettings parses index settings given one per line as name: value. Values are JSON, e.g. 1 or "1s"
+func parseSettings(payload string) (map[string]string, error) {
+	result := make(map[string]string)
+	for i, line := range strings.Split(payload, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("Invalid setting at line %d, expected name: value", i+1)
+		}
+		name, value := strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1])
+		if !json.Valid([]byte(value)) {
+			return nil, fmt.Errorf("Invalid value of %s at line %d, value must be JSON, e.g. 1 or \"1s\"", name, i+1)
+		}
+		result[name] = value
+	}
+	return result, nil
+}
+
+// textPosition converts byte offset in text to line and column, both starting with 1
+func textPosition(text string, offset int) (int, int) {
+	if offset > len(text) {
+		offset = len(text)
+	}
+	before := text[:offset]
+	line := strings.Count(before, "\n") + 1
+	column := offset - strings.LastIndex(before, "\n")
+	return line, column
+}
@@ -0,0 +1,183 @@
+package cmd
+
+import (
+	"encoding/json"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/fatih/color"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+// maxCellWidth limits width of table cells, longer values are truncated
+const maxCellWidth = 60
+
+var (
+	// emphasis matches fragments highlighted by Elasticsearch with default tags
+	emphasis = regexp.MustCompile(`<em>(.*?)</em>`)
+	mark     = color.New(color.FgHiYellow, color.Bold, color.Underline).SprintFunc()
+)
+
+// printHitsTable prints hits as a table with _id, _score and a column per _source field. If fields are given,
+// only matching columns are shown in the given order. Highlighted fragments replace values of their fields
+func printHitsTable(c *ishell.Context, hits []map[string]interface{}, fields []string) {
+	if len(hits) == 0 {
+		return
+	}
+	records := make([]map[string]string, len(hits))
+	highlights := make([]map[string]string, len(hits))
+	var names []string
+	seen := make(map[string]bool)
+	for i, hit := range hits {
+		records[i] = make(map[string]string)
+		for _, f := range flattenSource(hit["_source"]) {
+			if !seen[f.name] {
+				seen[f.name] = true
+				names = append(names, f.name)
+			}
+			records[i][f.name] = f.value
+		}
+		highlights[i] = make(map[string]string)
+		highlight, _ := hit["highlight"].(map[string]interface{})
+		for name, fragments := range highlight {
+			highlights[i][name] = joinFragments(fragments)
+		}
+	}
+	if len(fields) > 0 {
+		names = selectColumns(names, fields)
+	}
+	shown := make(map[string]bool)
+	for _, name := range names {
+		shown[name] = true
+	}
+	for _, hl := range highlights {
+		for _, name := range sortedKeys(hl) {
+			if !shown[name] {
+				shown[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+
+	columns := append([]string{"_id", "_score"}, names...)
+	rows := make([][]string, len(hits))
+	// tagged contains highlighted cells with highlight tags by row and column, cell text itself has no tags
+	tagged := make([]map[int]string, len(hits))
+	for i, hit := range hits {
+		row := []string{scalarString(hit["_id"]), scalarString(hit["_score"])}
+		tagged[i] = make(map[int]string)
+		for j, name := range names {
+			if fragment, ok := highlights[i][name]; ok && color.NoColor {
+				row = append(row, fragment)
+			} else if ok {
+				tagged[i][j+2] = fragment
+				row = append(row, emphasis.ReplaceAllString(fragment, "$1"))
+			} else {
+				row = append(row, truncate(records[i][name], maxCellWidth))
+			}
+		}
+		rows[i] = row
+	}
+	printTable(c, columns, rows, func(row int, column int, value string) string {
+		if fragment, ok := tagged[row][column]; ok {
+			return highlightFragment(fragment, func(s string) string { return s })
+		}
+		return value
+	})
+}
+
+// printHighlight prints highlighted fragments of a hit under its YAML representation
+func printHighlight(c *ishell.Context, highlight map[string]interface{}) {
+	cprintln(c, "highlight:")
+	for _, name := range sortedKeys(highlight) {
+		text := highlightFragment(joinFragments(highlight[name]), func(s string) string { return bl("%s", s) })
+		printMessage(c, bl("  %s: ", name)+text+"\n")
+	}
+	cprintln(c, "")
+}
+
+// highlightFragment colors text highlighted by Elasticsearch, the rest of the text is formatted with plain.
+// Without colors highlight tags are left in place
+func highlightFragment(fragment string, plain func(string) string) string {
+	if color.NoColor {
+		return fragment
+	}
+	var result strings.Builder
+	last := 0
+	for _, match := range emphasis.FindAllStringSubmatchIndex(fragment, -1) {
+		if match[0] > last {
+			result.WriteString(plain(fragment[last:match[0]]))
+		}
+		result.WriteString(mark(fragment[match[2]:match[3]]))
+		last = match[1]
+	}
+	if last < len(fragment) {
+		result.WriteString(plain(fragment[last:]))
+	}
+	return result.String()
+}
+
+func joinFragments(fragments interface{}) string {
+	list, _ := fragments.([]interface{})
+	texts := make([]string, len(list))
+	for i, fragment := range list {
+		texts[i] = strings.Join(strings.Fields(scalarString(fragment)), " ")
+	}
+	return strings.Join(texts, " ... ")
+}
+
+// flattenSource lists fields of the document source with dotted names, in alphabetical order
+func flattenSource(source interface{}) []field {
+	if source == nil {
+		return nil
+	}
+	data, err := json.Marshal(source)
+	if err != nil {
+		return nil
+	}
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	value, err := orderedValue(decoder)
+	if err != nil {
+		return nil
+	}
+	return flatten("", value)
+}
+
+// selectColumns returns names matching any of the fields in order of the fields. Field matches its own name,
+// names of its inner fields, or names matching it as a glob pattern
+func selectColumns(names []string, fields []string) []string {
+	var result []string
+	selected := make(map[string]bool)
+	for _, f := range fields {
+		for _, name := range names {
+			matched, _ := path.Match(f, name)
+			if !selected[name] && (matched || name == f || strings.HasPrefix(name, f+".")) {
+				selected[name] = true
+				result = append(result, name)
+			}
+		}
+	}
+	return result
+}
+
+// truncate shortens text longer than width, adding ellipsis
+func truncate(text string, width int) string {
+	runes := []rune(text)
+	if len(runes) <= width {
+		return text
+	}
+	return string(runes[:width-3]) + "..."
+}
+
+// withoutField returns copy of the hit without given field
+func withoutField(hit map[string]interface{}, name string) map[string]interface{} {
+	result := make(map[string]interface{}, len(hit))
+	for k, v := range hit {
+		if k != name {
+			result[k] = v
+		}
+	}
+	return result
+}
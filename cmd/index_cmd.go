@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"strings"
-
 	ishell "gopkg.in/abiosoft/ishell.v2"
 )
 
@@ -72,7 +70,7 @@ func Index() *ishell.Cmd {
 
 	index.AddCmd(&ishell.Cmd{
 		Name: "configure",
-		Help: "Set index' setting",
+		Help: "Set index' setting. Usage: configure [--index <index-name>] [--editor]",
 		Func: configureIndex,
 	})
 
@@ -115,7 +113,13 @@ func Index() *ishell.Cmd {
 	index.AddCmd(&ishell.Cmd{
 		Name: "close",
 		Help: "Closes previously open index. Usage: close [--index <index-name>]",
-		Func: openIndex,
+		Func: closeIndex,
+	})
+
+	index.AddCmd(&ishell.Cmd{
+		Name: "lifecycle",
+		Help: "Explains lifecycle state of the index using ILM (Elasticsearch) or ISM (OpenSearch). Usage: lifecycle [--index] [<index-name>]",
+		Func: indexLifecycle,
 	})
 
 	index.AddCmd(&ishell.Cmd{
@@ -131,7 +135,7 @@ func singleIndexOp(c *ishell.Context, op func(string) error) {
 	if context != nil {
 		selector, err := parseDocumentArgs(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		var indexName string
@@ -146,7 +150,7 @@ func singleIndexOp(c *ishell.Context, op func(string) error) {
 		}
 		err = op(indexName)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -173,7 +177,7 @@ func flush(c *ishell.Context) {
 
 		slct, err := parseDocumentArgsCustom(c.Args, &flushArgs{})
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		selector := slct.(*flushArgs)
@@ -184,7 +188,7 @@ func flush(c *ishell.Context) {
 		}
 		err = context.Flush(selector.Index, selector.Force, selector.Wait)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -197,7 +201,7 @@ func clearCache(c *ishell.Context) {
 	if context != nil {
 		selector, err := parseDocumentArgs(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		if selector.Index == "" {
@@ -207,7 +211,7 @@ func clearCache(c *ishell.Context) {
 		}
 		err = context.ClearCache(selector.Index)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -220,7 +224,7 @@ func refresh(c *ishell.Context) {
 	if context != nil {
 		selector, err := parseDocumentArgs(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		if selector.Index == "" {
@@ -230,7 +234,7 @@ func refresh(c *ishell.Context) {
 		}
 		err = context.Refresh(selector.Index)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -243,7 +247,7 @@ func forceMerge(c *ishell.Context) {
 	if context != nil {
 		selector, err := parseDocumentArgs(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		if selector.Index == "" {
@@ -253,7 +257,7 @@ func forceMerge(c *ishell.Context) {
 		}
 		err = context.ForceMerge(selector.Index)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -264,7 +268,7 @@ func forceMerge(c *ishell.Context) {
 func selectIndex(c *ishell.Context) string {
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return ""
 	}
 	var index string
@@ -283,42 +287,31 @@ func selectIndex(c *ishell.Context) string {
 
 func configureIndex(c *ishell.Context) {
 	if context != nil {
-
-		selector, err := parseDocumentArgs(c.Args)
+		type configureArgs struct {
+			documentSelectorData
+			Editor bool `long:"editor" description:"Edit settings in external editor"`
+		}
+		slctr, err := parseDocumentArgsCustom(c.Args, &configureArgs{})
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
+		selector := slctr.(*configureArgs)
 		if selector.Index == "" {
 			errorMsg(c, errIndexNotSelected)
 			return
 		}
 
-		var payload map[string]string
-
-		payload = make(map[string]string)
-		cprintlist(c, "Enter configuration parameters, one per line. Finish with ", cyb(";"))
-		c.SetPrompt(">>> ")
-		defer restorePrompt(c)
-		settings := c.ReadMultiLines(";")
-		lastSemicolon := strings.LastIndex(settings, ";")
-		if len(settings) == 0 || lastSemicolon < 0 {
-			cprintln(c, "Cancelled")
+		text, ok := readPayload(c, settingsInput, selector.Editor)
+		if !ok {
 			return
 		}
-		settings = settings[:lastSemicolon]
-		lines := strings.Split(settings, "\n")
-		for _, ln := range lines {
-			ln = strings.TrimSpace(ln)
-			kv := strings.Split(ln, ":")
-			if len(kv) == 2 {
-				payload[strings.TrimSpace(kv[0])] = kv[1]
-			}
-		}
+		// settings are validated by readPayload
+		payload, _ := parseSettings(text)
 
 		err = context.IndexConfigure(selector.Index, payload)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -356,7 +349,7 @@ func restrictIndex(c *ishell.Context) {
 	err = context.MoveAllShardsToNode(selector.Index, "_"+mode, route)
 
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
 		if route == "" {
 			cprintln(c, "Restrictions removed")
@@ -388,7 +381,7 @@ func truncateIndex(c *ishell.Context) {
 	err = context.TruncateIndex(selector.Index)
 
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
 		cprintln(c, "Ok")
 	}
@@ -416,9 +409,10 @@ func deleteIndex(c *ishell.Context) {
 	err = context.DeleteIndex(selector.Index)
 
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
 		context.ActiveIndex = ""
+		metadata.refresh(metadataIndices)
 		cprintln(c, "Ok")
 		restorePrompt(c)
 	}
@@ -439,7 +433,7 @@ func aliasOperation(c *ishell.Context, aliasFunc func(string, string) error) {
 	}
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	if selector.Index == "" {
@@ -452,8 +446,9 @@ func aliasOperation(c *ishell.Context, aliasFunc func(string, string) error) {
 	}
 	err = aliasFunc(selector.Index, selector.Args[0])
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
+		metadata.refresh(metadataIndices)
 		cprintln(c, "Ok")
 	}
 }
@@ -470,7 +465,7 @@ func copyIndex(c *ishell.Context) {
 
 	slct, err := parseDocumentArgsCustom(c.Args, &reindexArgs{})
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	selector := slct.(*reindexArgs)
@@ -480,6 +475,7 @@ func copyIndex(c *ishell.Context) {
 	}
 	err = context.CopyIndex(selector.Index, selector.Target)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	}
+	metadata.refresh(metadataIndices)
 }
@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"shelastic/es"
+	"sort"
+	"strings"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+const listIndicesUsage = "Usage: list indices [--sort <column>] [--reverse] [--health green|yellow|red] [--columns <column,...>] [pattern...]"
+
+var (
+	indexColumns        = []string{"health", "status", "index", "uuid", "pri", "rep", "docs", "deleted", "pri_size", "size", "created", "aliases"}
+	defaultIndexColumns = []string{"health", "status", "index", "pri", "rep", "docs", "deleted", "pri_size", "size", "created", "aliases"}
+)
+
+func listIndices(c *ishell.Context) {
+	if context == nil {
+		errorMsg(c, errNotConnected)
+		return
+	}
+	var args struct {
+		Sort    string `long:"sort" description:"Column to sort by" default:"index"`
+		Reverse bool   `short:"r" long:"reverse" description:"Sort in descending order"`
+		Health  string `long:"health" description:"Show only indices with given health" choice:"green" choice:"yellow" choice:"red"`
+		Columns string `long:"columns" description:"Comma-separated list of columns to show"`
+	}
+	patterns, err := flags.ParseArgs(&args, c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	columns := defaultIndexColumns
+	if args.Columns != "" {
+		columns = strings.Split(args.Columns, ",")
+	}
+	for _, column := range append(columns, args.Sort) {
+		if !isIndexColumn(column) {
+			errorMsg(c, "Unknown column %s. Available columns: %s", column, strings.Join(indexColumns, ", "))
+			return
+		}
+	}
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errorMsg(c, "Invalid index name pattern %s", pattern)
+			return
+		}
+	}
+
+	indices, err := context.ListIndexInfo()
+	if err != nil {
+		printError(c, err, "Failed to retrieve list of indices")
+		return
+	}
+	var selected []*es.IndexInfo
+	for _, info := range indices {
+		if (args.Health == "" || info.Health == args.Health) && matchesAny(info.Name, patterns) {
+			selected = append(selected, info)
+		}
+	}
+	sort.SliceStable(selected, func(i, j int) bool {
+		a, _ := indexColumn(selected[i], args.Sort)
+		b, _ := indexColumn(selected[j], args.Sort)
+		if args.Reverse {
+			return lessValue(b, a)
+		}
+		return lessValue(a, b)
+	})
+
+	rows := make([]tableRow, len(selected))
+	text := make([][]string, len(selected))
+	for i, info := range selected {
+		rows[i] = tableRow{columns: columns, values: make([]interface{}, len(columns))}
+		text[i] = make([]string, len(columns))
+		for j, column := range columns {
+			rows[i].values[j], text[i][j] = indexColumn(info, column)
+		}
+	}
+	render(c, rows, func() {
+		if len(selected) == 0 {
+			cprintln(c, "No indices found")
+			return
+		}
+		printTable(c, columns, text, func(row int, column int, value string) string {
+			if columns[column] == "health" {
+				return healthColor(value)
+			}
+			return bl("%s", value)
+		})
+	})
+}
+
+func isIndexColumn(column string) bool {
+	for _, c := range indexColumns {
+		if c == column {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesAny checks if name matches any of glob patterns. Any name matches empty list of patterns
+func matchesAny(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// indexColumn returns value of index column for structured output and its text representation.
+// Document counts and sizes of closed indices are unknown and returned as nil
+func indexColumn(info *es.IndexInfo, column string) (interface{}, string) {
+	switch column {
+	case "health":
+		return info.Health, info.Health
+	case "status":
+		return info.Status, info.Status
+	case "index":
+		return info.Name, info.Name
+	case "uuid":
+		return info.UUID, info.UUID
+	case "pri":
+		return info.Shards, fmt.Sprint(info.Shards)
+	case "rep":
+		return info.Replicas, fmt.Sprint(info.Replicas)
+	case "aliases":
+		return info.Aliases, strings.Join(info.Aliases, ",")
+	case "created":
+		if info.CreationDate.IsZero() {
+			return nil, ""
+		}
+		return info.CreationDate.UTC().Format(time.RFC3339), info.CreationDate.Format("2006-01-02 15:04:05")
+	}
+	if info.Closed() {
+		return nil, ""
+	}
+	switch column {
+	case "docs":
+		return info.DocumentCount, fmt.Sprint(info.DocumentCount)
+	case "deleted":
+		return info.DeletedCount, fmt.Sprint(info.DeletedCount)
+	case "pri_size":
+		return info.PrimarySize, humanSize(info.PrimarySize)
+	case "size":
+		return info.TotalSize, humanSize(info.TotalSize)
+	}
+	return nil, ""
+}
+
+// lessValue compares column values. Unknown values are less than any other value
+func lessValue(a interface{}, b interface{}) bool {
+	if a == nil || b == nil {
+		return a == nil && b != nil
+	}
+	switch av := a.(type) {
+	case int:
+		return av < b.(int)
+	case int64:
+		return av < b.(int64)
+	case []string:
+		return strings.Join(av, ",") < strings.Join(b.([]string), ",")
+	}
+	return fmt.Sprint(a) < fmt.Sprint(b)
+}
+
+func healthColor(health string) string {
+	switch health {
+	case "green":
+		return gre(health)
+	case "yellow":
+		return yel(health)
+	case "red":
+		return red(health)
+	}
+	return health
+}
+
+// humanSize formats size in bytes using the largest unit which keeps value at least 1, as in 12.3mb
+func humanSize(size int64) string {
+	units := []string{"b", "kb", "mb", "gb", "tb", "pb"}
+	value := float64(size)
+	unit := 0
+	for value >= 1024 && unit < len(units)-1 {
+		value /= 1024
+		unit++
+	}
+	if unit == 0 {
+		return fmt.Sprintf("%d%s", size, units[unit])
+	}
+	return fmt.Sprintf("%.1f%s", value, units[unit])
+}
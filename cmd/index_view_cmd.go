@@ -2,7 +2,6 @@ package cmd
 
 import (
 	"shelastic/es"
-	"shelastic/utils"
 
 	ishell "gopkg.in/abiosoft/ishell.v2"
 )
@@ -12,7 +11,7 @@ func viewIndexMapping(c *ishell.Context) {
 
 		selector, err := parseDocumentArgs(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		if selector.Index == "" {
@@ -26,15 +25,10 @@ func viewIndexMapping(c *ishell.Context) {
 
 		result, err := context.IndexViewMapping(selector.Index, selector.Document, property)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
-		mappings, err := utils.MapToYaml(result)
-		if err != nil {
-			errorMsg(c, err.Error())
-		} else {
-			cprintln(c, mappings)
-		}
+		renderYAML(c, result)
 
 	} else {
 		errorMsg(c, errNotConnected)
@@ -51,15 +45,9 @@ func viewIndexSettings(c *ishell.Context) {
 
 		result, err := context.IndexViewSettings(index)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
-
-			text, err := utils.MapToYaml(result)
-			if err != nil {
-				errorMsg(c, err.Error())
-			} else {
-				cprintln(c, text)
-			}
+			renderYAML(c, result)
 		}
 
 	} else {
@@ -77,7 +65,7 @@ func viewIndexShards(c *ishell.Context) {
 
 		sel, err := parseDocumentArgsCustom(c.Args, &shardsArgs{})
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		selector := sel.(*shardsArgs)
@@ -89,13 +77,26 @@ func viewIndexShards(c *ishell.Context) {
 
 		result, err := context.IndexShards(selector.Index)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
-			if selector.Mode == "by-node" {
-				printIndexShardsByNode(c, result)
-			} else {
-				printIndexShardsByShard(c, result)
+			var shards []indexShardOutput
+			for _, indexShard := range result {
+				for _, shardInfo := range indexShard.Shards {
+					shards = append(shards, indexShardOutput{
+						Shard:   indexShard.ID,
+						Primary: shardInfo.Routing.Primary,
+						State:   shardInfo.Routing.State,
+						Node:    shardInfo.Node.Name,
+					})
+				}
 			}
+			render(c, shards, func() {
+				if selector.Mode == "by-node" {
+					printIndexShardsByNode(c, result)
+				} else {
+					printIndexShardsByShard(c, result)
+				}
+			})
 		}
 
 	} else {
@@ -103,6 +104,14 @@ func viewIndexShards(c *ishell.Context) {
 	}
 }
 
+// indexShardOutput is a copy of index shard, as it is shown in structured output
+type indexShardOutput struct {
+	Shard   int    `json:"shard"`
+	Primary bool   `json:"primary"`
+	State   string `json:"state"`
+	Node    string `json:"node"`
+}
+
 func viewIndexSegments(c *ishell.Context) {
 	if context == nil {
 		errorMsg(c, errNotConnected)
@@ -110,7 +119,7 @@ func viewIndexSegments(c *ishell.Context) {
 
 	selector, err := parseDocumentArgs(c.Args)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 
@@ -121,13 +130,9 @@ func viewIndexSegments(c *ishell.Context) {
 
 	result, err := context.IndexShards(selector.Index)
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 	} else {
-		yamlstr, err := utils.MapToYaml(result)
-		if err != nil {
-			errorMsg(c, err.Error())
-		}
-		cprintln(c, yamlstr)
+		renderYAML(c, result)
 	}
 
 }
@@ -183,3 +188,23 @@ func printIndexShardsByShard(c *ishell.Context, indexShards es.IndexShards) {
 		}
 	}
 }
+
+func indexLifecycle(c *ishell.Context) {
+	if context != nil {
+
+		index := selectIndex(c)
+		if index == "" {
+			return
+		}
+
+		result, err := context.IndexLifecycle(index)
+		if err != nil {
+			printError(c, err)
+		} else {
+			renderYAML(c, result)
+		}
+
+	} else {
+		errorMsg(c, errNotConnected)
+	}
+}
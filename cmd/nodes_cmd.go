@@ -48,11 +48,11 @@ func getNodeStats(c *ishell.Context) {
 	if context != nil {
 		nodeStats, err := context.GetNodeStats(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		var nodeName string
@@ -64,14 +64,22 @@ func getNodeStats(c *ishell.Context) {
 		if nodeName != "" {
 			for node := range nodeStats.Nodes {
 				if node == nodeName {
-					cprintln(c, undr(node+":"))
-					cprintln(c, nodeStatsToString(nodeStats.Nodes[node]))
+					render(c, []nodeStatsOutput{{node, nodeStats.Nodes[node]}}, func() {
+						cprintln(c, undr(node+":"))
+						cprintln(c, nodeStatsToString(nodeStats.Nodes[node]))
+					})
 					return
 				}
 			}
 			errorMsg(c, "Node '%s' not found", nodeName)
 		} else {
-			print(c, nodeStats)
+			var result []nodeStatsOutput
+			for _, node := range sortedKeys(nodeStats.Nodes) {
+				result = append(result, nodeStatsOutput{node, nodeStats.Nodes[node]})
+			}
+			render(c, result, func() {
+				print(c, nodeStats)
+			})
 		}
 	} else {
 		errorMsg(c, errNotConnected)
@@ -82,11 +90,11 @@ func getEnvironmentStats(c *ishell.Context) {
 	if context != nil {
 		nodeStats, err := context.GetNodeEnvironmentInfo(c.Args)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
 		var nodeName string
@@ -98,14 +106,22 @@ func getEnvironmentStats(c *ishell.Context) {
 		if nodeName != "" {
 			for node := range nodeStats.Nodes {
 				if node == nodeName {
-					cprintln(c, undr(node+":"))
-					cprintln(c, environmentToString(nodeStats.Nodes[node]))
+					render(c, []nodeEnvironmentOutput{{node, nodeStats.Nodes[node]}}, func() {
+						cprintln(c, undr(node+":"))
+						cprintln(c, environmentToString(nodeStats.Nodes[node]))
+					})
 					return
 				}
 			}
 			errorMsg(c, "Node '%s' not found", nodeName)
 		} else {
-			printEnvironment(c, nodeStats.Nodes)
+			var result []nodeEnvironmentOutput
+			for _, node := range sortedKeys(nodeStats.Nodes) {
+				result = append(result, nodeEnvironmentOutput{node, nodeStats.Nodes[node]})
+			}
+			render(c, result, func() {
+				printEnvironment(c, nodeStats.Nodes)
+			})
 		}
 	} else {
 		errorMsg(c, errNotConnected)
@@ -130,49 +146,73 @@ func nodeShards(c *ishell.Context) {
 	} else {
 		nodes = []string{c.Args[0]}
 	}
-	indices, err := context.ListIndices()
+	allShards, err := context.AllIndexShards()
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 
-	for _, node := range nodes {
-		nodeIndexMap, err := buildNodeIndexInfo(node, indices)
-		if err != nil {
-			errorMsg(c, err.Error())
-			return
-		}
-		cprintf(c, undr(node))
-		cprintln(c, ":")
-		for index := range nodeIndexMap {
-			cprintln(c, "  Index '%s':", index)
+	nodeIndexMaps := make([]map[string][]shard, len(nodes))
+	var result []nodeShardOutput
+	for i, node := range nodes {
+		nodeIndexMap := buildNodeIndexInfo(node, allShards)
+		nodeIndexMaps[i] = nodeIndexMap
+		for _, index := range sortedKeys(nodeIndexMap) {
 			for _, shard := range nodeIndexMap[index] {
-				var prim string
-				if shard.Primary {
-					prim = "Primary"
-				} else {
-					prim = "Replica"
-				}
-				cprintln(c, "    %d: %s, %s", shard.ID, shard.State, prim)
+				result = append(result, nodeShardOutput{Node: node, Index: index, shard: shard})
 			}
 		}
 	}
 
+	render(c, result, func() {
+		for i, node := range nodes {
+			cprintf(c, undr(node))
+			cprintln(c, ":")
+			for index := range nodeIndexMaps[i] {
+				cprintln(c, "  Index '%s':", index)
+				for _, shard := range nodeIndexMaps[i][index] {
+					var prim string
+					if shard.Primary {
+						prim = "Primary"
+					} else {
+						prim = "Replica"
+					}
+					cprintln(c, "    %d: %s, %s", shard.ID, shard.State, prim)
+				}
+			}
+		}
+	})
 }
 
 type shard struct {
-	ID      int
-	Primary bool
-	State   string
+	ID      int    `json:"shard"`
+	Primary bool   `json:"primary"`
+	State   string `json:"state"`
 }
 
-func buildNodeIndexInfo(node string, indices []*es.ShortIndexInfo) (map[string][]shard, error) {
+// nodeShardOutput is a shard allocated to a node, as it is shown in structured output
+type nodeShardOutput struct {
+	Node  string `json:"node"`
+	Index string `json:"index"`
+	shard
+}
+
+// nodeStatsOutput is node statistics with node id, as it is shown in structured output
+type nodeStatsOutput struct {
+	ID string `json:"id"`
+	es.NodeStats
+}
+
+// nodeEnvironmentOutput is node environment with node id, as it is shown in structured output
+type nodeEnvironmentOutput struct {
+	ID string `json:"id"`
+	es.NodeEnvironmentInfo
+}
+
+// buildNodeIndexInfo selects shards allocated to the node from shards of all indices, grouped by index name
+func buildNodeIndexInfo(node string, allShards map[string]es.IndexShards) map[string][]shard {
 	nodeIndexInfo := make(map[string][]shard)
-	for _, idx := range indices {
-		shards, err := context.IndexShards(idx.Name)
-		if err != nil {
-			return nil, err
-		}
+	for index, shards := range allShards {
 		var nodeShards []shard
 		for _, sh := range shards {
 			for _, is := range sh.Shards {
@@ -187,10 +227,10 @@ func buildNodeIndexInfo(node string, indices []*es.ShortIndexInfo) (map[string][
 			}
 		}
 		if len(nodeShards) > 0 {
-			nodeIndexInfo[idx.Name] = nodeShards
+			nodeIndexInfo[index] = nodeShards
 		}
 	}
-	return nodeIndexInfo, nil
+	return nodeIndexInfo
 }
 
 func decomissionNodes(c *ishell.Context) {
@@ -207,7 +247,7 @@ func decomissionNodes(c *ishell.Context) {
 
 	slct, err := parseDocumentArgsCustom(c.Args, &decomissionArgs{})
 	if err != nil {
-		errorMsg(c, err.Error())
+		printError(c, err)
 		return
 	}
 	selector := slct.(*decomissionArgs)
@@ -215,39 +255,19 @@ func decomissionNodes(c *ishell.Context) {
 	if len(selector.Mode) < 1 {
 		settings, err := context.GetSettings()
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 			return
 		}
-		cprintlist(c, "Existing transient routing allocations:")
-
-		transient, ok := settings["transient"].(map[string]interface{})
-		if !ok {
-			cprintln(c, "None")
-			return
-		}
-		cluster, ok := transient["cluster"].(map[string]interface{})
-		if !ok {
-			cprintln(c, "None")
-			return
-		}
-		routing, ok := cluster["routing"].(map[string]interface{})
-		if !ok {
-			cprintln(c, "None")
-			return
-		}
-		allocation, ok := routing["allocation"].(map[string]interface{})
-		if !ok {
-			cprintln(c, "None")
-			return
-		}
-		excludes, ok := allocation["exclude"].(map[string]interface{})
-		if !ok {
-			cprintln(c, "None")
-		} else {
+		excludes := transientExcludes(settings)
+		render(c, excludes, func() {
+			cprintlist(c, "Existing transient routing allocations:")
+			if len(excludes) == 0 {
+				cprintln(c, "None")
+			}
 			for key, value := range excludes {
-				cprintlist(c, key, ": ", hbl(value.(string)))
+				cprintlist(c, key, ": ", hbl(fmt.Sprint(value)))
 			}
-		}
+		})
 	} else {
 		var nodes string
 		if selector.Clear {
@@ -262,13 +282,26 @@ func decomissionNodes(c *ishell.Context) {
 
 		err = context.DecomissionNode(selector.Mode, nodes)
 		if err != nil {
-			errorMsg(c, "Failed to modify cluster allocation: "+err.Error())
+			printError(c, err, "Failed to modify cluster allocation")
 		} else {
 			cprintln(c, "Ok")
 		}
 	}
 }
 
+// transientExcludes returns transient cluster.routing.allocation.exclude settings. Returns empty map if there are none
+func transientExcludes(settings map[string]interface{}) map[string]interface{} {
+	current := settings
+	for _, key := range []string{"transient", "cluster", "routing", "allocation", "exclude"} {
+		next, ok := current[key].(map[string]interface{})
+		if !ok {
+			return map[string]interface{}{}
+		}
+		current = next
+	}
+	return current
+}
+
 func filterSettings(settings map[string]interface{}, prefix string) []string {
 	result := make([]string, 0)
 	for key := range settings {
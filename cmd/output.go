@@ -0,0 +1,372 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"shelastic/utils"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	ishell "gopkg.in/abiosoft/ishell.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+// Output formats of command results
+const (
+	outputText  = "text"
+	outputJSON  = "json"
+	outputYAML  = "yaml"
+	outputTable = "table"
+	outputCSV   = "csv"
+)
+
+var outputFormats = []string{outputText, outputJSON, outputYAML, outputTable, outputCSV}
+
+// tabular is implemented by command results which are shown in table and CSV formats differently than in JSON and
+// YAML formats. For example, search result is shown as a table of hits
+type tabular interface {
+	rows() interface{}
+}
+
+// tableRow is a row of a table with selected columns. Row is serialized as JSON object with fields in column order
+type tableRow struct {
+	columns []string
+	values  []interface{}
+}
+
+func (r tableRow) MarshalJSON() ([]byte, error) {
+	var buffer bytes.Buffer
+	buffer.WriteString("{")
+	for i, column := range r.columns {
+		if i > 0 {
+			buffer.WriteString(",")
+		}
+		key, err := json.Marshal(column)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(r.values[i])
+		if err != nil {
+			return nil, err
+		}
+		buffer.Write(key)
+		buffer.WriteString(":")
+		buffer.Write(value)
+	}
+	buffer.WriteString("}")
+	return buffer.Bytes(), nil
+}
+
+// Set is a parent for shell settings commands
+func Set() *ishell.Cmd {
+	set := &ishell.Cmd{
+		Name: "set",
+		Help: "Change shell settings",
+	}
+
+	set.AddCmd(&ishell.Cmd{
+		Name: "output",
+		Help: "Select output format of command results. Usage: set output [text|json|yaml|table|csv]",
+		Func: setOutput,
+	})
+
+	set.AddCmd(&ishell.Cmd{
+		Name: "editor",
+		Help: "Edit queries, documents and index settings in $EDITOR instead of the prompt. Usage: set editor [on|off]",
+		Func: setEditor,
+	})
+
+	return set
+}
+
+func setOutput(c *ishell.Context) {
+	if len(c.Args) < 1 {
+		cprintlist(c, "Output format: ", cyb(outputFormat()))
+		return
+	}
+	for _, format := range outputFormats {
+		if format == c.Args[0] {
+			settings.Output = format
+			cprintlist(c, "Output format: ", cyb(format))
+			return
+		}
+	}
+	errorMsg(c, "Unknown output format %s. Supported formats: %s", c.Args[0], strings.Join(outputFormats, ", "))
+}
+
+func outputFormat() string {
+	if settings.Output == "" {
+		return outputText
+	}
+	return settings.Output
+}
+
+// structuredOutput checks if command results are printed in one of machine-readable formats
+func structuredOutput() bool {
+	return outputFormat() != outputText
+}
+
+// printMessage prints informational message. With structured output messages are written to standard error,
+// so that standard output contains only command results
+func printMessage(c *ishell.Context, text string) {
+	if structuredOutput() {
+		fmt.Fprint(os.Stderr, text)
+	} else {
+		c.Print(text)
+	}
+}
+
+// renderYAML prints command result which is shown as YAML in text format
+func renderYAML(c *ishell.Context, data interface{}) {
+	render(c, data, func() {
+		text, err := utils.MapToYaml(data)
+		if err != nil {
+			printError(c, err)
+			return
+		}
+		cprintln(c, text)
+	})
+}
+
+// render prints command result in selected output format. In text format the result is printed by text function,
+// other formats are produced from JSON representation of data
+func render(c *ishell.Context, data interface{}, text func()) {
+	if !structuredOutput() {
+		text()
+		return
+	}
+	out, err := formatOutput(outputFormat(), data)
+	if err != nil {
+		printError(c, err, "Failed to format output")
+		return
+	}
+	c.Print(out)
+}
+
+// printTable prints column-aligned table in text format. Padding is added after colorize is applied to the cell,
+// so that color codes do not break alignment
+func printTable(c *ishell.Context, columns []string, rows [][]string, colorize func(row int, column int, value string) string) {
+	widths := make([]int, len(columns))
+	for i, column := range columns {
+		widths[i] = len(column)
+	}
+	for _, row := range rows {
+		for i, value := range row {
+			if len(value) > widths[i] {
+				widths[i] = len(value)
+			}
+		}
+	}
+	padding := func(i int, value string) string {
+		if i == len(columns)-1 {
+			return ""
+		}
+		return strings.Repeat(" ", widths[i]-len(value)+2)
+	}
+	var header strings.Builder
+	for i, column := range columns {
+		header.WriteString(hbl("%s", column) + padding(i, column))
+	}
+	printMessage(c, header.String()+"\n")
+	for r, row := range rows {
+		var line strings.Builder
+		for i, value := range row {
+			line.WriteString(colorize(r, i, value) + padding(i, value))
+		}
+		printMessage(c, line.String()+"\n")
+	}
+}
+
+func formatOutput(format string, data interface{}) (string, error) {
+	if t, ok := data.(tabular); ok && (format == outputTable || format == outputCSV) {
+		data = t.rows()
+	}
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	if format == outputJSON {
+		encoder.SetIndent("", "  ")
+	}
+	if err := encoder.Encode(data); err != nil {
+		return "", err
+	}
+	if format == outputJSON {
+		return buffer.String(), nil
+	}
+
+	decoder := json.NewDecoder(&buffer)
+	decoder.UseNumber()
+	value, err := orderedValue(decoder)
+	if err != nil {
+		return "", err
+	}
+	if format == outputYAML {
+		text, err := yaml.Marshal(value)
+		return string(text), err
+	}
+
+	columns, rows := tableOf(value)
+	if len(columns) == 0 {
+		// empty list has no columns
+		return "", nil
+	}
+	switch format {
+	case outputCSV:
+		var out bytes.Buffer
+		writer := csv.NewWriter(&out)
+		writer.Write(columns)
+		writer.WriteAll(rows)
+		return out.String(), writer.Error()
+	default:
+		var out bytes.Buffer
+		writer := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(writer, strings.Join(columns, "\t"))
+		for _, row := range rows {
+			fmt.Fprintln(writer, strings.Join(row, "\t"))
+		}
+		err := writer.Flush()
+		return out.String(), err
+	}
+}
+
+// orderedValue decodes JSON value keeping order of object fields. Objects are decoded as yaml.MapSlice,
+// arrays as []interface{}, numbers as int64 or float64
+func orderedValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch token {
+	case json.Delim('{'):
+		result := yaml.MapSlice{}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := orderedValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, yaml.MapItem{Key: key, Value: value})
+		}
+		_, err := decoder.Token()
+		return result, err
+	case json.Delim('['):
+		result := []interface{}{}
+		for decoder.More() {
+			value, err := orderedValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, value)
+		}
+		_, err := decoder.Token()
+		return result, err
+	}
+	if number, ok := token.(json.Number); ok {
+		if i, err := number.Int64(); err == nil {
+			return i, nil
+		}
+		return number.Float64()
+	}
+	return token, nil
+}
+
+type field struct {
+	name  string
+	value string
+}
+
+// tableOf converts decoded value to table. List produces a row per item with a column per field, fields of nested
+// objects become columns with dotted names. Single object is shown as a list of fields with their values
+func tableOf(value interface{}) ([]string, [][]string) {
+	switch v := value.(type) {
+	case []interface{}:
+		var columns []string
+		positions := make(map[string]int)
+		records := make([]map[string]string, len(v))
+		for i, item := range v {
+			records[i] = make(map[string]string)
+			for _, f := range flatten("", item) {
+				name := f.name
+				if name == "" {
+					name = "value"
+				}
+				if _, ok := positions[name]; !ok {
+					positions[name] = len(columns)
+					columns = append(columns, name)
+				}
+				records[i][name] = f.value
+			}
+		}
+		rows := make([][]string, len(records))
+		for i, record := range records {
+			rows[i] = make([]string, len(columns))
+			for name, value := range record {
+				rows[i][positions[name]] = value
+			}
+		}
+		return columns, rows
+	case yaml.MapSlice:
+		var rows [][]string
+		for _, f := range flatten("", v) {
+			rows = append(rows, []string{f.name, f.value})
+		}
+		return []string{"field", "value"}, rows
+	}
+	return []string{"value"}, [][]string{{scalarString(value)}}
+}
+
+// flatten lists all scalar fields of the value. Names of nested fields are joined with dots, array items are
+// addressed by their index. Arrays of scalars are shown as comma-separated list
+func flatten(prefix string, value interface{}) []field {
+	join := func(name string) string {
+		if prefix == "" {
+			return name
+		}
+		return prefix + "." + name
+	}
+	switch v := value.(type) {
+	case yaml.MapSlice:
+		if len(v) == 0 {
+			return []field{{prefix, ""}}
+		}
+		var result []field
+		for _, item := range v {
+			result = append(result, flatten(join(fmt.Sprint(item.Key)), item.Value)...)
+		}
+		return result
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			switch item.(type) {
+			case yaml.MapSlice, []interface{}:
+				var result []field
+				for i, item := range v {
+					result = append(result, flatten(join(strconv.Itoa(i)), item)...)
+				}
+				return result
+			}
+			values[i] = scalarString(item)
+		}
+		return []field{{prefix, strings.Join(values, ",")}}
+	}
+	return []field{{prefix, scalarString(value)}}
+}
+
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return fmt.Sprint(value)
+}
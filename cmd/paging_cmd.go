@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"shelastic/es"
+	"strings"
+
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+// hitsArgs are options of commands printing search results page by page
+type hitsArgs struct {
+	From      int      `long:"from" description:"Offset of the first hit" default:"0"`
+	Size      int      `long:"size" description:"Number of hits on a page" default:"20"`
+	Fields    string   `long:"fields" description:"Comma-separated list of _source fields to return"`
+	Table     bool     `long:"table" description:"Show hits as a table with a column per field"`
+	Highlight []string `long:"highlight" description:"Highlight matches in the field, can be repeated"`
+	AggsOnly  bool     `long:"aggs-only" description:"Show only aggregations of the query, no hits are returned"`
+}
+
+// options converts command options to search parameters
+func (h hitsArgs) options() es.SearchOptions {
+	size := h.Size
+	if h.AggsOnly {
+		size = 0
+	}
+	return es.SearchOptions{From: h.From, Size: size, Fields: splitList(h.Fields), Highlight: h.highlighted()}
+}
+
+// highlighted returns fields to highlight. Fields are given with repeated options or as comma-separated list
+func (h hitsArgs) highlighted() []string {
+	var result []string
+	for _, fields := range h.Highlight {
+		result = append(result, splitList(fields)...)
+	}
+	return result
+}
+
+var (
+	// pager is the last search of pagerSession, its results are paged with next and prev commands
+	pager        *es.SearchPager
+	pagerSession string
+	// pagerView contains options of displaying hits of the last search
+	pagerView hitsArgs
+)
+
+// Next prints the next page of the last search results
+func Next() *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "next",
+		Help: "Show the next page of the last document search or query",
+		Func: func(c *ishell.Context) {
+			if !checkPager(c) {
+				return
+			}
+			if !pager.HasNext() {
+				errorMsg(c, "There are no more hits")
+				return
+			}
+			showPage(c, pager.Page+1)
+		},
+	}
+}
+
+// Prev prints the previous page of the last search results
+func Prev() *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "prev",
+		Help: "Show the previous page of the last document search or query",
+		Func: func(c *ishell.Context) {
+			if !checkPager(c) {
+				return
+			}
+			if !pager.HasPrevious() {
+				errorMsg(c, "This is the first page")
+				return
+			}
+			showPage(c, pager.Page-1)
+		},
+	}
+}
+
+// startPaging replaces pager of the last search with a new one and prints the first page
+func startPaging(c *ishell.Context, newPager *es.SearchPager, view hitsArgs) {
+	if pager != nil {
+		if conn, ok := sessions[pagerSession]; ok {
+			// point in time expires by itself, failure to release it is not reported
+			conn.ClosePager(pager)
+		}
+	}
+	pager = newPager
+	pagerSession = activeSession
+	pagerView = view
+	showPage(c, 0)
+}
+
+func checkPager(c *ishell.Context) bool {
+	if context == nil {
+		errorMsg(c, errNotConnected)
+		return false
+	}
+	if pager == nil || pagerSession != activeSession {
+		errorMsg(c, "No search results to page through. Use document search or document query first")
+		return false
+	}
+	return true
+}
+
+func showPage(c *ishell.Context, page int) {
+	sr, err := context.FetchPage(pager, page)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	printSearchResult(c, sr, pagerView)
+	if pager.HasNext() && pager.HasPrevious() {
+		cprintlist(c, "Use ", hbl("next"), " or ", hbl("prev"), " to see more")
+	} else if pager.HasNext() {
+		cprintlist(c, "Use ", hbl("next"), " to see more")
+	} else if pager.HasPrevious() {
+		cprintlist(c, "Use ", hbl("prev"), " to see previous hits")
+	}
+}
+
+// splitList splits comma-separated list, empty items are skipped
+func splitList(list string) []string {
+	var result []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
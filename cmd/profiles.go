@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	ishell "gopkg.in/abiosoft/ishell.v2"
+	yaml "gopkg.in/yaml.v2"
+)
+
+const defaultConfigFile = ".shelastic.yaml"
+
+// Profile is a named set of connection parameters stored in configuration file
+type Profile struct {
+	Hosts   []string     `yaml:"hosts"`
+	Sniff   bool         `yaml:"sniff"`
+	TLS     TLSSettings  `yaml:"tls"`
+	Auth    AuthSettings `yaml:"auth"`
+	Index   string       `yaml:"index"`
+	NoColor *bool        `yaml:"no-color"`
+}
+
+// configuration is a content of shelastic configuration file
+type configuration struct {
+	Profiles map[string]*Profile `yaml:"profiles"`
+}
+
+var (
+	config     = &configuration{}
+	configPath string
+	configErr  error
+)
+
+// loadProfiles reads connection profiles from configuration file. If fileName is empty, ~/.shelastic.yaml is used.
+// Missing default configuration file is not an error
+func loadProfiles(fileName string) {
+	explicit := fileName != ""
+	if !explicit {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return
+		}
+		fileName = filepath.Join(home, defaultConfigFile)
+	}
+	configPath = fileName
+	config = &configuration{}
+	data, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		if explicit || !os.IsNotExist(err) {
+			configErr = err
+		}
+		return
+	}
+	configErr = yaml.Unmarshal(data, config)
+}
+
+// findProfile returns profile by name or nil if there is no such profile
+func findProfile(name string) *Profile {
+	if profile, ok := config.Profiles[name]; ok {
+		return profile
+	}
+	return nil
+}
+
+// Profiles is a parent for connection profile commands
+func Profiles() *ishell.Cmd {
+	profiles := &ishell.Cmd{
+		Name: "profiles",
+		Help: "Connection profiles",
+	}
+
+	profiles.AddCmd(&ishell.Cmd{
+		Name: "list",
+		Help: "List connection profiles",
+		Func: listProfiles,
+	})
+
+	profiles.AddCmd(&ishell.Cmd{
+		Name: "reload",
+		Help: "Reloads configuration file",
+		Func: func(c *ishell.Context) {
+			loadProfiles(settings.Config)
+			listProfiles(c)
+		},
+	})
+
+	return profiles
+}
+
+func listProfiles(c *ishell.Context) {
+	if configErr != nil {
+		errorMsg(c, "Failed to read %s: %s", configPath, configErr.Error())
+		return
+	}
+	names := make([]string, 0, len(config.Profiles))
+	for name := range config.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	result := []profileOutput{}
+	for _, name := range names {
+		profile := config.Profiles[name]
+		output := profileOutput{Name: name, Hosts: profile.Hosts, User: profile.Auth.Username, Index: profile.Index}
+		if profile.Auth.Username != "" {
+			output.Auth = "basic"
+		} else if profile.Auth.APIKey != "" {
+			output.Auth = "api key"
+		} else if profile.Auth.Token != "" {
+			output.Auth = "token"
+		}
+		result = append(result, output)
+	}
+	render(c, result, func() {
+		if len(result) == 0 {
+			cprintln(c, "No profiles defined in %s", configPath)
+			return
+		}
+		for _, profile := range result {
+			details := []string{strings.Join(profile.Hosts, ",")}
+			if profile.User != "" {
+				details = append(details, "user: "+profile.User)
+			} else if profile.Auth != "" {
+				details = append(details, profile.Auth)
+			}
+			if profile.Index != "" {
+				details = append(details, "index: "+profile.Index)
+			}
+			cprintlist(c, cyb(profile.Name), " [", strings.Join(details, ", "), "]")
+		}
+	})
+}
+
+// profileOutput is a connection profile as it is shown in structured output. Credentials are never shown
+type profileOutput struct {
+	Name  string   `json:"name"`
+	Hosts []string `json:"hosts"`
+	Auth  string   `json:"auth,omitempty"`
+	User  string   `json:"user,omitempty"`
+	Index string   `json:"index,omitempty"`
+}
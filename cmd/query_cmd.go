@@ -0,0 +1,545 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	flags "github.com/jessevdk/go-flags"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+const (
+	defaultDataDir    = ".shelastic"
+	savedQueriesDir   = "queries"
+	savedQueryExt     = ".json"
+	queryHistoryFile  = "history.jsonl"
+	maxHistoryEntries = 1000
+
+	querySaveUsage    = "Usage: query save [--index <index-name>] [--doc <doc>] [--editor] [--last] <name>"
+	queryRunUsage     = "Usage: query run [--index <index-name>] [--doc <doc>] [--from <offset>] [--size <count>] [--fields <fields>] [--table] [--highlight <field>] [--aggs-only] <name|history-number> [<placeholder>=<value>...]"
+	queryShowUsage    = "Usage: query show <name|history-number>"
+	queryDeleteUsage  = "Usage: query delete <name>"
+	queryHistoryUsage = "Usage: query history [--all] [--limit <count>]"
+)
+
+var (
+	queryNamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	// placeholderPattern matches {{name}} and {{name:default}} in saved queries
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_.-]*)\s*(?::([^}]*))?\}\}`)
+
+	savedQueryInput = payloadInput{
+		kind:     queryInput.kind,
+		prompt:   "Enter query, ending with ';'. Use {{name}} or {{name:default}} for values given at run time",
+		template: queryInput.template,
+		validate: validateQueryTemplate,
+	}
+)
+
+// Query is a parent for saved query and query history commands
+func Query() *ishell.Cmd {
+	query := &ishell.Cmd{
+		Name: "query",
+		Help: "Saved queries and query history",
+	}
+
+	query.AddCmd(&ishell.Cmd{
+		Name: "save",
+		Help: "Save query under a name. Query is entered at the prompt or in editor, --last saves the last entered query. " + querySaveUsage,
+		Func: saveQuery,
+	})
+
+	query.AddCmd(&ishell.Cmd{
+		Name: "list",
+		Help: "List saved queries",
+		Func: listQueries,
+	})
+
+	query.AddCmd(&ishell.Cmd{
+		Name: "show",
+		Help: "Show saved query or query from history. " + queryShowUsage,
+		Func: showQuery,
+	})
+
+	query.AddCmd(&ishell.Cmd{
+		Name: "run",
+		Help: "Run saved query or query from history, filling in placeholders. " + queryRunUsage,
+		Func: runSavedQuery,
+	})
+
+	query.AddCmd(&ishell.Cmd{
+		Name: "delete",
+		Help: "Delete saved query. " + queryDeleteUsage,
+		Func: deleteQuery,
+	})
+
+	query.AddCmd(&ishell.Cmd{
+		Name: "history",
+		Help: "List queries executed on the cluster in use, --all shows queries of all clusters. " + queryHistoryUsage,
+		Func: queryHistory,
+	})
+
+	return query
+}
+
+// queryHistoryEntry is a query executed by document query or bulk export, stored in history file
+type queryHistoryEntry struct {
+	Time    time.Time `json:"time"`
+	Cluster string    `json:"cluster"`
+	Index   string    `json:"index"`
+	Query   string    `json:"query"`
+}
+
+// savedQueryOutput is a saved query as it is shown in structured output
+type savedQueryOutput struct {
+	Name         string   `json:"name"`
+	Placeholders []string `json:"placeholders"`
+	Query        string   `json:"query,omitempty"`
+}
+
+// historyOutput is a query history entry as it is shown in structured output
+type historyOutput struct {
+	Number int `json:"number"`
+	queryHistoryEntry
+}
+
+func saveQuery(c *ishell.Context) {
+	type saveArgs struct {
+		documentSelectorData
+		Editor bool `long:"editor" description:"Edit query in external editor"`
+		Last   bool `long:"last" description:"Save the last entered query"`
+	}
+	args := &saveArgs{}
+	positional, err := flags.ParseArgs(args, c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if len(positional) != 1 {
+		errorMsg(c, querySaveUsage)
+		return
+	}
+	name := positional[0]
+	if err := validateQueryName(name); err != nil {
+		printError(c, err)
+		return
+	}
+
+	var q string
+	if args.Last {
+		last, ok := lastPayloads[queryInput.kind]
+		if !ok || last == "" {
+			errorMsg(c, "No query has been entered yet")
+			return
+		}
+		if err := validateQueryTemplate(last); err != nil {
+			printError(c, err)
+			return
+		}
+		q = last
+	} else {
+		index := args.Index
+		if index == "" && context != nil {
+			index = context.ActiveIndex
+		}
+		var ok bool
+		if q, ok = editQuery(c, savedQueryInput, index, args.Document, args.Editor); !ok {
+			return
+		}
+		if q == "" {
+			errorMsg(c, "Query is empty")
+			return
+		}
+	}
+
+	fileName := savedQueryPath(name)
+	_, statErr := os.Stat(fileName)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		printError(c, err, "Failed to save query %s", name)
+		return
+	}
+	if err := ioutil.WriteFile(fileName, []byte(q+"\n"), 0600); err != nil {
+		printError(c, err, "Failed to save query %s", name)
+		return
+	}
+	if statErr == nil {
+		cprintlist(c, "Replaced query ", cyb(name))
+	} else {
+		cprintlist(c, "Saved query ", cyb(name), " to ", cy(fileName))
+	}
+}
+
+func listQueries(c *ishell.Context) {
+	names, err := savedQueryNames()
+	if err != nil {
+		printError(c, err, "Failed to list saved queries")
+		return
+	}
+	result := []savedQueryOutput{}
+	for _, name := range names {
+		q, err := loadSavedQuery(name)
+		if err != nil {
+			printError(c, err, "Failed to read query %s", name)
+			return
+		}
+		result = append(result, savedQueryOutput{Name: name, Placeholders: placeholderNames(q)})
+	}
+	render(c, result, func() {
+		if len(result) == 0 {
+			cprintln(c, "No saved queries in %s", filepath.Join(dataDir(), savedQueriesDir))
+			return
+		}
+		for _, q := range result {
+			if len(q.Placeholders) == 0 {
+				cprintlist(c, cyb(q.Name))
+			} else {
+				cprintlist(c, cyb(q.Name), " [", strings.Join(q.Placeholders, ", "), "]")
+			}
+		}
+	})
+}
+
+func showQuery(c *ishell.Context) {
+	if len(c.Args) != 1 {
+		errorMsg(c, queryShowUsage)
+		return
+	}
+	q, entry, err := findQuery(c.Args[0])
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if entry != nil {
+		render(c, entry, func() {
+			cprintlist(c, entry.Time.Format("2006-01-02 15:04:05"), " ", cyb(entry.Cluster), " ", cy(entry.Index))
+			cprintln(c, "%s", indentQuery(q))
+		})
+		return
+	}
+	result := savedQueryOutput{Name: c.Args[0], Placeholders: placeholderNames(q), Query: q}
+	render(c, result, func() {
+		cprintln(c, "%s", q)
+		if len(result.Placeholders) > 0 {
+			cprintlist(c, "Placeholders: ", strings.Join(result.Placeholders, ", "))
+		}
+	})
+}
+
+func runSavedQuery(c *ishell.Context) {
+	if context == nil {
+		errorMsg(c, errNotConnected)
+		return
+	}
+	args := &struct {
+		documentSelectorData
+		hitsArgs
+	}{}
+	positional, err := flags.ParseArgs(args, c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if len(positional) < 1 {
+		errorMsg(c, queryRunUsage)
+		return
+	}
+	q, entry, err := findQuery(positional[0])
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	index := args.Index
+	if index == "" && entry != nil {
+		index = entry.Index
+	}
+	if index == "" {
+		index = context.ActiveIndex
+	}
+
+	values := make(map[string]string)
+	for _, arg := range positional[1:] {
+		kv := strings.SplitN(arg, "=", 2)
+		if len(kv) != 2 {
+			errorMsg(c, "Invalid placeholder value %s, expected <placeholder>=<value>. %s", arg, queryRunUsage)
+			return
+		}
+		values[kv[0]] = kv[1]
+	}
+	q, ok := fillPlaceholders(c, q, values)
+	if !ok {
+		return
+	}
+	if err := validateJSON(q); err != nil {
+		printError(c, err)
+		return
+	}
+	runQuery(c, index, args.Document, q, args.hitsArgs)
+}
+
+func deleteQuery(c *ishell.Context) {
+	if len(c.Args) != 1 {
+		errorMsg(c, queryDeleteUsage)
+		return
+	}
+	name := c.Args[0]
+	if err := validateQueryName(name); err != nil {
+		printError(c, err)
+		return
+	}
+	if err := os.Remove(savedQueryPath(name)); err != nil {
+		if os.IsNotExist(err) {
+			errorMsg(c, "No saved query %s", name)
+		} else {
+			printError(c, err, "Failed to delete query %s", name)
+		}
+		return
+	}
+	cprintlist(c, "Deleted query ", cyb(name))
+}
+
+func queryHistory(c *ishell.Context) {
+	args := &struct {
+		All   bool `long:"all" description:"Show queries executed on all clusters"`
+		Limit int  `long:"limit" description:"Number of most recent queries to show" default:"20"`
+	}{}
+	if _, err := flags.ParseArgs(args, c.Args); err != nil {
+		printError(c, err)
+		return
+	}
+	if !args.All && context == nil {
+		errorMsg(c, errNotConnected+". Use --all to show queries of all clusters")
+		return
+	}
+	entries, err := readQueryHistory()
+	if err != nil {
+		printError(c, err, "Failed to read query history")
+		return
+	}
+	result := []historyOutput{}
+	for i, entry := range entries {
+		if args.All || entry.Cluster == context.ClusterName {
+			result = append(result, historyOutput{Number: i + 1, queryHistoryEntry: entry})
+		}
+	}
+	if args.Limit > 0 && len(result) > args.Limit {
+		result = result[len(result)-args.Limit:]
+	}
+	render(c, result, func() {
+		if len(result) == 0 {
+			cprintln(c, "Query history is empty")
+			return
+		}
+		for _, entry := range result {
+			cprintlist(c, hbl("%4d", entry.Number), " ", entry.Time.Format("2006-01-02 15:04:05"), " ",
+				cyb(entry.Cluster), " ", cy(entry.Index), " ", compactQuery(entry.Query))
+		}
+	})
+}
+
+// recordQuery appends query to the history file. History is best effort, failure to write it does not fail the query
+func recordQuery(cluster string, index string, query string) {
+	entry := queryHistoryEntry{Time: time.Now().Truncate(time.Second), Cluster: cluster, Index: index, Query: query}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	fileName := filepath.Join(dataDir(), queryHistoryFile)
+	if err := os.MkdirAll(filepath.Dir(fileName), 0700); err != nil {
+		return
+	}
+
+	entries, err := readQueryHistory()
+	if err == nil && len(entries) >= maxHistoryEntries {
+		// history is rewritten keeping only the most recent entries
+		var buffer bytes.Buffer
+		for _, old := range entries[len(entries)-maxHistoryEntries+1:] {
+			if data, err := json.Marshal(old); err == nil {
+				buffer.Write(data)
+				buffer.WriteByte('\n')
+			}
+		}
+		buffer.Write(line)
+		buffer.WriteByte('\n')
+		ioutil.WriteFile(fileName, buffer.Bytes(), 0600)
+		return
+	}
+
+	file, err := os.OpenFile(fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+	file.Write(append(line, '\n'))
+}
+
+// readQueryHistory reads all history entries, oldest first. Missing history file is not an error
+func readQueryHistory() ([]queryHistoryEntry, error) {
+	file, err := os.Open(filepath.Join(dataDir(), queryHistoryFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var result []queryHistoryEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		var entry queryHistoryEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err == nil {
+			result = append(result, entry)
+		}
+	}
+	return result, scanner.Err()
+}
+
+// findQuery returns saved query by name or query from history by its number. History entry is nil for saved queries
+func findQuery(ref string) (string, *queryHistoryEntry, error) {
+	if number, err := strconv.Atoi(ref); err == nil {
+		entries, err := readQueryHistory()
+		if err != nil {
+			return "", nil, err
+		}
+		if number < 1 || number > len(entries) {
+			return "", nil, fmt.Errorf("No query number %d in history", number)
+		}
+		entry := entries[number-1]
+		return entry.Query, &entry, nil
+	}
+	if err := validateQueryName(ref); err != nil {
+		return "", nil, err
+	}
+	q, err := loadSavedQuery(ref)
+	if os.IsNotExist(err) {
+		return "", nil, fmt.Errorf("No saved query %s", ref)
+	}
+	return q, nil, err
+}
+
+// fillPlaceholders replaces placeholders with given values or defaults. Values which are not given are asked at the
+// prompt, in batch mode they must be given as arguments
+func fillPlaceholders(c *ishell.Context, q string, values map[string]string) (string, bool) {
+	for _, match := range placeholderPattern.FindAllStringSubmatch(q, -1) {
+		name := match[1]
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if strings.Contains(match[0], ":") {
+			values[name] = match[2]
+			continue
+		}
+		if isBatch() {
+			errorMsg(c, "Value of %s is not given. %s", name, queryRunUsage)
+			return "", false
+		}
+		c.SetPrompt(name + ": ")
+		values[name] = c.ReadLine()
+		restorePrompt(c)
+	}
+	return placeholderPattern.ReplaceAllStringFunc(q, func(placeholder string) string {
+		return values[placeholderPattern.FindStringSubmatch(placeholder)[1]]
+	}), true
+}
+
+// placeholderNames returns names of placeholders of the query in order of appearance
+func placeholderNames(q string) []string {
+	result := []string{}
+	seen := make(map[string]bool)
+	for _, match := range placeholderPattern.FindAllStringSubmatch(q, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			result = append(result, match[1])
+		}
+	}
+	return result
+}
+
+// validateQueryTemplate checks that query is a JSON object once placeholders are filled in
+func validateQueryTemplate(q string) error {
+	return validateQuery(placeholderPattern.ReplaceAllString(q, "0"))
+}
+
+func validateQueryName(name string) error {
+	if !queryNamePattern.MatchString(name) {
+		return fmt.Errorf("Invalid query name %s, use letters, digits, '_', '-' and '.'", name)
+	}
+	if _, err := strconv.Atoi(name); err == nil {
+		return fmt.Errorf("Invalid query name %s, numbers refer to query history", name)
+	}
+	return nil
+}
+
+// dataDir returns directory with saved queries and query history
+func dataDir() string {
+	if settings != nil && settings.DataDir != "" {
+		return settings.DataDir
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return defaultDataDir
+	}
+	return filepath.Join(home, defaultDataDir)
+}
+
+func savedQueryPath(name string) string {
+	return filepath.Join(dataDir(), savedQueriesDir, name+savedQueryExt)
+}
+
+func loadSavedQuery(name string) (string, error) {
+	data, err := ioutil.ReadFile(savedQueryPath(name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// savedQueryNames returns sorted names of saved queries
+func savedQueryNames() ([]string, error) {
+	files, err := ioutil.ReadDir(filepath.Join(dataDir(), savedQueriesDir))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var names []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), savedQueryExt) {
+			names = append(names, strings.TrimSuffix(file.Name(), savedQueryExt))
+		}
+	}
+	return names, nil
+}
+
+// compactQuery returns query as a single line
+func compactQuery(q string) string {
+	var buffer bytes.Buffer
+	if err := json.Compact(&buffer, []byte(q)); err != nil {
+		return strings.Join(strings.Fields(q), " ")
+	}
+	return buffer.String()
+}
+
+// indentQuery returns pretty printed query, or query as is if it is not valid JSON
+func indentQuery(q string) string {
+	var buffer bytes.Buffer
+	if err := json.Indent(&buffer, []byte(q), "", "  "); err != nil {
+		return q
+	}
+	return buffer.String()
+}
+
+func completeSavedQueries(args []string) []string {
+	names, _ := savedQueryNames()
+	return names
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"encoding/json"
+	"shelastic/es"
+	"sort"
+	"strings"
+	"unicode"
+
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+const matchAllQuery = "{\"query\": {\"match_all\":{}}}"
+
+// dslKeywords are Query DSL keywords suggested in query editor
+var dslKeywords = []string{
+	"_source", "aggs", "asc", "bool", "boost", "calendar_interval", "cardinality", "date_histogram", "desc", "exists",
+	"excludes", "field", "fields", "filter", "format", "from", "fuzzy", "gt", "gte", "highlight", "histogram", "ids",
+	"includes", "interval", "lt", "lte", "match", "match_all", "match_none", "match_phrase", "match_phrase_prefix",
+	"max", "min", "minimum_should_match", "multi_match", "must", "must_not", "nested", "operator", "order", "path",
+	"prefix", "query", "query_string", "range", "regexp", "should", "simple_query_string", "size", "sort", "sum",
+	"term", "terms", "track_total_hits", "values", "wildcard",
+}
+
+// queryEditor completes field names of the index mapping and Query DSL keywords while query is entered
+type queryEditor struct {
+	index string
+	doc   string
+}
+
+// activeQuery is set while query is entered at the prompt
+var activeQuery *queryEditor
+
+// readQuery reads query at the prompt or in external editor. Empty query is replaced with match_all query.
+// Returns false if input is cancelled or is not valid JSON
+func readQuery(c *ishell.Context, index string, doc string, useEditor bool) (string, bool) {
+	q, ok := editQuery(c, queryInput, index, doc, useEditor)
+	if !ok {
+		return "", false
+	}
+	if q == "" {
+		q = matchAllQuery
+		cprintln(c, "Using match all query")
+	}
+	return q, true
+}
+
+// editQuery reads query with completion of fields of the index
+func editQuery(c *ishell.Context, input payloadInput, index string, doc string, useEditor bool) (string, bool) {
+	activeQuery = &queryEditor{index: index, doc: doc}
+	defer func() {
+		activeQuery = nil
+	}()
+	return readPayload(c, input, useEditor)
+}
+
+// complete suggests quoted field names and keywords. Only the word inside quotes is completed and closing quote is
+// added. Keywords are not suggested in place of values, i.e. after colon
+func (qe *queryEditor) complete(line []rune) ([][]rune, int) {
+	start := len(line)
+	for start > 0 && isFieldRune(line[start-1]) {
+		start--
+	}
+	if start == 0 || line[start-1] != '"' {
+		return nil, 0
+	}
+	prefix := string(line[start:])
+	before := strings.TrimRightFunc(string(line[:start-1]), unicode.IsSpace)
+
+	candidates := qe.fields()
+	if !strings.HasSuffix(before, ":") {
+		candidates = append(candidates, dslKeywords...)
+	}
+	sort.Strings(candidates)
+
+	var suggestions [][]rune
+	for i, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) && (i == 0 || candidates[i-1] != candidate) {
+			suggestions = append(suggestions, []rune(strings.TrimPrefix(candidate, prefix)+"\""))
+		}
+	}
+	return suggestions, len([]rune(prefix))
+}
+
+func (qe *queryEditor) fields() []string {
+	if qe.index == "" {
+		return nil
+	}
+	return metadata.get(fieldsKey(qe.index, qe.doc), func(conn es.Es) ([]string, error) {
+		fields, err := conn.ListFields(qe.index, qe.doc)
+		if err != nil {
+			return nil, err
+		}
+		result := make([]string, len(fields))
+		for i, field := range fields {
+			result[i] = field.Name
+		}
+		return result, nil
+	})
+}
+
+func isFieldRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_.@-", r)
+}
+
+func fieldsKey(index string, doc string) string {
+	return "fields/" + index + "/" + doc
+}
+
+// completeFields suggests fields of the index given with --index or of the index in use
+func completeFields(args []string) []string {
+	if context == nil {
+		return nil
+	}
+	index := flagValue(args, "--index")
+	if index == "" {
+		index = context.ActiveIndex
+	}
+	qe := &queryEditor{index: index, doc: flagValue(args, "--doc")}
+	return qe.fields()
+}
+
+const skeletonUsage = "Usage: skeleton [--index <index-name>] [--doc <doc>] <field>"
+
+// querySkeleton prints query for the field, chosen according to the field type
+func querySkeleton(c *ishell.Context) {
+	if context == nil {
+		errorMsg(c, errNotConnected)
+		return
+	}
+	selector, err := parseDocumentArgs(c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if selector.Index == "" {
+		errorMsg(c, errIndexNotSelected)
+		return
+	}
+	if len(selector.Args) == 0 {
+		errorMsg(c, "Field is not specified. "+skeletonUsage)
+		return
+	}
+	fields, err := context.ListFields(selector.Index, selector.Document)
+	if err != nil {
+		printError(c, err, "Failed to retrieve mapping of %s", selector.Index)
+		return
+	}
+	name := selector.Args[0]
+	types := make(map[string]string)
+	for _, field := range fields {
+		types[field.Name] = field.Type
+	}
+	fieldType, ok := types[name]
+	if !ok {
+		errorMsg(c, "No field %s in mapping of %s", name, selector.Index)
+		return
+	}
+
+	query := map[string]interface{}{"query": skeleton(name, fieldType, types)}
+	render(c, query, func() {
+		text, err := json.MarshalIndent(query, "", "  ")
+		if err != nil {
+			printError(c, err)
+			return
+		}
+		cprintln(c, "%s", text)
+	})
+}
+
+// skeleton returns query clause suitable for the field type. Fields inside nested objects are wrapped in nested query
+func skeleton(name string, fieldType string, types map[string]string) map[string]interface{} {
+	var clause map[string]interface{}
+	switch fieldType {
+	case "text", "match_only_text", "search_as_you_type":
+		clause = map[string]interface{}{"match": map[string]interface{}{name: map[string]interface{}{"query": ""}}}
+	case "keyword", "constant_keyword", "wildcard", "ip", "version":
+		clause = map[string]interface{}{"term": map[string]interface{}{name: ""}}
+	case "boolean":
+		clause = map[string]interface{}{"term": map[string]interface{}{name: true}}
+	case "long", "integer", "short", "byte", "double", "float", "half_float", "scaled_float", "unsigned_long":
+		clause = map[string]interface{}{"range": map[string]interface{}{name: map[string]interface{}{"gte": 0, "lte": 0}}}
+	case "date", "date_nanos":
+		clause = map[string]interface{}{"range": map[string]interface{}{name: map[string]interface{}{"gte": "now-1d", "lte": "now"}}}
+	case "geo_point":
+		clause = map[string]interface{}{"geo_distance": map[string]interface{}{"distance": "10km", name: map[string]interface{}{"lat": 0, "lon": 0}}}
+	case "nested":
+		return map[string]interface{}{"nested": map[string]interface{}{"path": name, "query": map[string]interface{}{"match_all": map[string]interface{}{}}}}
+	default:
+		clause = map[string]interface{}{"exists": map[string]interface{}{"field": name}}
+	}
+
+	for parent := name; strings.Contains(parent, "."); {
+		parent = parent[:strings.LastIndex(parent, ".")]
+		if types[parent] == "nested" {
+			clause = map[string]interface{}{"nested": map[string]interface{}{"path": parent, "query": clause}}
+		}
+	}
+	return clause
+}
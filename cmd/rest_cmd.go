@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"encoding/json"
+	"net/http"
+	"shelastic/utils"
+	"strings"
+
+	flags "github.com/jessevdk/go-flags"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+const restUsage = "Usage: rest [--body] [--json] <GET|POST|PUT|DELETE|HEAD> <path>"
+
+// Rest executes arbitrary REST request against the cluster
+func Rest() *ishell.Cmd {
+	return &ishell.Cmd{
+		Name: "rest",
+		Help: "Executes REST request, e.g. rest GET _cat/allocation?v. " + restUsage,
+		Func: restRequest,
+	}
+}
+
+func restRequest(c *ishell.Context) {
+	if context == nil {
+		errorMsg(c, errNotConnected)
+		return
+	}
+	var args struct {
+		Body bool `long:"body" short:"b" description:"Read request body for GET, DELETE and HEAD requests"`
+		JSON bool `long:"json" description:"Print JSON responses as JSON instead of YAML"`
+	}
+	positional, err := flags.ParseArgs(&args, c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if len(positional) < 2 {
+		errorMsg(c, "Not enough parameters. "+restUsage)
+		return
+	}
+	method := strings.ToUpper(positional[0])
+	switch method {
+	case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodHead:
+	default:
+		errorMsg(c, "Unsupported method "+positional[0]+". "+restUsage)
+		return
+	}
+
+	body := ""
+	if method == http.MethodPost || method == http.MethodPut || args.Body {
+		cprintln(c, "Enter request body, ending with ';'. Empty body is allowed")
+		c.SetPrompt(">>> ")
+		body = readMultiLines(c, ";")
+		restorePrompt(c)
+		body = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(body), ";"))
+	}
+
+	resp, err := context.Rest(method, positional[1], body)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if method == http.MethodHead || len(resp.Body) == 0 {
+		cprintln(c, "%d %s", resp.Status, http.StatusText(resp.Status))
+		return
+	}
+	if !resp.IsJSON() {
+		render(c, string(resp.Body), func() {
+			cprintln(c, "%s", string(resp.Body))
+		})
+		return
+	}
+
+	var data interface{}
+	if err := json.Unmarshal(resp.Body, &data); err != nil {
+		printError(c, err)
+		return
+	}
+	render(c, data, func() {
+		var text string
+		if args.JSON {
+			var bytes []byte
+			bytes, err = json.MarshalIndent(data, "", "  ")
+			text = string(bytes)
+		} else {
+			text, err = utils.MapToYaml(data)
+		}
+		if err != nil {
+			printError(c, err)
+			return
+		}
+		cprintln(c, "%s", text)
+	})
+}
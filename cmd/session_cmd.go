@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"fmt"
+	"shelastic/es"
+	"sort"
+
+	"github.com/fatih/color"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+var (
+	// sessions contains all open connections to Elasticsearch clusters by session name
+	sessions = make(map[string]*es.Es)
+	// activeSession is a name of the session used by commands. context points to the connection of active session
+	activeSession string
+	// sessionNoColor tells if colors are disabled in the session. Profiles may disable or enable colors for their sessions
+	sessionNoColor = make(map[string]bool)
+)
+
+// addSession registers a new connection under given session name and makes it active.
+// If name is empty, cluster name is used, with numeric suffix added if such session already exists.
+// Colors are disabled while the session is active if noColor is set
+func addSession(name string, conn *es.Es, noColor bool) string {
+	if name == "" {
+		name = conn.ClusterName
+		for i := 2; sessions[name] != nil; i++ {
+			name = fmt.Sprintf("%s-%d", conn.ClusterName, i)
+		}
+	}
+	sessions[name] = conn
+	sessionNoColor[name] = noColor
+	switchSession(name)
+	return name
+}
+
+// switchSession makes session with a given name active and applies its color setting. Empty name deactivates
+// current session and restores color setting of the command line
+func switchSession(name string) {
+	activeSession = name
+	context = sessions[name]
+	if noColor, ok := sessionNoColor[name]; ok {
+		color.NoColor = noColor
+	} else {
+		color.NoColor = settings.NoColor
+	}
+}
+
+// closeSession removes session. If active session is closed, another remaining session becomes active
+func closeSession(name string) {
+	delete(sessions, name)
+	delete(sessionNoColor, name)
+	if name == activeSession {
+		names := sessionNames()
+		if len(names) > 0 {
+			switchSession(names[0])
+		} else {
+			switchSession("")
+		}
+	}
+}
+
+func sessionNames() []string {
+	names := make([]string, 0, len(sessions))
+	for name := range sessions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Session is a parent for session management commands
+func Session() *ishell.Cmd {
+	session := &ishell.Cmd{
+		Name: "session",
+		Help: "Manage connections to several clusters",
+	}
+
+	session.AddCmd(&ishell.Cmd{
+		Name: "list",
+		Help: "List open sessions. Active session is marked with *",
+		Func: listSessions,
+	})
+
+	session.AddCmd(&ishell.Cmd{
+		Name: "use",
+		Help: "Make session active. Usage: use <session-name>",
+		Func: useSession,
+	})
+
+	return session
+}
+
+// sessionOutput is a session as it is shown in structured output
+type sessionOutput struct {
+	Name    string `json:"name"`
+	Active  bool   `json:"active"`
+	Cluster string `json:"cluster"`
+	Index   string `json:"index"`
+}
+
+func listSessions(c *ishell.Context) {
+	result := []sessionOutput{}
+	for _, name := range sessionNames() {
+		conn := sessions[name]
+		result = append(result, sessionOutput{name, name == activeSession, conn.ClusterName, conn.ActiveIndex})
+	}
+	render(c, result, func() {
+		if len(result) == 0 {
+			cprintln(c, "No open sessions")
+			return
+		}
+		for _, session := range result {
+			marker := "  "
+			if session.Active {
+				marker = "* "
+			}
+			index := session.Index
+			if index == "" {
+				index = "-"
+			}
+			cprintlist(c, marker, cyb(session.Name), " [cluster: ", session.Cluster, ", index: ", index, "]")
+		}
+	})
+}
+
+func useSession(c *ishell.Context) {
+	if len(c.Args) < 1 {
+		errorMsg(c, "Please specify session name")
+		return
+	}
+	name := c.Args[0]
+	if _, ok := sessions[name]; !ok {
+		errorMsg(c, "Unknown session: %s", name)
+		return
+	}
+	switchSession(name)
+	cprintlist(c, "Using session ", cyb(name), " (cluster ", context.ClusterName, ")")
+	restorePrompt(c)
+}
@@ -1,8 +1,6 @@
 package cmd
 
 import (
-	"shelastic/utils"
-
 	ishell "gopkg.in/abiosoft/ishell.v2"
 )
 
@@ -74,7 +72,7 @@ func verifyRepo(c *ishell.Context) {
 		repoName := c.Args[0]
 		err := context.VerifyRepository(repoName)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
 			cprintln(c, "Ok")
 		}
@@ -93,8 +91,9 @@ func createSnapshot(c *ishell.Context) {
 		snapshotName := c.Args[1]
 		err := context.CreateSnapshot(repoName, snapshotName)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
+			metadata.refresh(snapshotsKey(repoName))
 			cprintln(c, "Ok")
 		}
 	} else {
@@ -117,14 +116,9 @@ func getSnapshotInfo(c *ishell.Context) {
 		}
 		info, err := context.GetSnapshotInfo(repoName, snapshotName)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
-			result, err := utils.MapToYaml(info)
-			if err != nil {
-				errorMsg(c, err.Error())
-			} else {
-				cprintln(c, result)
-			}
+			renderYAML(c, info)
 		}
 	} else {
 		errorMsg(c, errNotConnected)
@@ -142,8 +136,9 @@ func deleteSnapshot(c *ishell.Context) {
 
 		err := context.DeleteSnapshot(repoName, snapshot)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
+			metadata.refresh(snapshotsKey(repoName))
 			cprintln(c, "Ok")
 		}
 	} else {
@@ -162,8 +157,9 @@ func restoreSnapshot(c *ishell.Context) {
 
 		err := context.RestoreSnapshot(repoName, snapshot)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
+			metadata.refresh(metadataIndices)
 			cprintln(c, "Ok")
 		}
 	} else {
@@ -184,8 +180,9 @@ func createRepo(c *ishell.Context) {
 
 		err := context.RegisterRepository(repoName, repoType, settings)
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
+			metadata.refresh(metadataRepositories)
 			cprintln(c, "Ok")
 		}
 	} else {
@@ -197,14 +194,9 @@ func listRepo(c *ishell.Context) {
 	if context != nil {
 		data, err := context.ListRepository()
 		if err != nil {
-			errorMsg(c, err.Error())
+			printError(c, err)
 		} else {
-			yaml, err := utils.MapToYaml(data)
-			if err != nil {
-				errorMsg(c, err.Error())
-			} else {
-				cprintln(c, yaml)
-			}
+			renderYAML(c, data)
 		}
 	} else {
 		errorMsg(c, errNotConnected)
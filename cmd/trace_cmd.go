@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"shelastic/es"
+
+	flags "github.com/jessevdk/go-flags"
+	ishell "gopkg.in/abiosoft/ishell.v2"
+)
+
+// tracer records HTTP traffic of all sessions when tracing is on
+var tracer *es.Tracer
+
+// Trace is a parent for request tracing commands
+func Trace() *ishell.Cmd {
+	trace := &ishell.Cmd{
+		Name: "trace",
+		Help: "Record requests and responses to a file",
+		Func: traceStatus,
+	}
+
+	trace.AddCmd(&ishell.Cmd{
+		Name: "on",
+		Help: "Start recording requests and responses. Usage: trace on [--format text|curl|har] [--no-redact] <file>",
+		Func: traceOn,
+	})
+
+	trace.AddCmd(&ishell.Cmd{
+		Name: "off",
+		Help: "Stop recording requests and responses",
+		Func: traceOff,
+	})
+
+	return trace
+}
+
+// traceOutput is a trace status as it is shown in structured output
+type traceOutput struct {
+	Enabled bool   `json:"enabled"`
+	File    string `json:"file,omitempty"`
+	Format  string `json:"format,omitempty"`
+	Entries int    `json:"entries"`
+}
+
+func traceStatus(c *ishell.Context) {
+	if tracer == nil {
+		render(c, traceOutput{}, func() {
+			cprintln(c, "Trace is off")
+		})
+		return
+	}
+	status := tracer.Snapshot()
+	render(c, traceOutput{true, status.FileName, status.Format, status.Entries}, func() {
+		cprintlist(c, "Tracing to ", cy(status.FileName), " in ", status.Format, " format, ", hbl("%d", status.Entries), " requests recorded")
+	})
+}
+
+func traceOn(c *ishell.Context) {
+	var args struct {
+		Format   string `long:"format" choice:"text" choice:"curl" choice:"har" default:"text" description:"Trace file format"`
+		NoRedact bool   `long:"no-redact" description:"Record values of authentication headers and passwords in URLs"`
+	}
+	positional, err := flags.ParseArgs(&args, c.Args)
+	if err != nil {
+		printError(c, err)
+		return
+	}
+	if len(positional) == 0 {
+		errorMsg(c, "Please specify trace file name")
+		return
+	}
+
+	newTracer, err := es.StartTrace(positional[0], args.Format, !args.NoRedact)
+	if err != nil {
+		printError(c, err, "Failed to start trace")
+		return
+	}
+	stopTrace(c)
+	setTracer(newTracer)
+	cprintlist(c, "Tracing requests to ", cy(newTracer.FileName))
+}
+
+func traceOff(c *ishell.Context) {
+	if tracer == nil {
+		cprintln(c, "Trace is off")
+		return
+	}
+	fileName := tracer.FileName
+	stopTrace(c)
+	cprintlist(c, "Trace saved to ", cy(fileName))
+}
+
+// stopTrace closes current trace file, if any, and stops recording
+func stopTrace(c *ishell.Context) {
+	if tracer == nil {
+		return
+	}
+	if err := tracer.Close(); err != nil {
+		printError(c, err, "Failed to close trace file")
+	}
+	setTracer(nil)
+}
+
+// setTracer makes all the sessions use given tracer
+func setTracer(t *es.Tracer) {
+	tracer = t
+	for _, session := range sessions {
+		session.Tracer = t
+	}
+}
@@ -0,0 +1,271 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Aggregation is a parsed result of an aggregation. Bucket aggregations, like terms or histogram, have buckets,
+// metric aggregations have values and single bucket aggregations, like nested or filter, have document count
+// and sub-aggregations
+type Aggregation struct {
+	Name string
+	// Type is a type of aggregation as given in the query, e.g. terms or date_histogram. Responses do not report
+	// types, so it is empty if aggregation is not found in the query
+	Type string
+	// Buckets are nil unless it is a bucket aggregation
+	Buckets []*Bucket
+	// Values are values of metric aggregation in order they are usually shown, e.g. count, min, max, avg and sum of
+	// stats, or percents of percentiles. Single value metrics have one value named "value"
+	Values []MetricValue
+	// DocCount is a number of documents in single bucket aggregation, it is nil for other aggregations
+	DocCount     *int64
+	Aggregations []*Aggregation
+	// Raw is a result of aggregation which is not recognized, e.g. top_hits
+	Raw map[string]interface{}
+}
+
+// Bucket is a bucket of bucket aggregation with its sub-aggregations
+type Bucket struct {
+	// Key is a formatted key of the bucket, e.g. a term or a date
+	Key          string
+	DocCount     int64
+	Aggregations []*Aggregation
+}
+
+// MetricValue is a named value of metric aggregation. Value is a number, a formatted string, or nil if metric
+// has no value, e.g. average of no documents
+type MetricValue struct {
+	Name  string
+	Value interface{}
+}
+
+// statsOrder is an order of values of stats and extended_stats aggregations, other values follow in alphabetical order
+var statsOrder = []string{"count", "min", "max", "avg", "sum"}
+
+// parseAggregations converts aggregations section of search response. Definitions are aggregations of the query,
+// they give types of aggregations and tell sub-aggregations from other fields of buckets
+func parseAggregations(definitions map[string]interface{}, response map[string]interface{}) []*Aggregation {
+	var result []*Aggregation
+	for _, name := range aggregationNames(definitions, response) {
+		value, ok := response[name].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		definition, _ := definitions[name].(map[string]interface{})
+		result = append(result, parseAggregation(name, definition, value))
+	}
+	return result
+}
+
+// aggregationNames returns sorted names of aggregations present in response. Without definitions every object
+// in response is considered an aggregation
+func aggregationNames(definitions map[string]interface{}, response map[string]interface{}) []string {
+	var names []string
+	for name, value := range response {
+		if _, ok := definitions[name]; ok {
+			names = append(names, name)
+		} else if _, isObject := value.(map[string]interface{}); isObject && definitions == nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func parseAggregation(name string, definition map[string]interface{}, value map[string]interface{}) *Aggregation {
+	result := &Aggregation{Name: name, Type: aggregationType(definition)}
+	subDefinitions := subAggregations(definition)
+	if buckets, ok := value["buckets"]; ok {
+		result.Buckets = parseBuckets(buckets, subDefinitions)
+	} else if values, ok := value["values"]; ok {
+		result.Values = percentileValues(values)
+	} else if _, ok := value["value"]; ok {
+		result.Values = []MetricValue{{"value", metricValue(value, "value")}}
+	} else if _, ok := value["avg"]; ok {
+		result.Values = statsValues(value)
+	} else if count, ok := value["doc_count"].(float64); ok {
+		docCount := int64(count)
+		result.DocCount = &docCount
+		result.Aggregations = parseAggregations(subDefinitions, value)
+	} else {
+		result.Raw = value
+	}
+	return result
+}
+
+// aggregationType returns type of aggregation from its definition, e.g. {"terms": {...}, "aggs": {...}} is terms
+func aggregationType(definition map[string]interface{}) string {
+	for key := range definition {
+		if key != "aggs" && key != "aggregations" && key != "meta" {
+			return key
+		}
+	}
+	return ""
+}
+
+func subAggregations(definition map[string]interface{}) map[string]interface{} {
+	if aggs, ok := definition["aggs"].(map[string]interface{}); ok {
+		return aggs
+	}
+	aggs, _ := definition["aggregations"].(map[string]interface{})
+	return aggs
+}
+
+// parseBuckets converts list of buckets. Keyed buckets, returned as an object by filters and keyed range
+// aggregations, are sorted by key
+func parseBuckets(buckets interface{}, definitions map[string]interface{}) []*Bucket {
+	result := []*Bucket{}
+	add := func(key string, bucket map[string]interface{}) {
+		count, _ := bucket["doc_count"].(float64)
+		if key == "" {
+			key = bucketKey(bucket)
+		}
+		result = append(result, &Bucket{Key: key, DocCount: int64(count), Aggregations: parseBucketAggregations(definitions, bucket)})
+	}
+	switch b := buckets.(type) {
+	case []interface{}:
+		for _, item := range b {
+			if bucket, ok := item.(map[string]interface{}); ok {
+				add("", bucket)
+			}
+		}
+	case map[string]interface{}:
+		keys := make([]string, 0, len(b))
+		for key := range b {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			if bucket, ok := b[key].(map[string]interface{}); ok {
+				add(key, bucket)
+			}
+		}
+	}
+	return result
+}
+
+// parseBucketAggregations parses sub-aggregations of a bucket. Without definitions, fields which are a part of
+// bucket itself are not taken for sub-aggregations
+func parseBucketAggregations(definitions map[string]interface{}, bucket map[string]interface{}) []*Aggregation {
+	if definitions != nil {
+		return parseAggregations(definitions, bucket)
+	}
+	subs := make(map[string]interface{})
+	for name, value := range bucket {
+		if name != "key" && name != "key_as_string" && name != "from" && name != "to" && name != "doc_count" {
+			subs[name] = value
+		}
+	}
+	return parseAggregations(nil, subs)
+}
+
+// bucketKey formats key of a bucket. Formatted key, such as a date, is preferred, range buckets without key are
+// shown as from-to
+func bucketKey(bucket map[string]interface{}) string {
+	if key, ok := bucket["key_as_string"].(string); ok {
+		return key
+	}
+	if key, ok := bucket["key"]; ok {
+		return formatValue(key)
+	}
+	from, hasFrom := bucket["from"]
+	to, hasTo := bucket["to"]
+	if hasFrom || hasTo {
+		return formatValue(from) + "-" + formatValue(to)
+	}
+	return ""
+}
+
+// percentileValues converts values of percentiles, given either as an object or as a list of keys and values,
+// ordered by percent
+func percentileValues(values interface{}) []MetricValue {
+	var result []MetricValue
+	switch v := values.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if strings.HasSuffix(key, "_as_string") {
+				continue
+			}
+			if formatted, ok := v[key+"_as_string"]; ok {
+				value = formatted
+			}
+			result = append(result, MetricValue{key, value})
+		}
+	case []interface{}:
+		for _, item := range v {
+			if entry, ok := item.(map[string]interface{}); ok {
+				result = append(result, MetricValue{formatValue(entry["key"]), metricValue(entry, "value")})
+			}
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		a, _ := strconv.ParseFloat(result[i].Name, 64)
+		b, _ := strconv.ParseFloat(result[j].Name, 64)
+		return a < b
+	})
+	return result
+}
+
+// statsValues converts values of stats and extended_stats aggregations. Scalar values only are included
+func statsValues(value map[string]interface{}) []MetricValue {
+	var result []MetricValue
+	for _, name := range statsOrder {
+		if _, ok := value[name]; ok {
+			result = append(result, MetricValue{name, metricValue(value, name)})
+		}
+	}
+	var others []string
+	for name, v := range value {
+		switch v.(type) {
+		case map[string]interface{}, []interface{}:
+			continue
+		}
+		if !strings.HasSuffix(name, "_as_string") && !contains(statsOrder, name) {
+			others = append(others, name)
+		}
+	}
+	sort.Strings(others)
+	for _, name := range others {
+		result = append(result, MetricValue{name, metricValue(value, name)})
+	}
+	return result
+}
+
+// metricValue returns formatted value of the metric if it is reported, e.g. for dates, or the raw value
+func metricValue(value map[string]interface{}, name string) interface{} {
+	if formatted, ok := value[name+"_as_string"].(string); ok {
+		return formatted
+	}
+	return value[name]
+}
+
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case map[string]interface{}:
+		// composite aggregation key
+		data, err := json.Marshal(v)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return fmt.Sprint(value)
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
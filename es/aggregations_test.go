@@ -0,0 +1,166 @@
+package es
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+// aggregationsQuery is the query the search_aggregations fixtures are recorded with. Fixtures of old versions contain
+// only the aggregations which were recorded with them
+const aggregationsQuery = `{
+  "size": 0,
+  "aggs": {
+    "genres": {
+      "terms": {"field": "genre"},
+      "aggs": {"avg_year": {"avg": {"field": "year"}}, "authors": {"cardinality": {"field": "author"}}}
+    },
+    "by_year": {"date_histogram": {"field": "published", "calendar_interval": "year", "format": "yyyy"}},
+    "pages": {"range": {"field": "pages", "ranges": [{"to": 300}, {"from": 300}]}},
+    "year_stats": {"stats": {"field": "year"}},
+    "pages_stats": {"extended_stats": {"field": "pages"}},
+    "year_percentiles": {"percentiles": {"field": "year", "percents": [50, 95]}},
+    "published_percentiles": {"percentiles": {"field": "published", "percents": [50, 95], "format": "yyyy-MM-dd"}},
+    "reviews": {"nested": {"path": "reviews"}, "aggs": {"ratings": {"terms": {"field": "reviews.rating"}}}},
+    "top": {"top_hits": {"size": 1}}
+  }
+}`
+
+func fixtureAggregations(t *testing.T, version string) map[string]interface{} {
+	var body searchResponse
+	if err := decodeResponse("/books/_search", fixture(t, version, "search_aggregations"), &body); err != nil {
+		t.Fatalf("%s: %s", version, err)
+	}
+	return body.Aggregations
+}
+
+func queryAggregations(t *testing.T) map[string]interface{} {
+	var query map[string]interface{}
+	if err := json.Unmarshal([]byte(aggregationsQuery), &query); err != nil {
+		t.Fatal(err)
+	}
+	return subAggregations(query)
+}
+
+func docCount(count int64) *int64 {
+	return &count
+}
+
+func singleValue(v interface{}) []MetricValue {
+	return []MetricValue{{"value", v}}
+}
+
+// genresAggregation is terms aggregation with metric sub-aggregations, recorded the same way by all versions
+var genresAggregation = &Aggregation{Name: "genres", Type: "terms", Buckets: []*Bucket{
+	{Key: "sf", DocCount: 2, Aggregations: []*Aggregation{
+		{Name: "authors", Type: "cardinality", Values: singleValue(2.0)},
+		{Name: "avg_year", Type: "avg", Values: singleValue(1963.0)},
+	}},
+	{Key: "fantasy", DocCount: 1, Aggregations: []*Aggregation{
+		{Name: "authors", Type: "cardinality", Values: singleValue(1.0)},
+		{Name: "avg_year", Type: "avg", Values: singleValue(1954.0)},
+	}},
+}}
+
+var pagesAggregation = &Aggregation{Name: "pages", Type: "range", Buckets: []*Bucket{
+	{Key: "*-300.0", DocCount: 1},
+	{Key: "300.0-*", DocCount: 2},
+}}
+
+var reviewsAggregation = &Aggregation{Name: "reviews", Type: "nested", DocCount: docCount(4), Aggregations: []*Aggregation{
+	{Name: "ratings", Type: "terms", Buckets: []*Bucket{{Key: "5", DocCount: 3}, {Key: "4", DocCount: 1}}},
+}}
+
+func TestParseAggregations(t *testing.T) {
+	response := fixtureAggregations(t, "7.17.9")
+	expected := []*Aggregation{
+		{Name: "by_year", Type: "date_histogram", Buckets: []*Bucket{
+			{Key: "1954", DocCount: 1},
+			{Key: "1961", DocCount: 1},
+			{Key: "1965", DocCount: 1},
+		}},
+		genresAggregation,
+		pagesAggregation,
+		{Name: "pages_stats", Type: "extended_stats", Values: []MetricValue{
+			{"count", 3.0}, {"min", 204.0}, {"max", 896.0}, {"avg", 512.0}, {"sum", 1536.0},
+			{"std_deviation", 287.57375865448273}, {"std_deviation_population", 287.57375865448273},
+			{"std_deviation_sampling", 352.20448605888026}, {"sum_of_squares", 1034528.0},
+			{"variance", 82698.66666666669}, {"variance_population", 82698.66666666669}, {"variance_sampling", 124048.0},
+		}},
+		reviewsAggregation,
+		// top hits are not recognized and kept as returned
+		{Name: "top", Type: "top_hits", Raw: response["top"].(map[string]interface{})},
+		{Name: "year_percentiles", Type: "percentiles", Values: []MetricValue{{"50.0", 1961.0}, {"95.0", 1965.0}}},
+		{Name: "year_stats", Type: "stats", Values: []MetricValue{
+			{"count", 3.0}, {"min", 1954.0}, {"max", 1965.0}, {"avg", 1960.0}, {"sum", 5880.0},
+		}},
+	}
+	assertAggregations(t, "7.17.9", parseAggregations(queryAggregations(t), response), expected)
+}
+
+func TestParseLegacyAggregations(t *testing.T) {
+	expected := []*Aggregation{
+		genresAggregation,
+		// 1.x adds formatted from and to to range buckets, they are not sub-aggregations
+		pagesAggregation,
+		// formatted percentiles of dates are preferred over numbers
+		{Name: "published_percentiles", Type: "percentiles", Values: []MetricValue{{"50.0", "1961-01-01"}, {"95.0", "1965-01-01"}}},
+		reviewsAggregation,
+	}
+	assertAggregations(t, "1.7.6", parseAggregations(queryAggregations(t), fixtureAggregations(t, "1.7.6")), expected)
+}
+
+func TestParseAggregationsWithoutQuery(t *testing.T) {
+	// without definitions types are unknown and sub-aggregations are told from bucket fields by their values
+	expected := []*Aggregation{
+		{Name: "genres", Buckets: []*Bucket{
+			{Key: "sf", DocCount: 2, Aggregations: []*Aggregation{
+				{Name: "authors", Values: singleValue(2.0)},
+				{Name: "avg_year", Values: singleValue(1963.0)},
+			}},
+			{Key: "fantasy", DocCount: 1, Aggregations: []*Aggregation{
+				{Name: "authors", Values: singleValue(1.0)},
+				{Name: "avg_year", Values: singleValue(1954.0)},
+			}},
+		}},
+		{Name: "pages", Buckets: []*Bucket{{Key: "*-300.0", DocCount: 1}, {Key: "300.0-*", DocCount: 2}}},
+		{Name: "published_percentiles", Values: []MetricValue{{"50.0", "1961-01-01"}, {"95.0", "1965-01-01"}}},
+		{Name: "reviews", DocCount: docCount(4), Aggregations: []*Aggregation{
+			{Name: "ratings", Buckets: []*Bucket{{Key: "5", DocCount: 3}, {Key: "4", DocCount: 1}}},
+		}},
+	}
+	assertAggregations(t, "1.7.6", parseAggregations(nil, fixtureAggregations(t, "1.7.6")), expected)
+}
+
+func TestParseKeyedBuckets(t *testing.T) {
+	definitions := map[string]interface{}{
+		"sizes": map[string]interface{}{"range": map[string]interface{}{"keyed": true}},
+		"p":     map[string]interface{}{"percentiles": map[string]interface{}{"keyed": false}},
+	}
+	var response map[string]interface{}
+	err := json.Unmarshal([]byte(`{
+		"sizes": {"buckets": {"small": {"to": 300.0, "doc_count": 1}, "large": {"from": 300.0, "doc_count": 2}}},
+		"p": {"values": [{"key": 95.0, "value": 1965.0}, {"key": 50.0, "value": null}]}
+	}`), &response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*Aggregation{
+		{Name: "p", Type: "percentiles", Values: []MetricValue{{"50", nil}, {"95", 1965.0}}},
+		{Name: "sizes", Type: "range", Buckets: []*Bucket{{Key: "large", DocCount: 2}, {Key: "small", DocCount: 1}}},
+	}
+	assertAggregations(t, "keyed", parseAggregations(definitions, response), expected)
+}
+
+func assertAggregations(t *testing.T, name string, actual []*Aggregation, expected []*Aggregation) {
+	if len(actual) != len(expected) {
+		t.Fatalf("%s: %d aggregations parsed, expected %d", name, len(actual), len(expected))
+	}
+	for i := range expected {
+		if !reflect.DeepEqual(actual[i], expected[i]) {
+			got, _ := json.Marshal(actual[i])
+			want, _ := json.Marshal(expected[i])
+			t.Errorf("%s: aggregation %s:\n got      %s\n expected %s", name, expected[i].Name, got, want)
+		}
+	}
+}
@@ -0,0 +1,39 @@
+package es
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+)
+
+// AuthOptions contains credentials used to authenticate requests to Elasticsearch cluster.
+// Only one authentication method is used, API key takes precedence over bearer token which takes precedence over basic authentication
+type AuthOptions struct {
+	Username    string
+	Password    string
+	APIKey      string
+	BearerToken string
+}
+
+// apply adds authentication header to the request
+func (a *AuthOptions) apply(req *http.Request) {
+	if a == nil {
+		return
+	}
+	switch {
+	case a.APIKey != "":
+		req.Header.Set("Authorization", "ApiKey "+encodeAPIKey(a.APIKey))
+	case a.BearerToken != "":
+		req.Header.Set("Authorization", "Bearer "+a.BearerToken)
+	case a.Username != "":
+		req.SetBasicAuth(a.Username, a.Password)
+	}
+}
+
+// encodeAPIKey accepts API key either in "id:api_key" form or already base64-encoded and returns encoded key
+func encodeAPIKey(key string) string {
+	if strings.Contains(key, ":") {
+		return base64.StdEncoding.EncodeToString([]byte(key))
+	}
+	return key
+}
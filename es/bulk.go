@@ -29,12 +29,11 @@ type BulkRecord struct {
 
 // BulkExport performs ES scroll search and exports records into "output" channel. Channel is closed after all records are exported
 func (e Es) BulkExport(index string, doc string, query string, output chan *BulkRecord, ctlChan chan error) {
+	e.recordQuery(index, query)
 	if index != "" {
 		index = "/" + index
 	}
-	if doc != "" {
-		doc = "/" + doc
-	}
+	doc = e.typePath(doc)
 	var body map[string]interface{}
 
 	if err := json.Unmarshal([]byte(query), &body); err != nil {
@@ -43,9 +42,13 @@ func (e Es) BulkExport(index string, doc string, query string, output chan *Bulk
 	}
 
 	body["size"] = size
-	if (e.Version[0] == 2 && e.Version[1] >= 1) || e.Version[0] >= 5 {
+	if e.Capabilities.SortByDoc {
 		body["sort"] = []string{"_doc"}
 	}
+	if e.Capabilities.TrackTotalHits {
+		// exact total is needed to report progress
+		body["track_total_hits"] = true
+	}
 
 	bytes, err := json.Marshal(body)
 
@@ -54,22 +57,18 @@ func (e Es) BulkExport(index string, doc string, query string, output chan *Bulk
 		return
 	}
 
-	resp, err := e.getJSONWithBody(fmt.Sprintf("%s%s/_search?scroll=%s", index, doc, scrollLength), string(bytes))
-	if err != nil {
-		ctlChan <- err
-		return
-	}
-	err = checkError(resp)
+	var resp searchResponse
+	err = e.requestInto(http.MethodGet, fmt.Sprintf("%s%s/_search?scroll=%s", index, doc, scrollLength), string(bytes), &resp)
 	if err != nil {
 		ctlChan <- err
 		return
 	}
-	var scrollID string
 
-	if e.Version[0] < 2 {
+	if e.Capabilities.FirstScrollPageEmpty {
 		// versions 1.x do not return records in first scroll request, so we skip to next one immediately
-		scrollID = resp["_scroll_id"].(string)
-		resp, err = e.getJSON(fmt.Sprintf("/_search/scroll?scroll=%s&scroll_id=%s", scrollLength, scrollID))
+		scrollID := resp.ScrollID
+		resp = searchResponse{}
+		err = e.getInto(fmt.Sprintf("/_search/scroll?scroll=%s&scroll_id=%s", scrollLength, scrollID), &resp)
 		if err != nil {
 			ctlChan <- err
 			return
@@ -78,24 +77,14 @@ func (e Es) BulkExport(index string, doc string, query string, output chan *Bulk
 	count := 0
 
 	for {
-		scrollID, ok := resp["_scroll_id"].(string)
-		if !ok {
+		scrollID := resp.ScrollID
+		if scrollID == "" {
 			ctlChan <- fmt.Errorf("Unexpected response: Response does not contain _scroll_id")
 			return
 		}
 
-		hits, ok1 := resp["hits"].(map[string]interface{})
-		totalf, ok2 := hits["total"].(float64)
-		if !(ok1 || ok2) {
-			ctlChan <- fmt.Errorf("Unexpected response: no hits or total")
-			return
-		}
-		total := int(totalf)
-		records := hits["hits"].([]interface{})
-		if !ok {
-			ctlChan <- fmt.Errorf("Unexpected response: No hits")
-			return
-		}
+		total := resp.Hits.Total.Value
+		records := resp.Hits.Hits
 
 		if e.Debug {
 			fmt.Printf("Bulk supplier: Total: %d, Records: %d\n", total, len(records))
@@ -105,15 +94,18 @@ func (e Es) BulkExport(index string, doc string, query string, output chan *Bulk
 			break
 		}
 
-		for _, record := range records {
+		for _, rec := range records {
 			count++
-			rec := record.(map[string]interface{})
+			progress := 100
+			if total > 0 {
+				progress = (count * 100) / total
+			}
 			res := &BulkRecord{
-				ID:       rec["_id"].(string),
-				Index:    rec["_index"].(string),
-				Document: rec["_type"].(string),
+				ID:       rec.str("_id"),
+				Index:    rec.str("_index"),
+				Document: rec.str("_type"),
 				Content:  rec,
-				Progress: (count * 100) / total,
+				Progress: progress,
 			}
 
 			// check if we are still in a game?
@@ -136,10 +128,11 @@ func (e Es) BulkExport(index string, doc string, query string, output chan *Bulk
 			output <- res
 		}
 
-		if e.Version[0] >= 2 {
-			resp, err = e.getJSONWithBody("/_search/scroll", fmt.Sprintf("{\"scroll\":\"%s\",\"scroll_id\":\"%s\"}", scrollLength, scrollID))
+		resp = searchResponse{}
+		if e.Capabilities.ScrollInBody {
+			err = e.requestInto(http.MethodGet, "/_search/scroll", fmt.Sprintf("{\"scroll\":\"%s\",\"scroll_id\":\"%s\"}", scrollLength, scrollID), &resp)
 		} else {
-			resp, err = e.getJSON(fmt.Sprintf("/_search/scroll?scroll=%s&scroll_id=%s", scrollLength, scrollID))
+			err = e.getInto(fmt.Sprintf("/_search/scroll?scroll=%s&scroll_id=%s", scrollLength, scrollID), &resp)
 		}
 		if err != nil {
 			ctlChan <- err
@@ -167,18 +160,19 @@ func (e Es) BulkImport(indexName string, documentName string, idfld string, data
 
 	count := 0
 	for _, recJSON := range inputJSON {
-		var idstr string
+		var id string
 		if idfld != "" {
-			id, ok := recJSON[idfld].(string)
+			var ok bool
+			id, ok = recJSON[idfld].(string)
 			if !ok {
 				return fmt.Errorf("No field '%s' in record", idfld)
 			}
-			idstr = fmt.Sprintf(", \"_id\": \"%s\"", id)
-		} else {
-			idstr = ""
 		}
-		wrtr.WriteString(fmt.Sprintf("{\"index\":{\"_index\" : \"%s\", \"_type\":\"%s\", \"_id\":\"%s\"}}\n",
-			indexName, documentName, idstr))
+		meta, err := e.bulkIndexAction(indexName, documentName, id)
+		if err != nil {
+			return err
+		}
+		wrtr.WriteString(meta)
 		lineBytes, err := json.Marshal(recJSON)
 		if err != nil {
 			return err
@@ -240,22 +234,46 @@ func (e Es) BulkImportNdJSON(data string, errFile string) error {
 
 }
 
+// bulkIndexAction creates "index" action line of bulk request. Document type is only included for clusters
+// which support types, empty id makes ES generate one
+func (e Es) bulkIndexAction(indexName string, documentName string, id string) (string, error) {
+	meta := map[string]string{"_index": indexName}
+	if !e.Capabilities.Typeless {
+		if documentName == "" {
+			return "", errDocumentTypeRequired
+		}
+		meta["_type"] = documentName
+	}
+	if id != "" {
+		meta["_id"] = id
+	}
+	action, err := json.Marshal(map[string]interface{}{"index": meta})
+	if err != nil {
+		return "", err
+	}
+	return string(action) + "\n", nil
+}
+
 func (e Es) sendBulkBody(body string, errFile string) error {
 
-	resp, err := e.requestWithBody(http.MethodPost, "/_bulk", body, "application/x-ndjson")
+	contentType := "application/x-ndjson"
+	if !e.Capabilities.NDJSONContentType {
+		contentType = "application/json"
+	}
+	resp, err := e.requestWithBody(http.MethodPost, "/_bulk", body, contentType)
 
 	if err != nil {
 		return err
 	}
 
-	haveErrors, ok := resp["errors"].(bool)
-	if haveErrors || !ok {
+	var result bulkResponse
+	if err := utils.DictToAnyJ(resp, &result); err != nil || result.Errors == nil || *result.Errors {
 		jerr := writeResponseToFile(resp, errFile)
 		if jerr != nil {
-			return fmt.Errorf("There were errors during export. Failed to parse ES response: " + jerr.Error())
+			return fmt.Errorf("There were errors during the export. Failed to write ES response to " + errFile + ". " + jerr.Error())
 		}
 		if err != nil {
-			return fmt.Errorf("There were errors during the export. Failed to write ES response to " + errFile + ". " + err.Error())
+			return fmt.Errorf("There were errors during export. Failed to parse ES response: " + err.Error())
 		}
 		return fmt.Errorf("There were errors during the export. ES response is saved to " + errFile)
 	}
@@ -268,7 +286,11 @@ func (e Es) bulkInsert(indexName string, documentName string, buffer []*BulkReco
 	wrtr := new(bytes.Buffer)
 
 	for _, rec := range buffer {
-		wrtr.WriteString(fmt.Sprintf("{\"index\":{\"_index\" : \"%s\", \"_type\":\"%s\", \"_id\":\"%s\"}}\n", indexName, documentName, rec.ID))
+		meta, err := e.bulkIndexAction(indexName, documentName, rec.ID)
+		if err != nil {
+			return err
+		}
+		wrtr.WriteString(meta)
 		source := rec.Content["_source"]
 		lineBytes, err := json.Marshal(source)
 
@@ -291,8 +313,8 @@ func (e Es) bulkInsert(indexName string, documentName string, buffer []*BulkReco
 		return err
 	}
 
-	haveErrors, ok := resp["errors"].(bool)
-	if haveErrors || !ok {
+	var result bulkResponse
+	if err := utils.DictToAnyJ(resp, &result); err != nil || result.Errors == nil || *result.Errors {
 		if err != nil {
 			return fmt.Errorf("There were errors during the copying: " + err.Error())
 		}
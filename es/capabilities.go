@@ -0,0 +1,118 @@
+package es
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DistributionElasticsearch identifies Elasticsearch clusters
+	DistributionElasticsearch = "elasticsearch"
+	// DistributionOpenSearch identifies OpenSearch clusters
+	DistributionOpenSearch = "opensearch"
+)
+
+// openSearchBaseVersion is a version of Elasticsearch OpenSearch was forked from. OpenSearch APIs are
+// compatible with this version, except for the changes listed in capabilitiesFor
+var openSearchBaseVersion = []int{7, 10, 2}
+
+// Capabilities describes APIs and request/response formats supported by the cluster.
+// Capabilities are derived from cluster version when connection is established
+type Capabilities struct {
+	// Reindex is true if _reindex API is available (2.3+)
+	Reindex bool
+	// Typeless is true if document APIs accept _doc instead of document type and mappings have no types (7.0+)
+	Typeless bool
+	// SortByDoc is true if scroll can be sorted by _doc for efficiency (2.1+)
+	SortByDoc bool
+	// ScrollInBody is true if scroll id can be passed in request body (2.0+)
+	ScrollInBody bool
+	// FirstScrollPageEmpty is true if initial scroll request does not return any hits (1.x)
+	FirstScrollPageEmpty bool
+	// ForceMergeAPI is a name of force merge API: _optimize before 2.1, _forcemerge since then
+	ForceMergeAPI string
+	// TrackTotalHits is true if search accepts track_total_hits parameter (7.0+)
+	TrackTotalHits bool
+	// PointInTime is true if searches can be paged with search_after within point in time and _shard_doc
+	// tiebreaker (7.12+). OpenSearch point in time API differs from Elasticsearch one and is not used
+	PointInTime bool
+	// CatJSON is true if _cat APIs can return JSON (5.0+)
+	CatJSON bool
+	// NDJSONContentType is true if bulk API accepts application/x-ndjson content type (5.0+)
+	NDJSONContentType bool
+	// ILM is true if Elasticsearch index lifecycle management API is available (6.6+)
+	ILM bool
+	// ISM is true if OpenSearch index state management API is available
+	ISM bool
+}
+
+// parseVersion converts version string like "6.2.4" or "7.0.0-beta1" to slice of numbers
+func parseVersion(version string) ([]int, error) {
+	if idx := strings.IndexAny(version, "-+"); idx >= 0 {
+		version = version[:idx]
+	}
+	var result []int
+	for _, v := range strings.Split(version, ".") {
+		vi, err := strconv.Atoi(v)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to parse Elasticsearch version %s", version)
+		}
+		result = append(result, vi)
+	}
+	for len(result) < 3 {
+		result = append(result, 0)
+	}
+	return result, nil
+}
+
+// versionString converts parsed version back to string
+func versionString(version []int) string {
+	parts := make([]string, len(version))
+	for i, v := range version {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ".")
+}
+
+// versionAtLeast checks if version is equal or greater than major.minor
+func versionAtLeast(version []int, major int, minor int) bool {
+	if len(version) < 2 {
+		return false
+	}
+	return version[0] > major || (version[0] == major && version[1] >= minor)
+}
+
+// capabilitiesForVersion returns capabilities of Elasticsearch cluster of a given version
+func capabilitiesForVersion(version []int) Capabilities {
+	caps := Capabilities{
+		Reindex:              versionAtLeast(version, 2, 3),
+		Typeless:             versionAtLeast(version, 7, 0),
+		SortByDoc:            versionAtLeast(version, 2, 1),
+		ScrollInBody:         versionAtLeast(version, 2, 0),
+		FirstScrollPageEmpty: !versionAtLeast(version, 2, 0),
+		TrackTotalHits:       versionAtLeast(version, 7, 0),
+		PointInTime:          versionAtLeast(version, 7, 12),
+		CatJSON:              versionAtLeast(version, 5, 0),
+		NDJSONContentType:    versionAtLeast(version, 5, 0),
+		ILM:                  versionAtLeast(version, 6, 6),
+	}
+	if versionAtLeast(version, 2, 1) {
+		caps.ForceMergeAPI = "_forcemerge"
+	} else {
+		caps.ForceMergeAPI = "_optimize"
+	}
+	return caps
+}
+
+// capabilitiesFor returns capabilities of a cluster of a given distribution and version.
+// OpenSearch versions are mapped to capabilities of Elasticsearch version it was forked from
+func capabilitiesFor(distribution string, version []int) Capabilities {
+	if distribution != DistributionOpenSearch {
+		return capabilitiesForVersion(version)
+	}
+	caps := capabilitiesForVersion(openSearchBaseVersion)
+	caps.ILM = false
+	caps.ISM = true
+	return caps
+}
@@ -0,0 +1,71 @@
+package es
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersion(t *testing.T) {
+	tests := []struct {
+		version  string
+		expected []int
+	}{
+		{"6.2.4", []int{6, 2, 4}},
+		{"7.0.0-beta1", []int{7, 0, 0}},
+		{"8.11", []int{8, 11, 0}},
+		{"2.11.0+build", []int{2, 11, 0}},
+	}
+	for _, test := range tests {
+		version, err := parseVersion(test.version)
+		if err != nil {
+			t.Errorf("%s: %s", test.version, err)
+			continue
+		}
+		if !reflect.DeepEqual(version, test.expected) {
+			t.Errorf("%s is parsed as %v, expected %v", test.version, version, test.expected)
+		}
+	}
+	if _, err := parseVersion("seven"); err == nil {
+		t.Errorf("Invalid version is parsed without error")
+	}
+}
+
+func TestCapabilitiesFor(t *testing.T) {
+	es := DistributionElasticsearch
+	tests := []struct {
+		distribution string
+		version      []int
+		expected     Capabilities
+	}{
+		{es, []int{1, 7, 6}, Capabilities{FirstScrollPageEmpty: true, ForceMergeAPI: "_optimize"}},
+		{es, []int{2, 0, 0}, Capabilities{ScrollInBody: true, ForceMergeAPI: "_optimize"}},
+		{es, []int{2, 4, 6}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge"}},
+		{es, []int{5, 0, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true}},
+		{es, []int{6, 0, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true}},
+		{es, []int{6, 6, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true, ILM: true}},
+		{es, []int{7, 0, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true, ILM: true, Typeless: true, TrackTotalHits: true}},
+		{es, []int{7, 11, 2}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true, ILM: true, Typeless: true, TrackTotalHits: true}},
+		{es, []int{7, 12, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true, ILM: true, Typeless: true, TrackTotalHits: true, PointInTime: true}},
+		{es, []int{8, 0, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true, ILM: true, Typeless: true, TrackTotalHits: true, PointInTime: true}},
+		{"", []int{7, 17, 9}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true, ForceMergeAPI: "_forcemerge",
+			CatJSON: true, NDJSONContentType: true, ILM: true, Typeless: true, TrackTotalHits: true, PointInTime: true}},
+		// OpenSearch follows Elasticsearch 7.10 regardless of its own version
+		{DistributionOpenSearch, []int{1, 3, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true,
+			ForceMergeAPI: "_forcemerge", CatJSON: true, NDJSONContentType: true, ISM: true, Typeless: true, TrackTotalHits: true}},
+		{DistributionOpenSearch, []int{2, 11, 0}, Capabilities{Reindex: true, SortByDoc: true, ScrollInBody: true,
+			ForceMergeAPI: "_forcemerge", CatJSON: true, NDJSONContentType: true, ISM: true, Typeless: true, TrackTotalHits: true}},
+	}
+	for _, test := range tests {
+		caps := capabilitiesFor(test.distribution, test.version)
+		if !reflect.DeepEqual(caps, test.expected) {
+			t.Errorf("%s %s:\n got      %+v\n expected %+v", test.distribution, versionString(test.version), caps, test.expected)
+		}
+	}
+}
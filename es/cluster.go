@@ -2,6 +2,8 @@ package es
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	yaml "gopkg.in/yaml.v2"
 )
@@ -24,7 +26,7 @@ type ClusterHealth struct {
 	ClusterName             string `json:"cluster_name" yaml:"Cluster Name"`
 	Status                  string `json:"status" yaml:"Status"`
 	NodeCount               int    `json:"number_of_nodes" yaml:"Nodes"`
-	DataNodeCount           int    `json:"number_of_data-nodes" yaml:"Data Nodes"`
+	DataNodeCount           int    `json:"number_of_data_nodes" yaml:"Data Nodes"`
 	ActiveShards            int    `json:"active_shards" yaml:"Active Shards"`
 	ActivePrimaryShards     int    `json:"active_primary_shards" yaml:"Active Primary Shards"`
 	RelocatingShards        int    `json:"relocating_shards" yaml:"Relocating Shards"`
@@ -37,41 +39,57 @@ type ClusterHealth struct {
 
 // ShortNodeInfo holds minimal node information
 type ShortNodeInfo struct {
-	UUID             string
-	Name             string
-	Host             string
-	IP               string
-	TransportAddress string
+	UUID             string `json:"id"`
+	Name             string `json:"name"`
+	Host             string `json:"host"`
+	IP               string `json:"ip"`
+	TransportAddress string `json:"transport_address"`
+	HTTPAddress      string `json:"http_address"`
 }
 
-// ListNodes returns slice of *ShortNodeInfo structs containing node information
+// ListNodes returns slice of *ShortNodeInfo structs containing node information, sorted by node name
 func (e Es) ListNodes() ([]*ShortNodeInfo, error) {
-	body, err := e.getJSON("/_nodes")
+	var body nodesResponse
+	err := e.getInto("/_nodes", &body)
 
 	if err != nil {
 		return nil, err
 	}
 
-	nodes := body["nodes"].(map[string]interface{})
-
-	result := make([]*ShortNodeInfo, len(nodes))
+	result := make([]*ShortNodeInfo, len(body.Nodes))
 	idx := 0
-	for node := range nodes {
-		nodeInfo := nodes[node].(map[string]interface{})
+	for node, nodeInfo := range body.Nodes {
+		httpAddress := nodeInfo.HTTP.PublishAddress
+		if httpAddress == "" {
+			httpAddress = nodeInfo.HTTPAddress
+		}
 
 		sni := &ShortNodeInfo{
 			UUID:             node,
-			Name:             nodeInfo["name"].(string),
-			TransportAddress: nodeInfo["transport_address"].(string),
-			Host:             nodeInfo["host"].(string),
-			IP:               nodeInfo["ip"].(string),
+			Name:             nodeInfo.Name,
+			TransportAddress: nodeInfo.TransportAddress,
+			Host:             nodeInfo.Host,
+			IP:               nodeInfo.IP,
+			HTTPAddress:      stripInetAddress(httpAddress),
 		}
 		result[idx] = sni
 		idx++
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
 	return result, nil
 }
 
+// stripInetAddress converts addresses like "inet[hostname/10.0.0.1:9200]" or "hostname/10.0.0.1:9200" to "10.0.0.1:9200".
+// ES 1.x returns addresses in this format
+func stripInetAddress(address string) string {
+	address = strings.TrimPrefix(address, "inet[")
+	address = strings.TrimSuffix(address, "]")
+	if idx := strings.LastIndex(address, "/"); idx >= 0 {
+		address = address[idx+1:]
+	}
+	return address
+}
+
 // GetSettings retrieves cluster settings
 func (e Es) GetSettings() (map[string]interface{}, error) {
 	body, err := e.getJSON("/_cluster/settings")
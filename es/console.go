@@ -0,0 +1,195 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ConsoleRequest is a single request of Kibana Dev Tools console script
+type ConsoleRequest struct {
+	Method string
+	Path   string
+	// Body is compacted JSON. Requests with several JSON documents in the body, like _bulk, have NDJSON body
+	Body string
+	// Line is a number of script line where request starts
+	Line int
+}
+
+var (
+	consoleRequestLine = regexp.MustCompile(`(?i)^\s*(GET|POST|PUT|DELETE|HEAD)\s+(.*)$`)
+	// comment of request line starts with # or // after whitespace
+	consoleLineComment = regexp.MustCompile(`(^|\s+)(#|//).*$`)
+)
+
+const tripleQuote = `"""`
+
+// ParseConsole parses script in Kibana Dev Tools console format: request line with method and path followed by
+// optional JSON body. Lines starting with # or //, comments at the end of request line and /* */ comments are ignored,
+// triple-quoted strings are converted to JSON strings. Spaces in the path are escaped
+func ParseConsole(script string) ([]*ConsoleRequest, error) {
+	var result []*ConsoleRequest
+	var current *ConsoleRequest
+	var body []string
+
+	flush := func() error {
+		if current == nil {
+			return nil
+		}
+		normalized, err := normalizeConsoleBody(strings.Join(body, "\n"))
+		if err != nil {
+			return fmt.Errorf("Invalid body of %s %s at line %d: %s", current.Method, current.Path, current.Line, err.Error())
+		}
+		current.Body = normalized
+		result = append(result, current)
+		return nil
+	}
+
+	inTripleQuote := false
+	inComment := false
+	for i, line := range strings.Split(script, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if inComment {
+			end := strings.Index(line, "*/")
+			if end < 0 {
+				continue
+			}
+			inComment = false
+			line = line[end+2:]
+		}
+		if !inTripleQuote {
+			if match := consoleRequestLine.FindStringSubmatch(line); match != nil {
+				if err := flush(); err != nil {
+					return nil, err
+				}
+				path := strings.TrimSpace(consoleLineComment.ReplaceAllString(match[2], ""))
+				if path == "" {
+					return nil, fmt.Errorf("Path expected at line %d", i+1)
+				}
+				current = &ConsoleRequest{Method: strings.ToUpper(match[1]), Path: strings.Replace(path, " ", "%20", -1), Line: i + 1}
+				body = nil
+				continue
+			}
+			trimmed := strings.TrimSpace(line)
+			if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, "//") {
+				continue
+			}
+			if strings.HasPrefix(trimmed, "/*") {
+				// comment lines are dropped, so that commented out requests are not parsed
+				end := strings.Index(trimmed, "*/")
+				if end < 0 {
+					inComment = true
+					continue
+				}
+				if strings.TrimSpace(trimmed[end+2:]) == "" {
+					continue
+				}
+			}
+			if current == nil {
+				return nil, fmt.Errorf("Request line expected at line %d", i+1)
+			}
+		}
+		if strings.Count(line, tripleQuote)%2 == 1 {
+			inTripleQuote = !inTripleQuote
+		}
+		body = append(body, line)
+	}
+	if inTripleQuote {
+		return nil, fmt.Errorf("Unterminated triple-quoted string in %s %s at line %d", current.Method, current.Path, current.Line)
+	}
+	if inComment {
+		return nil, fmt.Errorf("Unterminated comment")
+	}
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// normalizeConsoleBody removes comments, converts triple-quoted strings and compacts every JSON document of the body
+func normalizeConsoleBody(text string) (string, error) {
+	cleaned, err := stripConsoleSyntax(text)
+	if err != nil {
+		return "", err
+	}
+
+	var documents []string
+	decoder := json.NewDecoder(strings.NewReader(cleaned))
+	for {
+		var document json.RawMessage
+		err := decoder.Decode(&document)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		var compacted bytes.Buffer
+		if err := json.Compact(&compacted, document); err != nil {
+			return "", err
+		}
+		documents = append(documents, compacted.String())
+	}
+
+	switch len(documents) {
+	case 0:
+		return "", nil
+	case 1:
+		return documents[0], nil
+	}
+	return strings.Join(documents, "\n") + "\n", nil
+}
+
+// stripConsoleSyntax converts console-specific syntax to plain JSON
+func stripConsoleSyntax(text string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(text); {
+		rest := text[i:]
+		switch {
+		case strings.HasPrefix(rest, tripleQuote):
+			end := strings.Index(rest[len(tripleQuote):], tripleQuote)
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated triple-quoted string")
+			}
+			quoted, err := json.Marshal(rest[len(tripleQuote) : len(tripleQuote)+end])
+			if err != nil {
+				return "", err
+			}
+			out.Write(quoted)
+			i += end + 2*len(tripleQuote)
+		case rest[0] == '"':
+			// regular JSON strings are copied as is
+			j := 1
+			for j < len(rest) && rest[j] != '"' {
+				if rest[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(rest) {
+				return "", fmt.Errorf("Unterminated string")
+			}
+			out.WriteString(rest[:j+1])
+			i += j + 1
+		case strings.HasPrefix(rest, "//"):
+			end := strings.IndexByte(rest, '\n')
+			if end < 0 {
+				end = len(rest)
+			}
+			i += end
+		case strings.HasPrefix(rest, "/*"):
+			end := strings.Index(rest, "*/")
+			if end < 0 {
+				return "", fmt.Errorf("Unterminated comment")
+			}
+			i += end + 2
+		default:
+			out.WriteByte(rest[0])
+			i++
+		}
+	}
+	return out.String(), nil
+}
@@ -0,0 +1,82 @@
+package es
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConsole(t *testing.T) {
+	tests := []struct {
+		name     string
+		script   string
+		expected []ConsoleRequest
+	}{
+		{"request without body", "GET _cat/indices?v\n",
+			[]ConsoleRequest{{Method: "GET", Path: "_cat/indices?v", Line: 1}}},
+		{"lowercase method and json body", "put /books\n{\n  \"settings\": {\"number_of_shards\": 1}\n}\n",
+			[]ConsoleRequest{{Method: "PUT", Path: "/books", Body: `{"settings":{"number_of_shards":1}}`, Line: 1}}},
+		{"several requests", "GET /\n\nDELETE /books\nHEAD /books\n",
+			[]ConsoleRequest{{Method: "GET", Path: "/", Line: 1}, {Method: "DELETE", Path: "/books", Line: 3},
+				{Method: "HEAD", Path: "/books", Line: 4}}},
+		{"hash and slash comment lines", "# create index\nPUT /books\n// body follows\n{}\n",
+			[]ConsoleRequest{{Method: "PUT", Path: "/books", Body: "{}", Line: 2}}},
+		{"comments inside body", "POST /books/_search\n{\n  // match everything\n  \"query\": {\"match_all\": {}} /* all */\n}\n",
+			[]ConsoleRequest{{Method: "POST", Path: "/books/_search", Body: `{"query":{"match_all":{}}}`, Line: 1}}},
+		{"comment markers inside strings", "POST /books/_doc\n{\"url\": \"http://example.com/*x*/\", \"tag\": \"#1\"}\n",
+			[]ConsoleRequest{{Method: "POST", Path: "/books/_doc", Body: `{"url":"http://example.com/*x*/","tag":"#1"}`, Line: 1}}},
+		{"block comment between requests", "/* GET /disabled\nDELETE /disabled */\nGET /books\n",
+			[]ConsoleRequest{{Method: "GET", Path: "/books", Line: 3}}},
+		{"triple-quoted string", "POST /_scripts/s\n{\"script\": {\"source\": \"\"\"\n  ctx._source.n += 1; \"quoted\"\n\"\"\"}}\n",
+			[]ConsoleRequest{{Method: "POST", Path: "/_scripts/s",
+				Body: `{"script":{"source":"\n  ctx._source.n += 1; \"quoted\"\n"}}`, Line: 1}}},
+		{"triple-quoted request line", "POST /_scripts/s\n{\"source\": \"\"\"\nGET /not-a-request\n\"\"\"}\n",
+			[]ConsoleRequest{{Method: "POST", Path: "/_scripts/s", Body: `{"source":"\nGET /not-a-request\n"}`, Line: 1}}},
+		{"ndjson body", "POST _bulk\n{\"index\": {\"_index\": \"books\"}}\n{\"title\": \"Dune\"}\n",
+			[]ConsoleRequest{{Method: "POST", Path: "_bulk", Body: "{\"index\":{\"_index\":\"books\"}}\n{\"title\":\"Dune\"}\n", Line: 1}}},
+		{"trailing comments on request line", "GET /books/_search # hash\nGET /_cat/indices?v // slashes\n",
+			[]ConsoleRequest{{Method: "GET", Path: "/books/_search", Line: 1}, {Method: "GET", Path: "/_cat/indices?v", Line: 2}}},
+		{"spaces in query string", "GET /books/_search?q=title:dune messiah\n",
+			[]ConsoleRequest{{Method: "GET", Path: "/books/_search?q=title:dune%20messiah", Line: 1}}},
+		{"windows line endings", "GET /books\r\n{}\r\n",
+			[]ConsoleRequest{{Method: "GET", Path: "/books", Body: "{}", Line: 1}}},
+	}
+	for _, test := range tests {
+		requests, err := ParseConsole(test.script)
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+		var parsed []ConsoleRequest
+		for _, req := range requests {
+			parsed = append(parsed, *req)
+		}
+		if !reflect.DeepEqual(parsed, test.expected) {
+			t.Errorf("%s: parsed as %+v, expected %+v", test.name, parsed, test.expected)
+		}
+	}
+}
+
+func TestParseConsoleErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		error  string
+	}{
+		{"body without request", "{\"query\": {}}\n", "Request line expected at line 1"},
+		{"unterminated triple quote", "POST /_scripts/s\n{\"source\": \"\"\"\nctx._source.n += 1\n", "Unterminated triple-quoted string"},
+		{"unterminated comment", "GET /\n/* comment\n", "Unterminated comment"},
+		{"invalid json", "PUT /books\n{\"settings\": }\n", "Invalid body of PUT /books at line 1"},
+		{"missing path", "GET // no path\n", "Path expected at line 1"},
+	}
+	for _, test := range tests {
+		_, err := ParseConsole(test.script)
+		if err == nil {
+			t.Errorf("%s: parsed without error", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.error) {
+			t.Errorf("%s: error %q, expected %q", test.name, err, test.error)
+		}
+	}
+}
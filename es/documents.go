@@ -2,40 +2,46 @@ package es
 
 import (
 	"fmt"
-	"shelastic/utils"
+	"net/http"
+	"net/url"
+	"sort"
 )
 
-//DocumentProperty is a container for simple property information, it includes Name and Type
+// DocumentProperty is a container for simple property information, it includes Name and Type
 type DocumentProperty struct {
-	Name string
-	Type string
+	Name string `json:"name"`
+	Type string `json:"type"`
 }
 
-//SearchResult contains results for a simple search query
+// SearchResult contains results for a simple search query
 type SearchResult struct {
+	// From is an offset of the first hit in the whole result
+	From  int
 	Total int
-	Hits  []string
+	// TotalRelation is "gte" if Total is a lower bound of the number of hits and "eq" if it is exact
+	TotalRelation string
+	// Hits are search hits as returned by Elasticsearch, including metadata fields and _source
+	Hits []map[string]interface{}
+	// Aggregations are parsed results of aggregations of the query, RawAggregations are the same results as returned
+	// by Elasticsearch
+	Aggregations    []*Aggregation
+	RawAggregations map[string]interface{}
 }
 
+// typelessDocument is a name of the only document type in ES 7.x+ indices
+const typelessDocument = "_doc"
+
+var errDocumentTypeRequired = fmt.Errorf("Document type must be specified for Elasticsearch versions before 7.0")
+
 // ListDocuments lists names of the documents in the index
 func (e Es) ListDocuments(index string) ([]string, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/_mapping", index))
-
+	body, err := e.indexMappings(index, fmt.Sprintf("/%s/_mapping", index))
 	if err != nil {
 		return nil, err
 	}
 
-	err = checkError(body)
-	if err != nil {
-		return nil, fmt.Errorf("Index %s failed: %s", index, err.Error())
-	}
-
-	index = e.resolveAlias(index)
-
-	body = body[index].(map[string]interface{})
-
-	if mapping, ok := body["mappings"]; ok {
-		body = mapping.(map[string]interface{})
+	if e.Capabilities.Typeless {
+		return []string{typelessDocument}, nil
 	}
 
 	result := make([]string, len(body))
@@ -47,40 +53,52 @@ func (e Es) ListDocuments(index string) ([]string, error) {
 	return result, nil
 }
 
-//ListProperties lists properties of a given document of a given index
-func (e Es) ListProperties(index string, doc string) ([]DocumentProperty, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/_mapping/%s", index, doc))
+// indexMappings retrieves mappings of the index. Returned map contains mappings of every document type in the index
+func (e Es) indexMappings(index string, path string) (map[string]interface{}, error) {
+	var response mappingsResponse
+	err := e.getInto(path, &response)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = checkError(body)
-	if err != nil {
-		return nil, fmt.Errorf("Index %s failed: %s", index, err.Error())
-	}
-
 	index = e.resolveAlias(index)
 
-	body = body[index].(map[string]interface{})
+	body, ok := response[index]
+	if !ok {
+		return nil, fmt.Errorf("No mappings for index %s", index)
+	}
 
-	if mapping, ok := body["mappings"]; ok {
-		body = mapping.(map[string]interface{})
+	if mapping, ok := body["mappings"].(map[string]interface{}); ok {
+		body = mapping
 	}
+	return body, nil
+}
 
-	if document, ok := body[doc]; ok {
-		body = document.(map[string]interface{})["properties"].(map[string]interface{})
-	} else {
-		return nil, fmt.Errorf("No '%s' document in mapping", doc)
+// ListProperties lists properties of a given document of a given index
+// Document type is ignored for typeless clusters
+func (e Es) ListProperties(index string, doc string) ([]DocumentProperty, error) {
+	document, err := e.documentMapping(index, doc)
+	if err != nil {
+		return nil, err
+	}
+	properties, ok := document["properties"].(map[string]interface{})
+	if !ok {
+		if e.Capabilities.Typeless {
+			// index without mapped fields
+			return []DocumentProperty{}, nil
+		}
+		return nil, fmt.Errorf("No properties in '%s' document mapping", doc)
 	}
 
-	result := make([]DocumentProperty, len(body))
+	result := make([]DocumentProperty, len(properties))
 	i := 0
-	for field := range body {
-		fldType := body[field].(map[string]interface{})
-		types, ok := fldType["type"].(string)
-		if !ok {
-			types = "<complex>"
+	for field := range properties {
+		types := "<complex>"
+		if fldType, ok := properties[field].(map[string]interface{}); ok {
+			if t, ok := fldType["type"].(string); ok {
+				types = t
+			}
 		}
 		v := DocumentProperty{
 			Name: field,
@@ -92,150 +110,232 @@ func (e Es) ListProperties(index string, doc string) ([]DocumentProperty, error)
 	return result, nil
 }
 
-// GetDocument reads document by id and returns string with YAML-formatted document
-func (e Es) GetDocument(index string, docType string, id string) (string, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/%s/%s", index, docType, id))
-
+// documentMapping retrieves mapping of a given document of a given index
+// Document type is ignored for typeless clusters
+func (e Es) documentMapping(index string, doc string) (map[string]interface{}, error) {
+	if e.Capabilities.Typeless {
+		return e.indexMappings(index, fmt.Sprintf("/%s/_mapping", index))
+	}
+	body, err := e.indexMappings(index, fmt.Sprintf("/%s/_mapping/%s", index, doc))
 	if err != nil {
-		return "", err
+		return nil, err
+	}
+	document, ok := body[doc].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("No '%s' document in mapping", doc)
 	}
+	return document, nil
+}
 
-	err = checkError(body)
+// ListFields lists all fields of a given document of a given index, sorted by name. Fields of inner objects and
+// multi-fields are included, their names are full paths, e.g. user.name or title.keyword. Inner objects are listed
+// with type object or nested. If document type is not specified for clusters before 7.0, fields of all document
+// types are listed
+func (e Es) ListFields(index string, doc string) ([]DocumentProperty, error) {
+	var documents []map[string]interface{}
+	if e.Capabilities.Typeless || doc != "" {
+		document, err := e.documentMapping(index, doc)
+		if err != nil {
+			return nil, err
+		}
+		documents = append(documents, document)
+	} else {
+		body, err := e.indexMappings(index, fmt.Sprintf("/%s/_mapping", index))
+		if err != nil {
+			return nil, err
+		}
+		for _, document := range body {
+			if mapping, ok := document.(map[string]interface{}); ok {
+				documents = append(documents, mapping)
+			}
+		}
+	}
+
+	fields := make(map[string]string)
+	for _, document := range documents {
+		collectFields("", document, fields)
+	}
+	result := make([]DocumentProperty, 0, len(fields))
+	for name, fieldType := range fields {
+		result = append(result, DocumentProperty{Name: name, Type: fieldType})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+// collectFields adds fields from properties of the mapping to result, which maps full field path to field type
+func collectFields(prefix string, mapping map[string]interface{}, result map[string]string) {
+	properties, _ := mapping["properties"].(map[string]interface{})
+	for name, value := range properties {
+		field, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		path := prefix + name
+		fieldType, _ := field["type"].(string)
+		if fieldType == "" {
+			fieldType = "object"
+		}
+		result[path] = fieldType
+		collectFields(path+".", field, result)
+
+		multiFields, _ := field["fields"].(map[string]interface{})
+		for subName, subValue := range multiFields {
+			if subField, ok := subValue.(map[string]interface{}); ok {
+				subType, _ := subField["type"].(string)
+				result[path+"."+subName] = subType
+			}
+		}
+	}
+}
+
+// documentPath builds path to document API endpoint. Endpoint is one of "_doc", "_create" or "_update".
+// ES 7.x+ use typeless paths like /{index}/_create/{id}, older versions require document type,
+// e.g. /{index}/{type}/{id}/_create
+func (e Es) documentPath(index string, docType string, endpoint string, id string) (string, error) {
+	if e.Capabilities.Typeless {
+		if id == "" {
+			return fmt.Sprintf("/%s/%s", index, endpoint), nil
+		}
+		return fmt.Sprintf("/%s/%s/%s", index, endpoint, url.PathEscape(id)), nil
+	}
+	if docType == "" {
+		return "", errDocumentTypeRequired
+	}
+	path := fmt.Sprintf("/%s/%s", index, docType)
+	if id != "" {
+		path += "/" + url.PathEscape(id)
+	}
+	if endpoint != typelessDocument {
+		path += "/" + endpoint
+	}
+	return path, nil
+}
+
+// typePath returns document type part of the search path. Document types are not used in ES 7.x+ searches
+func (e Es) typePath(docType string) string {
+	if docType == "" || e.Capabilities.Typeless {
+		return ""
+	}
+	return "/" + docType
+}
+
+// GetDocument reads document by id and returns it with metadata fields and _source
+func (e Es) GetDocument(index string, docType string, id string) (map[string]interface{}, error) {
+	path, err := e.documentPath(index, docType, typelessDocument, id)
 	if err != nil {
-		return "", fmt.Errorf("Index %s failed: %s", index, err.Error())
+		return nil, err
 	}
+	body, err := e.getJSON(path)
 
-	result, err := utils.MapToYaml(body)
+	if err != nil {
+		return nil, err
+	}
 
+	err = checkError(body)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	return result, nil
+	return body, nil
 }
 
 // DeleteDocument deletes document by id
 func (e Es) DeleteDocument(index string, docType string, id string) error {
-	body, err := e.delete(fmt.Sprintf("/%s/%s/%s", index, docType, id))
-
+	path, err := e.documentPath(index, docType, typelessDocument, id)
 	if err != nil {
 		return err
 	}
+	var body documentResponse
+	err = e.requestInto(http.MethodDelete, path, "", &body)
 
-	err = checkError(body)
 	if err != nil {
-		return fmt.Errorf("Index %s failed: %s", index, err.Error())
+		return err
 	}
 
-	result, ok := body["result"].(string)
-
-	if ok && result == "deleted" {
+	if body.Result == "deleted" || (body.Result == "" && body.Found != nil && *body.Found) {
 		return nil
 	}
 
-	if ok {
-		return fmt.Errorf("Failed to delete document: " + result)
+	if body.Result != "" {
+		return fmt.Errorf("Failed to delete document: " + body.Result)
 	}
 	return fmt.Errorf("Failed to parse response from server")
 }
 
-//PutDocument stores JSON document in index/doc with provided id
+// PutDocument stores JSON document in index/doc with provided id
 func (e Es) PutDocument(index string, doc string, id string, reqBody string) (string, error) {
+	method := http.MethodPut
 	if id == "-" {
+		// documents with automatically generated ids must be posted
 		id = ""
+		method = http.MethodPost
 	}
-	body, err := e.putJSON(fmt.Sprintf("/%s/%s/%s", index, doc, id), reqBody)
+	path, err := e.documentPath(index, doc, typelessDocument, id)
 	if err != nil {
 		return "failed", err
 	}
-	err = checkError(body)
+	return e.storeDocument(method, path, reqBody, "created")
+}
+
+// CreateDocument stores JSON document in index/doc with provided id. Fails if document with the same id exists
+func (e Es) CreateDocument(index string, doc string, id string, reqBody string) (string, error) {
+	if id == "-" {
+		return e.PutDocument(index, doc, id, reqBody)
+	}
+	path, err := e.documentPath(index, doc, "_create", id)
 	if err != nil {
 		return "failed", err
 	}
-	result, ok := body["result"].(string)
-	if !ok {
-		return "failed", fmt.Errorf("Failed to parse response")
-	}
-	return result, nil
+	return e.storeDocument(http.MethodPut, path, reqBody, "created")
 }
 
-//Search function implements ES URL search
-func (e Es) Search(index string, doc string, query string) (*SearchResult, error) {
-	if doc != "" {
-		doc = "/" + doc
-	}
-	body, err := e.getJSON(fmt.Sprintf("/%s%s/_search?q=%s", index, doc, query))
-
+// UpdateDocument merges JSON document into existing document with provided id
+func (e Es) UpdateDocument(index string, doc string, id string, reqBody string) (string, error) {
+	path, err := e.documentPath(index, doc, "_update", id)
 	if err != nil {
-		return nil, err
+		return "failed", err
 	}
+	return e.storeDocument(http.MethodPost, path, fmt.Sprintf("{\"doc\": %s}", reqBody), "updated")
+}
 
-	err = checkError(body)
+// storeDocument executes document index, create or update request and returns its outcome.
+// Outcome is reported by ES 5.x+ in "result" field, older versions only report "created" flag for index requests,
+// defaultResult is returned when neither is present
+func (e Es) storeDocument(method string, path string, reqBody string, defaultResult string) (string, error) {
+	var body documentResponse
+	err := e.requestInto(method, path, reqBody, &body)
 	if err != nil {
-		return nil, err
+		return "failed", err
 	}
-
-	allhits, ok := body["hits"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("Failed to retrieve hits from response")
+	if body.Result != "" {
+		return body.Result, nil
 	}
-
-	total := int(allhits["total"].(float64))
-	hits := allhits["hits"].([]interface{})
-
-	result := make([]string, len(hits))
-	i := 0
-	for _, hit := range hits {
-		record, err := utils.MapToYaml(hit)
-		if err != nil {
-			return nil, err
+	if body.Created != nil {
+		if *body.Created {
+			return "created", nil
 		}
-		result[i] = record
-		i++
+		return "updated", nil
 	}
-
-	return &SearchResult{
-		Total: total,
-		Hits:  result,
-	}, nil
+	return defaultResult, nil
 }
 
-//Query function implements ES request body search
-func (e Es) Query(index string, doc string, query string) (*SearchResult, error) {
-	if doc != "" {
-		doc = "/" + doc
-	}
-	body, err := e.getJSONWithBody(fmt.Sprintf("%s%s/_search", index, doc), query)
-
-	if err != nil {
-		return nil, err
+// recordQuery notifies query recorder, if there is one
+func (e Es) recordQuery(index string, query string) {
+	if e.OnQuery != nil {
+		e.OnQuery(e.ClusterName, index, query)
 	}
+}
 
-	err = checkError(body)
-	if err != nil {
-		return nil, err
-	}
-
-	allhits, ok := body["hits"].(map[string]interface{})
-	if !ok {
-		return nil, fmt.Errorf("Failed to retrieve hits from response")
-	}
-
-	total := int(allhits["total"].(float64))
-	hits := allhits["hits"].([]interface{})
-
-	result := make([]string, len(hits))
-	i := 0
-	for _, hit := range hits {
-		record, err := utils.MapToYaml(hit)
-		if err != nil {
-			return nil, err
-		}
-		result[i] = record
-		i++
+func newSearchResult(body *searchResponse) *SearchResult {
+	result := make([]map[string]interface{}, len(body.Hits.Hits))
+	for i, hit := range body.Hits.Hits {
+		result[i] = hit
 	}
 
 	return &SearchResult{
-		Total: total,
-		Hits:  result,
-	}, nil
+		Total:           body.Hits.Total.Value,
+		TotalRelation:   body.Hits.Total.Relation,
+		Hits:            result,
+		RawAggregations: body.Aggregations,
+	}
 }
@@ -0,0 +1,175 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// ErrorCause describes a single cause of Elasticsearch error
+type ErrorCause struct {
+	Type     string      `json:"type"`
+	Reason   string      `json:"reason"`
+	Index    string      `json:"index"`
+	CausedBy *ErrorCause `json:"caused_by"`
+}
+
+// ShardFailure describes failure of a query on a single shard
+type ShardFailure struct {
+	Shard  int         `json:"shard"`
+	Index  string      `json:"index"`
+	Node   string      `json:"node"`
+	Reason *ErrorCause `json:"reason"`
+}
+
+// Error is an error reported by Elasticsearch. It contains HTTP status of the response along with
+// error type, reason and causes as reported by the cluster
+type Error struct {
+	Status        int
+	Type          string
+	Reason        string
+	Index         string
+	RootCause     []ErrorCause
+	CausedBy      *ErrorCause
+	ShardFailures []ShardFailure
+}
+
+// errorBody mirrors "error" object of Elasticsearch 2.x+ responses
+type errorBody struct {
+	ErrorCause
+	RootCause     []ErrorCause   `json:"root_cause"`
+	FailedShards  []ShardFailure `json:"failed_shards"`
+	ShardFailures []ShardFailure `json:"shard_failures"`
+}
+
+// legacyErrorType extracts exception name from ES 1.x error strings like "IndexMissingException[[idx] missing]"
+var legacyErrorType = regexp.MustCompile(`^([A-Za-z0-9_.]+Exception)\[(.*)\]$`)
+
+var camelCaseBoundary = regexp.MustCompile(`([a-z0-9])([A-Z])`)
+
+func (e *Error) Error() string {
+	var buffer bytes.Buffer
+	if e.Reason != "" {
+		buffer.WriteString(e.Reason)
+	} else if e.Type != "" {
+		buffer.WriteString(e.Type)
+	} else {
+		buffer.WriteString(http.StatusText(e.Status))
+	}
+	for cause := e.CausedBy; cause != nil; cause = cause.CausedBy {
+		buffer.WriteString(fmt.Sprintf(", caused by '%s'", cause.Reason))
+	}
+	return buffer.String()
+}
+
+// IsErrorType checks if err is an Elasticsearch error of a given type, for example "index_not_found_exception"
+func IsErrorType(err error, errorType string) bool {
+	if esErr, ok := AsError(err); ok {
+		return esErr.Type == errorType
+	}
+	return false
+}
+
+// AsError checks if err is an Elasticsearch error and returns it
+func AsError(err error) (*Error, bool) {
+	var esErr *Error
+	if errors.As(err, &esErr) {
+		return esErr, true
+	}
+	return nil, false
+}
+
+// newError creates Error from HTTP status and decoded response body. If body does not contain error description
+// then reason is derived from HTTP status
+func newError(status int, body map[string]interface{}) *Error {
+	result := parseError(body)
+	if result == nil {
+		result = &Error{Reason: http.StatusText(status)}
+		if outcome, ok := body["result"].(string); ok {
+			result.Reason = outcome
+		}
+	}
+	if result.Status == 0 {
+		result.Status = status
+	}
+	return result
+}
+
+// parseError reads error description from response body. Returns nil if body does not contain an error
+func parseError(body map[string]interface{}) *Error {
+	errorValue, ok := body["error"]
+	if !ok || errorValue == nil {
+		return nil
+	}
+	result := &Error{}
+	if status, ok := body["status"].(float64); ok {
+		result.Status = int(status)
+	}
+
+	switch value := errorValue.(type) {
+	case string:
+		// ES 1.x reports errors as strings
+		if match := legacyErrorType.FindStringSubmatch(value); match != nil {
+			result.Type = legacyTypeName(match[1])
+			result.Reason = match[2]
+		} else {
+			result.Reason = value
+		}
+	case map[string]interface{}:
+		data, err := json.Marshal(value)
+		if err != nil {
+			result.Reason = fmt.Sprintf("%v", value)
+			return result
+		}
+		var parsed errorBody
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			result.Reason = string(data)
+			return result
+		}
+		result.Type = parsed.Type
+		result.Reason = parsed.Reason
+		result.Index = parsed.Index
+		result.CausedBy = parsed.CausedBy
+		result.RootCause = parsed.RootCause
+		result.ShardFailures = append(parsed.FailedShards, parsed.ShardFailures...)
+	default:
+		result.Reason = fmt.Sprintf("%v", value)
+	}
+	return result
+}
+
+// legacyTypeName converts ES 1.x exception class name to snake case as used by newer versions,
+// e.g. "IndexMissingException" becomes "index_missing_exception"
+func legacyTypeName(className string) string {
+	if idx := strings.LastIndex(className, "."); idx >= 0 {
+		className = className[idx+1:]
+	}
+	return strings.ToLower(camelCaseBoundary.ReplaceAllString(className, "${1}_${2}"))
+}
+
+// checkError returns Error if response body contains error description
+func checkError(body map[string]interface{}) error {
+	if result := parseError(body); result != nil {
+		return result
+	}
+	return nil
+}
+
+// checkResponse converts unsuccessful HTTP responses to Error
+func checkResponse(resp *http.Response, bodyBytes []byte) error {
+	if resp.StatusCode < http.StatusBadRequest {
+		return nil
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		body = make(map[string]interface{})
+		if len(bodyBytes) > 0 {
+			body["error"] = strings.TrimSpace(string(bodyBytes))
+		}
+	}
+	return newError(resp.StatusCode, body)
+}
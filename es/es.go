@@ -1,71 +1,120 @@
 package es
 
 import (
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
-	"strconv"
-	"strings"
+	"time"
+)
+
+const (
+	dialTimeout         = 10 * time.Second
+	tlsHandshakeTimeout = 10 * time.Second
+	// defaultResponseTimeout is used when ConnectOptions do not specify ResponseTimeout
+	defaultResponseTimeout = 60 * time.Second
 )
 
 // PingResponse contains cluster name and ES version - response to ping command
 type PingResponse struct {
 	ClusterName string
 	Version     string
+	// Distribution is either "elasticsearch" or "opensearch"
+	Distribution string
 }
 
 // Es holds connection information for Elasticsearch cluster
 type Es struct {
-	host        string
-	esURL       *url.URL
-	client      *http.Client
+	hosts  *hostPool
+	client *http.Client
+	// waitClient executes requests which wait for completion of long-running operations and has no response timeout
+	waitClient  *http.Client
+	auth        *AuthOptions
 	ClusterName string
 	Version     []int
-	aliases     map[string]string
-	Nodes       map[string]*ShortNodeInfo
-	Debug       bool
+	// Distribution is either "elasticsearch" or "opensearch"
+	Distribution string
+	// Capabilities describes APIs supported by the cluster
+	Capabilities Capabilities
+	aliases      *aliasCache
+	Nodes        map[string]*ShortNodeInfo
+	Debug        bool
+	// Tracer records requests and responses if it is set
+	Tracer *Tracer
+	// OnQuery is notified about every query executed with NewQueryPager and BulkExport if it is set
+	OnQuery     QueryRecorder
 	ActiveIndex string
 }
 
+// QueryRecorder receives name of the cluster, index and body of executed query
+type QueryRecorder func(cluster string, index string, query string)
+
+// ConnectOptions contains optional connection parameters
+type ConnectOptions struct {
+	TLS  *TLSOptions
+	Auth *AuthOptions
+	// Sniff enables discovery of cluster nodes. HTTP addresses of all the nodes in the cluster are added to the list of hosts
+	Sniff bool
+	// Tracer records requests made while connecting and during the session
+	Tracer *Tracer
+	// OnQuery is notified about queries executed during the session
+	OnQuery QueryRecorder
+	// ResponseTimeout limits time to wait for response headers after request is sent. Node which does not respond
+	// in time is marked dead. Requests waiting for completion of an operation are not limited
+	ResponseTimeout time.Duration
+}
+
 // Connect initiates connection to an Elasticsearch cluster node specified by host argument
-func Connect(host string) (*Es, *PingResponse, error) {
-	if !strings.Contains(host, ":") {
-		host = host + ":9200"
+// Host argument may contain comma-separated list of hosts, in that case requests are distributed between all of them.
+// If TLS options are provided and host does not specify the scheme, https is used
+func Connect(host string, options *ConnectOptions) (*Es, *PingResponse, error) {
+	if options == nil {
+		options = &ConnectOptions{}
+	}
+	scheme := "http"
+	if options.TLS.Enabled() {
+		scheme = "https"
 	}
-	if !strings.Contains(host, "://") {
-		host = "http://" + host
+	urls, err := parseHosts(host, scheme)
+	if err != nil {
+		return nil, nil, err
 	}
-	u, err := url.Parse(host)
+
+	tlsConfig, err := options.TLS.config()
 	if err != nil {
 		return nil, nil, err
 	}
 
-	transport := &http.Transport{}
+	responseTimeout := options.ResponseTimeout
+	if responseTimeout <= 0 {
+		responseTimeout = defaultResponseTimeout
+	}
+	waitTransport := &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		DialContext:         (&net.Dialer{Timeout: dialTimeout, KeepAlive: 30 * time.Second}).DialContext,
+		TLSClientConfig:     tlsConfig,
+		TLSHandshakeTimeout: tlsHandshakeTimeout,
+	}
+	transport := waitTransport.Clone()
+	transport.ResponseHeaderTimeout = responseTimeout
 	client := &http.Client{
 		Transport: transport,
 	}
 
-	es := Es{client: client, esURL: u, host: host, ActiveIndex: ""}
+	es := Es{client: client, waitClient: &http.Client{Transport: waitTransport}, hosts: newHostPool(urls), auth: options.Auth, Tracer: options.Tracer, OnQuery: options.OnQuery, ActiveIndex: ""}
 
 	ping, err := es.Ping()
 
-	if err == nil {
-		vs := strings.Split(ping.Version, ".")
-		var ver []int
-		for _, v := range vs {
-			vi, err := strconv.Atoi(v)
-			if err != nil {
-				return nil, nil, fmt.Errorf("Failed to parse Elasticsearch version %s", ping.Version)
-			}
-			ver = append(ver, vi)
-		}
-		es.Version = ver
-		es.ClusterName = ping.ClusterName
-	} else {
+	if err != nil {
+		return nil, nil, err
+	}
+	es.Version, err = parseVersion(ping.Version)
+	if err != nil {
 		return nil, nil, err
 	}
+	es.Distribution = ping.Distribution
+	es.Capabilities = capabilitiesFor(es.Distribution, es.Version)
+	es.ClusterName = ping.ClusterName
 
 	aliases, err := es.buildAliasCache()
 
@@ -79,42 +128,66 @@ func Connect(host string) (*Es, *PingResponse, error) {
 		return nil, nil, err
 	}
 	es.Nodes = make(map[string]*ShortNodeInfo)
+	// sniffed nodes are reached with the same scheme as the seed host which listed them
+	if seed := es.hosts.lastAnswered(); seed != nil {
+		scheme = seed.Scheme
+	}
 	for _, node := range nodes {
 		es.Nodes[node.UUID] = node
+		if options.Sniff && node.HTTPAddress != "" {
+			if u, err := url.Parse(scheme + "://" + node.HTTPAddress); err == nil {
+				es.hosts.add(u)
+			}
+		}
 	}
 
 	return &es, ping, err
 }
 
+// Hosts returns list of cluster nodes used by client along with their availability
+func (e Es) Hosts() []HostStatus {
+	return e.hosts.status()
+}
+
 // Ping performs ping request to an ES node
 func (e Es) Ping() (*PingResponse, error) {
-	body, err := e.getJSON("/")
-
+	var body rootResponse
+	err := e.getInto("/", &body)
 	if err != nil {
 		return nil, err
 	}
 
+	if body.ClusterName == "" {
+		return nil, fmt.Errorf("Unexpected response: no cluster name")
+	}
+	if body.Version.Number == "" {
+		return nil, fmt.Errorf("Unexpected response: no version number")
+	}
+
+	distribution := body.Version.Distribution
+	if distribution == "" {
+		distribution = DistributionElasticsearch
+	}
+
 	return &PingResponse{
-		ClusterName: body["cluster_name"].(string),
-		Version:     body["version"].(map[string]interface{})["number"].(string),
+		ClusterName:  body.ClusterName,
+		Version:      body.Version.Number,
+		Distribution: distribution,
 	}, nil
 }
 
-// Health returns current ClusterHealth
-func (e Es) Health() (*ClusterHealth, error) {
-	body, err := e.get("/_cluster/health")
-
-	if err != nil {
-		return nil, err
+// DistributionName returns human-readable name of the cluster distribution
+func (e Es) DistributionName() string {
+	if e.Distribution == DistributionOpenSearch {
+		return "OpenSearch"
 	}
+	return "Elasticsearch"
+}
 
+// Health returns current ClusterHealth
+func (e Es) Health() (*ClusterHealth, error) {
 	var data ClusterHealth
-	bodyBytes, err := ioutil.ReadAll(body.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if err := json.Unmarshal(bodyBytes, &data); err != nil {
+	if err := e.getInto("/_cluster/health", &data); err != nil {
 		return nil, err
 	}
 	return &data, nil
@@ -0,0 +1,227 @@
+package es
+
+import (
+	"net/http"
+	"shelastic/test/esfake"
+	"sort"
+	"testing"
+)
+
+var personalities = []esfake.Personality{esfake.ES1, esfake.ES2, esfake.ES5, esfake.ES6, esfake.ES7, esfake.ES8, esfake.OpenSearch}
+
+// forEachPersonality runs test against fake cluster of every supported version. Fake is started once per version
+// and shared by all the checks made by the test
+func forEachPersonality(t *testing.T, test func(t *testing.T, fake *esfake.Server, conn *Es)) {
+	for _, personality := range personalities {
+		name := personality.Distribution + personality.Version
+		t.Run(name, func(t *testing.T) {
+			fake := esfake.New(personality)
+			defer fake.Close()
+			conn, _, err := Connect(fake.URL, nil)
+			if err != nil {
+				t.Fatalf("Connect failed: %s", err)
+			}
+			test(t, fake, conn)
+		})
+	}
+}
+
+// docType returns document type used with fake cluster. Document types are not used by typeless clusters
+func docType(fake *esfake.Server) string {
+	if fake.Typeless() {
+		return ""
+	}
+	return "book"
+}
+
+func TestConnectToEveryVersion(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, conn *Es) {
+		ping, err := conn.Ping()
+		if err != nil {
+			t.Fatalf("Ping failed: %s", err)
+		}
+		if ping.ClusterName != fake.ClusterName || ping.Version != fake.Personality.Version {
+			t.Errorf("Connected to %s %s, expected %s %s", ping.ClusterName, ping.Version, fake.ClusterName, fake.Personality.Version)
+		}
+		expected := DistributionElasticsearch
+		if fake.Personality.Distribution != "" {
+			expected = fake.Personality.Distribution
+		}
+		if conn.Distribution != expected {
+			t.Errorf("Distribution is %q, expected %q", conn.Distribution, expected)
+		}
+		if conn.Capabilities.Typeless != fake.Typeless() {
+			t.Errorf("Typeless is %v, expected %v", conn.Capabilities.Typeless, fake.Typeless())
+		}
+		health, err := conn.Health()
+		if err != nil {
+			t.Fatalf("Health failed: %s", err)
+		}
+		if health.ClusterName != fake.ClusterName || health.Status == "" {
+			t.Errorf("Unexpected health %+v", health)
+		}
+	})
+}
+
+func TestListIndexInfo(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, conn *Es) {
+		fake.AddIndex("books", map[string]interface{}{"title": map[string]interface{}{"type": "keyword"}})
+		fake.AddAlias("books", "library")
+		fake.AddDocument("books", docType(fake), "1", map[string]interface{}{"title": "Dune"})
+		fake.AddDocument("books", docType(fake), "2", map[string]interface{}{"title": "Solaris"})
+		fake.AddIndex("archive", nil)
+
+		indices, err := conn.ListIndexInfo()
+		if err != nil {
+			t.Fatalf("ListIndexInfo failed: %s", err)
+		}
+		var names []string
+		for _, index := range indices {
+			names = append(names, index.Name)
+		}
+		if len(names) != 2 || names[0] != "archive" || names[1] != "books" {
+			t.Fatalf("Indices are %v, expected [archive books]", names)
+		}
+		books := indices[1]
+		if books.DocumentCount != 2 || books.Status != "open" || len(books.Aliases) != 1 || books.Aliases[0] != "library" {
+			t.Errorf("Unexpected books index summary %+v", books)
+		}
+	})
+}
+
+func TestDocumentLifecycle(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, conn *Es) {
+		doc := docType(fake)
+		result, err := conn.PutDocument("books", doc, "1", `{"title": "Dune"}`)
+		if err != nil || result != "created" {
+			t.Fatalf("PutDocument returned %q, %v", result, err)
+		}
+		result, err = conn.PutDocument("books", doc, "1", `{"title": "Dune Messiah"}`)
+		if err != nil || result != "updated" {
+			t.Fatalf("Second PutDocument returned %q, %v", result, err)
+		}
+
+		document, err := conn.GetDocument("books", doc, "1")
+		if err != nil {
+			t.Fatalf("GetDocument failed: %s", err)
+		}
+		source, _ := document["_source"].(map[string]interface{})
+		if document["_id"] != "1" || source["title"] != "Dune Messiah" {
+			t.Errorf("Unexpected document %v", document)
+		}
+
+		if err := conn.DeleteDocument("books", doc, "1"); err != nil {
+			t.Fatalf("DeleteDocument failed: %s", err)
+		}
+		if documents := fake.Documents("books"); len(documents) != 0 {
+			t.Errorf("Document is not deleted: %v", documents)
+		}
+		_, err = conn.GetDocument("books", doc, "1")
+		if esErr, ok := AsError(err); !ok || esErr.Status != http.StatusNotFound {
+			t.Errorf("Expected not found error for deleted document, got %v", err)
+		}
+
+		if !fake.Typeless() {
+			if _, err := conn.GetDocument("books", "", "1"); err != errDocumentTypeRequired {
+				t.Errorf("Expected document type error, got %v", err)
+			}
+		}
+	})
+}
+
+func TestSearchPaging(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, conn *Es) {
+		const total = 5
+		ids := []string{"a", "b", "c", "d", "e"}
+		for _, id := range ids {
+			fake.AddDocument("books", docType(fake), id, map[string]interface{}{"title": "Book " + id})
+		}
+
+		// search without index runs across all indices and is paged with from and size
+		all, err := conn.NewQueryPager("", docType(fake), `{"query": {"match_all": {}}}`, SearchOptions{Size: 2})
+		if err != nil {
+			t.Fatalf("NewQueryPager without index failed: %s", err)
+		}
+		if result, err := conn.FetchPage(all, 1); err != nil || len(result.Hits) != 2 || result.Total != total {
+			t.Errorf("Second page of search without index is not fetched: %v %+v", err, result)
+		}
+
+		pager, err := conn.NewQueryPager("books", docType(fake), `{"query": {"match_all": {}}}`, SearchOptions{Size: 2})
+		if err != nil {
+			t.Fatalf("NewQueryPager failed: %s", err)
+		}
+		defer conn.ClosePager(pager)
+
+		var found []string
+		for page := 0; ; page++ {
+			result, err := conn.FetchPage(pager, page)
+			if err != nil {
+				t.Fatalf("Page %d: %s", page, err)
+			}
+			if result.Total != total {
+				t.Errorf("Page %d: total is %d, expected %d", page, result.Total, total)
+			}
+			for _, hit := range result.Hits {
+				id, _ := hit["_id"].(string)
+				found = append(found, id)
+			}
+			if pager.HasPrevious() != (page > 0) {
+				t.Errorf("Page %d: HasPrevious is %v", page, pager.HasPrevious())
+			}
+			if !pager.HasNext() {
+				break
+			}
+			if page > total {
+				t.Fatalf("Paging does not stop")
+			}
+		}
+		sort.Strings(found)
+		if len(found) != total {
+			t.Fatalf("Found %v, expected %v", found, ids)
+		}
+		for i := range ids {
+			if found[i] != ids[i] {
+				t.Fatalf("Found %v, expected %v", found, ids)
+			}
+		}
+
+		// the first page can be requested again
+		result, err := conn.FetchPage(pager, 0)
+		if err != nil || len(result.Hits) != 2 {
+			t.Errorf("First page is not fetched again: %v", err)
+		}
+	})
+}
+
+func TestErrorDecoding(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, conn *Es) {
+		pager, err := conn.NewSearchPager("missing", "", "title:dune", SearchOptions{Size: 10})
+		if err == nil {
+			// point in time is only opened by clusters supporting it, others fail when the first page is fetched
+			_, err = conn.FetchPage(pager, 0)
+		}
+		esErr, ok := AsError(err)
+		if !ok {
+			t.Fatalf("Expected Elasticsearch error, got %v", err)
+		}
+		if esErr.Status != http.StatusNotFound || esErr.Type != "index_not_found_exception" {
+			t.Errorf("Unexpected error %d %s: %s", esErr.Status, esErr.Type, esErr.Reason)
+		}
+		if !IsErrorType(err, "index_not_found_exception") {
+			t.Errorf("IsErrorType does not recognize %v", err)
+		}
+	})
+}
+
+func TestIndexConfigureMissingIndex(t *testing.T) {
+	forEachPersonality(t, func(t *testing.T, fake *esfake.Server, conn *Es) {
+		err := conn.IndexConfigure("missing", map[string]string{"index.number_of_replicas": "2"})
+		esErr, ok := AsError(err)
+		if !ok {
+			t.Fatalf("Expected Elasticsearch error, got %v", err)
+		}
+		if esErr.Status != http.StatusNotFound {
+			t.Errorf("Unexpected error %d %s: %s", esErr.Status, esErr.Type, esErr.Reason)
+		}
+	})
+}
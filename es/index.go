@@ -5,23 +5,25 @@ import (
 	"encoding/json"
 	"fmt"
 	"shelastic/utils"
+	"sort"
 	"strings"
+	"sync"
 )
 
 // ShortIndexInfo contains basic index information
 type ShortIndexInfo struct {
-	Name          string
-	DocumentCount int
-	DeletedCount  int
-	Size          int
-	Aliases       []*ShortAliasInfo
+	Name          string            `json:"name"`
+	DocumentCount int               `json:"docs"`
+	DeletedCount  int               `json:"deleted_docs"`
+	Size          int               `json:"size_in_bytes"`
+	Aliases       []*ShortAliasInfo `json:"aliases"`
 }
 
 // ShortAliasInfo contains basic alias information
 type ShortAliasInfo struct {
-	Name     string
-	Filtered bool
-	Filter   string
+	Name     string `json:"name"`
+	Filtered bool   `json:"filtered"`
+	Filter   string `json:"filter,omitempty"`
 }
 
 // IndexSettings contains index settings (surprise!)
@@ -30,62 +32,62 @@ type IndexSettings map[string]interface{}
 // IndexMappings contains index mappings
 type IndexMappings map[string]interface{}
 
-// ListIndices returns slice of *ShortIndexInfo containing names of indices
+// ListIndices returns slice of *ShortIndexInfo containing names of indices, sorted by name.
+// Aliases of all indices are retrieved with a single request
 func (e Es) ListIndices() ([]*ShortIndexInfo, error) {
-	body, err := e.getJSON("/_stats")
+	var body statsResponse
+	err := e.getInto("/_stats", &body)
 
 	if err != nil {
 		return nil, err
 	}
 
-	body = body["indices"].(map[string]interface{})
+	allAliases, err := e.getAllAliases()
+	if err != nil {
+		return nil, err
+	}
 
-	result := make([]*ShortIndexInfo, len(body))
+	result := make([]*ShortIndexInfo, len(body.Indices))
 	i := 0
-	for index := range body {
-		idx := body[index].(map[string]interface{})
-		prim := idx["primaries"].(map[string]interface{})
-		docs := prim["docs"].(map[string]interface{})
-		store := prim["store"].(map[string]interface{})
-
-		aliases, err := e.GetAliases(index)
+	for index, stats := range body.Indices {
+		aliases, err := newAliasInfos(allAliases[index].Aliases)
 		if err != nil {
-			aliases = make([]*ShortAliasInfo, 0)
+			return nil, err
 		}
 
 		sii := &ShortIndexInfo{
 			Name:          index,
-			DocumentCount: int(docs["count"].(float64)),
-			DeletedCount:  int(docs["deleted"].(float64)),
-			Size:          int(store["size_in_bytes"].(float64)),
+			DocumentCount: stats.Primaries.Docs.Count,
+			DeletedCount:  stats.Primaries.Docs.Deleted,
+			Size:          stats.Primaries.Store.SizeInBytes,
 			Aliases:       aliases,
 		}
 
 		result[i] = sii
 		i++
 	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
 	return result, nil
 }
 
 // GetAliases retrieves aliases for a given index
 // Each alias contains name and filter in yaml format, if alias is filtered
 func (e Es) GetAliases(indexName string) ([]*ShortAliasInfo, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/_alias/*", indexName))
+	var body aliasesResponse
+	err := e.getInto(fmt.Sprintf("/%s/_alias/*", indexName), &body)
 
 	if err != nil {
 		return nil, err
 	}
 
-	if len(body) == 0 {
-		return []*ShortAliasInfo{}, nil
-	}
-
-	body = body[indexName].(map[string]interface{})["aliases"].(map[string]interface{})
+	return newAliasInfos(body[indexName].Aliases)
+}
 
-	result := make([]*ShortAliasInfo, len(body))
-	i := 0
-	for alias := range body {
-		filter := body[alias].(map[string]interface{})
+// newAliasInfos converts aliases of an index, as returned by _alias API, to slice of *ShortAliasInfo sorted by name
+func newAliasInfos(aliases map[string]map[string]interface{}) ([]*ShortAliasInfo, error) {
+	result := make([]*ShortAliasInfo, 0, len(aliases))
+	for _, alias := range aliasNames(aliases) {
+		filter := aliases[alias]
 		filterYaml, err := utils.MapToYaml(filter)
 
 		if err != nil {
@@ -98,12 +100,33 @@ func (e Es) GetAliases(indexName string) ([]*ShortAliasInfo, error) {
 			Filter:   filterYaml,
 		}
 
-		result[i] = sai
-		i++
+		result = append(result, sai)
 	}
 	return result, nil
 }
 
+// aliasNames returns sorted names of index aliases
+func aliasNames(aliases map[string]map[string]interface{}) []string {
+	result := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		result = append(result, alias)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// getAllAliases retrieves aliases of all indices with a single request. Alias cache is refreshed from the response
+func (e Es) getAllAliases() (aliasesResponse, error) {
+	var body aliasesResponse
+	err := e.getInto("/_alias", &body)
+
+	if err != nil {
+		return nil, err
+	}
+	e.refreshAliasCache(body)
+	return body, nil
+}
+
 func getAnyKey(m map[string]interface{}) string {
 	for k := range m {
 		return k
@@ -111,6 +134,20 @@ func getAnyKey(m map[string]interface{}) string {
 	return ""
 }
 
+// IndexLifecycle explains current lifecycle state of the index. Elasticsearch index lifecycle management (ILM)
+// and OpenSearch index state management (ISM) are supported
+func (e Es) IndexLifecycle(indexName string) (map[string]interface{}, error) {
+	var path string
+	if e.Capabilities.ISM {
+		path = fmt.Sprintf("/_plugins/_ism/explain/%s", indexName)
+	} else if e.Capabilities.ILM {
+		path = fmt.Sprintf("/%s/_ilm/explain", indexName)
+	} else {
+		return nil, fmt.Errorf("Index lifecycle management is not supported by %s %s", e.DistributionName(), versionString(e.Version))
+	}
+	return e.getJSON(path)
+}
+
 // Flush flushes ES index
 func (e Es) Flush(indexName string, force bool, wait bool) error {
 	var path string
@@ -119,7 +156,7 @@ func (e Es) Flush(indexName string, force bool, wait bool) error {
 	} else {
 		path = "/_flush"
 	}
-	_, err := e.post(path, "")
+	_, err := e.postJSON(path, "")
 	return err
 }
 
@@ -131,7 +168,7 @@ func (e Es) ClearCache(indexName string) error {
 	} else {
 		path = "/_cache/clear"
 	}
-	_, err := e.post(path, "")
+	_, err := e.postJSON(path, "")
 	return err
 }
 
@@ -143,26 +180,21 @@ func (e Es) Refresh(indexName string) error {
 	} else {
 		path = "/_refresh"
 	}
-	_, err := e.post(path, "")
+	_, err := e.postJSON(path, "")
 	return err
 }
 
 // ForceMerge allows to force merging of one or more indices through an API.
-// For ES version 1.x and 2.x this calls _optimize API
+// For ES versions before 2.1 this calls _optimize API
 func (e Es) ForceMerge(indexName string) error {
-	var apiName string
-	if e.Version[0] < 5 {
-		apiName = "_optimize"
-	} else {
-		apiName = "_forcemerge"
-	}
+	apiName := e.Capabilities.ForceMergeAPI
 	var path string
 	if indexName != "" {
 		path = fmt.Sprintf("/%s/%s", indexName, apiName)
 	} else {
 		path = "/" + apiName
 	}
-	_, err := e.post(path, "")
+	_, err := e.postJSON(path, "")
 	return err
 }
 
@@ -184,6 +216,9 @@ func (e Es) IndexConfigure(indexName string, params map[string]string) error {
 	payload.WriteString("\n}")
 
 	resp, err := e.putJSON(fmt.Sprintf("/%s/_settings", indexName), payload.String())
+	if err != nil {
+		return err
+	}
 
 	err = checkError(resp)
 	if err != nil {
@@ -194,15 +229,11 @@ func (e Es) IndexConfigure(indexName string, params map[string]string) error {
 
 //ResolveAndValidateIndex checks if indexName parameter is a valid index and in case it is an alias it resolves it to actual index name
 func (e Es) ResolveAndValidateIndex(indexName string) (string, error) {
-	if len(e.aliases) != 0 {
-		if idx, ok := e.aliases[indexName]; ok {
-			return idx, nil
-		}
-		for als := range e.aliases {
-			if indexName == e.aliases[als] {
-				return indexName, nil
-			}
-		}
+	if idx, ok := e.aliases.index(indexName); ok {
+		return idx, nil
+	}
+	if e.aliases.hasIndex(indexName) {
+		return indexName, nil
 	}
 
 	indices, err := e.ListIndices()
@@ -254,14 +285,17 @@ func (e Es) TruncateIndex(indexName string) error {
 	if err != nil {
 		return err
 	}
-	e.delete("/" + indexName)
+	_, err = e.delete("/" + indexName)
+	if err != nil {
+		return err
+	}
 
 	_, err = e.postJSON("/"+indexName, string(databody))
 	return err
 }
 
 //CopyIndex creates a new index named 'newName' and copies data from 'indexName' to it
-//On ES 5.0+ this uses reindex API, on older versions this copies data using bulk APIs
+//On ES 2.3+ this uses reindex API, on older versions this copies data using bulk APIs
 //Mappings and settings are copied from original index
 func (e Es) CopyIndex(indexName string, newName string) error {
 	indexName = e.resolveAlias(indexName)
@@ -279,7 +313,10 @@ func (e Es) CopyIndex(indexName string, newName string) error {
 	}
 
 	// Create settings for new index
-	indexSettingsJSON := body[indexName].(map[string]interface{})
+	indexSettingsJSON, ok := body[indexName].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Cannot read settings of index %s", indexName)
+	}
 	indexSettingsJSON["aliases"] = make(map[string]interface{})
 	indexSettingsJSON["settings"] = make(map[string]interface{})
 
@@ -298,10 +335,10 @@ func (e Es) CopyIndex(indexName string, newName string) error {
 		return err
 	}
 
-	if e.Version[0] < 2 && e.Version[1] < 3 {
-		return e.copyData(indexName, newName)
+	if e.Capabilities.Reindex {
+		return e.reindex(indexName, newName)
 	}
-	return e.reindex(indexName, newName)
+	return e.copyData(indexName, newName)
 }
 
 func (e Es) reindex(oldIndex string, newIndex string) error {
@@ -371,8 +408,11 @@ func (e Es) copyData(oldIndex string, newIndex string) error {
 // DeleteIndex deletes index completely
 func (e Es) DeleteIndex(indexName string) error {
 	indexName = e.resolveAlias(indexName)
-	_, err := e.delete("/" + indexName)
-	return err
+	resp, err := e.delete("/" + indexName)
+	if err != nil {
+		return err
+	}
+	return checkError(resp)
 }
 
 // AddIndexAlias adds alias to index
@@ -419,10 +459,7 @@ func (e Es) aliasOperation(operation string, indexName string, alias string) err
 	}
 	err = checkError(resp)
 	if err == nil {
-		aliases, err := e.buildAliasCache()
-		if err == nil {
-			e.aliases = aliases
-		}
+		e.getAllAliases()
 	}
 	return err
 }
@@ -440,48 +477,95 @@ func (sai ShortAliasInfo) String() string {
 	return buffer.String()
 }
 
-func getErrorReason(errorBody interface{}) string {
-	body, ok := errorBody.(map[string]interface{})
-	var reason string
-	if !ok {
-		reason = errorBody.(string)
-	} else {
-		reason = body["reason"].(string)
-		if causeBody, ok := body["caused_by"]; ok {
-			cause := getErrorReason(causeBody)
-			return fmt.Sprintf("%s, caused by '%s'", reason, cause)
-		}
+func (e Es) buildAliasCache() (*aliasCache, error) {
+	var body aliasesResponse
+	err := e.getInto("/_alias", &body)
+
+	if err != nil {
+		return nil, err
 	}
-	return reason
+	cache := &aliasCache{}
+	cache.refresh(body)
+	return cache, nil
+}
+
+// aliasCache maps alias names to names of indices they point to. Cache is shared by all copies of Es
+// and may be refreshed by requests running in other goroutines, so access to it is synchronized
+type aliasCache struct {
+	mutex   sync.RWMutex
+	aliases map[string]string
 }
 
-func checkError(body map[string]interface{}) error {
-	if doc, ok := body["error"]; ok {
-		return fmt.Errorf(getErrorReason(doc))
+// refresh replaces content of the cache with aliases from _alias API response
+func (ac *aliasCache) refresh(body aliasesResponse) {
+	aliases := make(map[string]string)
+	for index, indexAliases := range body {
+		for a := range indexAliases.Aliases {
+			aliases[a] = index
+		}
 	}
-	return nil
+	ac.mutex.Lock()
+	defer ac.mutex.Unlock()
+	ac.aliases = aliases
 }
 
-func (e Es) buildAliasCache() (map[string]string, error) {
-	body, err := e.getJSON("/_alias")
+// index returns name of the index alias points to
+func (ac *aliasCache) index(alias string) (string, bool) {
+	if ac == nil {
+		return "", false
+	}
+	ac.mutex.RLock()
+	defer ac.mutex.RUnlock()
+	index, ok := ac.aliases[alias]
+	return index, ok
+}
 
-	if err != nil {
-		return nil, err
+// hasIndex checks if any alias points to the index
+func (ac *aliasCache) hasIndex(index string) bool {
+	if ac == nil {
+		return false
 	}
-	var result = make(map[string]string)
-	for index := range body {
-		aliases := body[index].(map[string]interface{})["aliases"].(map[string]interface{})
-		if len(aliases) > 0 {
-			for a := range aliases {
-				result[a] = index
-			}
+	ac.mutex.RLock()
+	defer ac.mutex.RUnlock()
+	for _, idx := range ac.aliases {
+		if idx == index {
+			return true
 		}
 	}
-	return result, nil
+	return false
+}
+
+// names returns sorted names of all cached aliases
+func (ac *aliasCache) names() []string {
+	if ac == nil {
+		return nil
+	}
+	ac.mutex.RLock()
+	defer ac.mutex.RUnlock()
+	result := make([]string, 0, len(ac.aliases))
+	for alias := range ac.aliases {
+		result = append(result, alias)
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Aliases returns names of all aliases known to the client. Names are taken from the alias cache,
+// which is refreshed whenever aliases of all indices are retrieved or changed
+func (e Es) Aliases() []string {
+	return e.aliases.names()
+}
+
+// refreshAliasCache replaces content of alias cache
+func (e Es) refreshAliasCache(body aliasesResponse) {
+	if e.aliases == nil {
+		return
+	}
+	e.aliases.refresh(body)
 }
 
 func (e Es) resolveAlias(indexName string) string {
-	if idx, ok := e.aliases[indexName]; ok {
+	if idx, ok := e.aliases.index(indexName); ok {
 		indexName = idx
 	}
 	return indexName
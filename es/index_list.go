@@ -0,0 +1,146 @@
+package es
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// IndexInfo contains index summary: health, state, shards, document counts and sizes
+type IndexInfo struct {
+	Health   string
+	Status   string
+	Name     string
+	UUID     string
+	Shards   int
+	Replicas int
+	// DocumentCount, DeletedCount and sizes are not known for closed indices
+	DocumentCount int64
+	DeletedCount  int64
+	PrimarySize   int64
+	TotalSize     int64
+	// CreationDate is zero if creation date is not reported by the cluster
+	CreationDate time.Time
+	Aliases      []string
+}
+
+// Closed checks if index is closed
+func (ii IndexInfo) Closed() bool {
+	return ii.Status == "close"
+}
+
+const catIndicesColumns = "health,status,index,uuid,pri,rep,docs.count,docs.deleted,store.size,pri.store.size,creation.date"
+
+// ListIndexInfo returns summary of all indices, including closed ones, sorted by name.
+// Clusters before 5.0 do not return _cat APIs output as JSON, for them summary is collected from cluster state,
+// index health and stats
+func (e Es) ListIndexInfo() ([]*IndexInfo, error) {
+	var result []*IndexInfo
+	var err error
+	if e.Capabilities.CatJSON {
+		result, err = e.catIndices()
+	} else {
+		result, err = e.indicesFromState()
+	}
+	if err != nil {
+		return nil, err
+	}
+	allAliases, err := e.getAllAliases()
+	if err != nil {
+		return nil, err
+	}
+	for _, info := range result {
+		info.Aliases = aliasNames(allAliases[info.Name].Aliases)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result, nil
+}
+
+func (e Es) catIndices() ([]*IndexInfo, error) {
+	var body catIndicesResponse
+	err := e.getInto("/_cat/indices?format=json&bytes=b&h="+catIndicesColumns, &body)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*IndexInfo, len(body))
+	for i, row := range body {
+		result[i] = &IndexInfo{
+			Health:        row.Health,
+			Status:        row.Status,
+			Name:          row.Index,
+			UUID:          row.UUID,
+			Shards:        int(parseCatNumber(row.Primaries)),
+			Replicas:      int(parseCatNumber(row.Replicas)),
+			DocumentCount: parseCatNumber(row.DocsCount),
+			DeletedCount:  parseCatNumber(row.DocsDeleted),
+			PrimarySize:   parseCatNumber(row.PrimaryStoreSize),
+			TotalSize:     parseCatNumber(row.StoreSize),
+			CreationDate:  parseEpochMillis(row.CreationDate),
+		}
+	}
+	return result, nil
+}
+
+func (e Es) indicesFromState() ([]*IndexInfo, error) {
+	var state clusterStateResponse
+	if err := e.getInto("/_cluster/state/metadata", &state); err != nil {
+		return nil, err
+	}
+	var health indicesHealthResponse
+	if err := e.getInto("/_cluster/health?level=indices", &health); err != nil {
+		return nil, err
+	}
+	var stats statsResponse
+	if err := e.getInto("/_stats", &stats); err != nil {
+		return nil, err
+	}
+
+	var result []*IndexInfo
+	for name, metadata := range state.Metadata.Indices {
+		info := &IndexInfo{
+			Health:       health.Indices[name].Status,
+			Status:       metadata.State,
+			Name:         name,
+			UUID:         indexSetting(metadata.Settings, "uuid"),
+			Shards:       int(parseCatNumber(indexSetting(metadata.Settings, "number_of_shards"))),
+			Replicas:     int(parseCatNumber(indexSetting(metadata.Settings, "number_of_replicas"))),
+			CreationDate: parseEpochMillis(indexSetting(metadata.Settings, "creation_date")),
+		}
+		if indexStats, ok := stats.Indices[name]; ok {
+			info.DocumentCount = int64(indexStats.Primaries.Docs.Count)
+			info.DeletedCount = int64(indexStats.Primaries.Docs.Deleted)
+			info.PrimarySize = int64(indexStats.Primaries.Store.SizeInBytes)
+			info.TotalSize = int64(indexStats.Total.Store.SizeInBytes)
+		}
+		result = append(result, info)
+	}
+	return result, nil
+}
+
+// indexSetting reads index setting from cluster state. Settings are either nested, as in {"index": {"uuid": ...}},
+// or flat, as in {"index.uuid": ...}, depending on cluster version
+func indexSetting(settings map[string]interface{}, name string) string {
+	if index, ok := settings["index"].(map[string]interface{}); ok {
+		if value, ok := index[name].(string); ok {
+			return value
+		}
+	}
+	value, _ := settings["index."+name].(string)
+	return value
+}
+
+// parseCatNumber converts numeric value returned by _cat API as string. Empty values, reported for closed indices,
+// are converted to 0
+func parseCatNumber(value string) int64 {
+	result, _ := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	return result
+}
+
+func parseEpochMillis(value string) time.Time {
+	millis := parseCatNumber(value)
+	if millis == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, millis*int64(time.Millisecond))
+}
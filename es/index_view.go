@@ -16,8 +16,8 @@ type ShardRouting struct {
 
 // ShardInfo contains information about the shard
 type ShardInfo struct {
-	Routing          *ShardRouting `json:"routing" yaml:"Routing"`
-	Node             *ShortNodeInfo
+	Routing          *ShardRouting           `json:"routing" yaml:"Routing"`
+	Node             *ShortNodeInfo          `json:"node" yaml:"Node"`
 	CommitedSegments int                     `json:"num_committed_segments" yaml:"Committed Segments"`
 	SearchSegments   int                     `json:"num_search_segments" yaml:"Search Segments"`
 	Segments         map[string]*SegmentInfo `json:"segments" yaml:"Segments"`
@@ -39,41 +39,51 @@ type SegmentInfo struct {
 // ID is number of shard
 // Shards contains information on actual shards allocated to nodes
 type IndexShard struct {
-	ID     int
-	Shards []*ShardInfo
+	ID     int          `json:"id"`
+	Shards []*ShardInfo `json:"shards"`
 }
 
 // IndexViewMapping returns string containing JSON of mapping information
 func (e Es) IndexViewMapping(indexName string, documentType string, propertyName string) (*IndexMappings, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/_mapping", indexName))
+	var response mappingsResponse
+	err := e.getInto(fmt.Sprintf("/%s/_mapping", indexName), &response)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = checkError(body)
-	if err != nil {
-		return nil, fmt.Errorf("Index %s failed: %s", indexName, err.Error())
-	}
-
 	indexName = e.resolveAlias(indexName)
 
-	body = body[indexName].(map[string]interface{})
+	body, ok := response[indexName]
+	if !ok {
+		return nil, fmt.Errorf("No mappings for index %s", indexName)
+	}
 
-	if doc, ok := body["mappings"]; ok {
-		body = doc.(map[string]interface{})
+	if doc, ok := body["mappings"].(map[string]interface{}); ok {
+		body = doc
 	}
 
-	if documentType != "" {
-		if doc, ok := body[documentType]; ok {
-			body = doc.(map[string]interface{})["properties"].(map[string]interface{})
-		} else {
+	if e.Capabilities.Typeless {
+		// typeless mappings contain properties directly, document type is ignored
+		if documentType != "" || propertyName != "" {
+			body, ok = body["properties"].(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("No properties in index %s mapping", indexName)
+			}
+		}
+	} else if documentType != "" {
+		doc, ok := body[documentType].(map[string]interface{})
+		if !ok {
 			return nil, fmt.Errorf("No '%s' document in mapping", documentType)
 		}
+		body, ok = doc["properties"].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("No properties in '%s' document mapping", documentType)
+		}
 	}
 	if propertyName != "" {
-		if doc, ok := body[propertyName]; ok {
-			body = doc.(map[string]interface{})
+		if doc, ok := body[propertyName].(map[string]interface{}); ok {
+			body = doc
 		} else {
 			return nil, fmt.Errorf("No '%s' property in document '%s'", propertyName, documentType)
 		}
@@ -88,22 +98,25 @@ func (e Es) IndexViewMapping(indexName string, documentType string, propertyName
 
 // IndexViewSettings retrieves index settings
 func (e Es) IndexViewSettings(indexName string) (*IndexSettings, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/_settings", indexName))
+	var response settingsResponse
+	err := e.getInto(fmt.Sprintf("/%s/_settings", indexName), &response)
 
 	if err != nil {
 		return nil, err
 	}
 
-	// return settings, nil
-	err = checkError(body)
-	if err != nil {
-		return nil, fmt.Errorf("Index %s failed: %s", indexName, err.Error())
-	}
-
 	indexName = e.resolveAlias(indexName)
 
+	index, ok := response[indexName]
+	if !ok {
+		return nil, fmt.Errorf("No settings for index %s", indexName)
+	}
+
 	settings := &IndexSettings{}
-	err = utils.DictToAny(body[indexName].(map[string]interface{})["settings"].(map[string]interface{})["index"].(map[string]interface{}), settings)
+	err = utils.DictToAny(index.Settings.Index, settings)
+	if err != nil {
+		return nil, err
+	}
 
 	return settings, nil
 }
@@ -113,48 +126,63 @@ type IndexShards []IndexShard
 
 //IndexShards returns list of shards allocated for a given index
 func (e Es) IndexShards(indexName string) (IndexShards, error) {
-	body, err := e.getJSON(fmt.Sprintf("/%s/_segments", indexName))
+	var body segmentsResponse
+	err := e.getInto(fmt.Sprintf("/%s/_segments", indexName), &body)
 
 	if err != nil {
 		return nil, err
 	}
 
-	err = checkError(body)
-	if err != nil {
-		return nil, fmt.Errorf("Index %s failed: %s", indexName, err.Error())
+	indexName = e.resolveAlias(indexName)
+
+	index, ok := body.Indices[indexName]
+	if !ok {
+		return nil, fmt.Errorf("No segments information for index %s", indexName)
 	}
+	return e.newIndexShards(index.Shards)
+}
 
-	indexName = e.resolveAlias(indexName)
+// AllIndexShards returns shards of all indices by index name. Shards are retrieved with a single request
+func (e Es) AllIndexShards() (map[string]IndexShards, error) {
+	var body segmentsResponse
+	err := e.getInto("/_segments", &body)
 
-	shards := body["indices"].(map[string]interface{})[indexName].(map[string]interface{})["shards"].(map[string]interface{})
+	if err != nil {
+		return nil, err
+	}
 
+	result := make(map[string]IndexShards, len(body.Indices))
+	for indexName, index := range body.Indices {
+		shards, err := e.newIndexShards(index.Shards)
+		if err != nil {
+			return nil, err
+		}
+		result[indexName] = shards
+	}
+	return result, nil
+}
+
+// newIndexShards converts shards from _segments response to IndexShards sorted by shard number
+func (e Es) newIndexShards(shards map[string][]*ShardInfo) (IndexShards, error) {
 	var result []IndexShard
-	for shardIdx := range shards {
-		shard := shards[shardIdx].([]interface{})
-		var shardInfos = make([]*ShardInfo, len(shard))
-		for idx, subShardM := range shard {
-			subShard := subShardM.(map[string]interface{})
-
-			shardInfo := &ShardInfo{}
-			err = utils.DictToAnyJ(subShard, shardInfo)
-			if err != nil {
-				return nil, err
+	for shardIdx, shard := range shards {
+		for _, shardInfo := range shard {
+			if shardInfo.Routing == nil {
+				return nil, fmt.Errorf("Failed to parse response: no routing information for shard %s", shardIdx)
 			}
 			nodeID := shardInfo.Routing.Node
 
 			node, ok := e.Nodes[nodeID]
 			if !ok {
-				return nil, fmt.Errorf("Failed to parse response: Unknown node %s", node)
+				return nil, fmt.Errorf("Failed to parse response: Unknown node %s", nodeID)
 			}
 
 			shardInfo.Node = node
-
-			shardInfos[idx] = shardInfo
 		}
 		id, _ := strconv.Atoi(shardIdx)
 		indexShard := IndexShard{
 			ID:     id,
-			Shards: shardInfos,
+			Shards: shard,
 		}
 		result = append(result, indexShard)
 	}
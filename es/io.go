@@ -3,111 +3,193 @@ package es
 import (
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 )
 
-func (e Es) get(path string) (*http.Response, error) {
+// newRequest creates HTTP request to the next available cluster node with authentication headers set
+func (e Es) newRequest(method string, path string, body io.Reader) (*http.Request, error) {
 	pathURL, err := url.Parse(path)
 	if err != nil {
 		return nil, err
 	}
-	reqURL := e.esURL.ResolveReference(pathURL)
-	if e.Debug {
-		fmt.Printf("Request: GET %s\n\n", reqURL.String())
-	}
-	resp, err := e.client.Get(reqURL.String())
-	if e.Debug {
-		if err != nil {
-			fmt.Println("Response error: ", err.Error())
-		} else {
-			fmt.Println("Response:", resp)
-		}
+	reqURL := e.hosts.pick().url.ResolveReference(pathURL)
+	req, err := http.NewRequest(method, reqURL.String(), body)
+	if err != nil {
+		return nil, err
 	}
-	return resp, err
+	e.auth.apply(req)
+	return req, nil
 }
 
-func (e Es) post(path string, data string) (*http.Response, error) {
-	pathURL, err := url.Parse(path)
-	if err != nil {
-		return nil, err
+// do executes HTTP request and returns response along with its body. If cluster node fails to respond, or a proxy
+// reports it unreachable, it is marked as dead and idempotent requests are retried on other nodes. Every attempt is recorded by tracer
+func (e Es) do(req *http.Request, data string) (*http.Response, []byte, error) {
+	attempts := 1
+	if isIdempotent(req.Method) {
+		attempts = e.hosts.size()
 	}
-	reqURL := e.esURL.ResolveReference(pathURL)
 	var resp *http.Response
-	req, err := http.NewRequest(http.MethodPost, reqURL.String(), strings.NewReader(data))
+	var bodyBytes []byte
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 {
+			req, err = e.retarget(req)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+		host := e.hosts.find(req.URL.Host)
+		resp, bodyBytes, err = e.roundTrip(req, data)
+		if err == nil && !isNodeFailure(resp.StatusCode) {
+			e.hosts.markAlive(host)
+			break
+		}
+		e.hosts.markDead(host)
+	}
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	cl := strconv.FormatInt(int64(len(data)), 10)
-	req.Header.Add("Content-Length", cl)
+	return resp, bodyBytes, nil
+}
 
-	if e.Debug {
-		fmt.Println("Request: ", req)
+// roundTrip sends request to a single node and reads the response body. Requests waiting for completion of
+// long-running operation are sent without response timeout
+func (e Es) roundTrip(req *http.Request, data string) (*http.Response, []byte, error) {
+	client := e.client
+	if e.waitClient != nil && req.URL.Query().Get("wait_for_completion") == "true" {
+		client = e.waitClient
 	}
+	started := time.Now()
+	resp, err := client.Do(req)
+	var bodyBytes []byte
+	if err == nil {
+		bodyBytes, err = ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	if e.Tracer != nil {
+		e.Tracer.record(req, data, resp, bodyBytes, started, err)
+	}
+	return resp, bodyBytes, err
+}
 
-	resp, err = e.client.Do(req)
+// execute executes request and returns response body. Unsuccessful responses are converted to Error
+func (e Es) execute(req *http.Request, data string) ([]byte, error) {
+	resp, bodyBytes, err := e.do(req, data)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp, bodyBytes); err != nil {
+		return nil, err
+	}
+	return bodyBytes, nil
+}
 
-	if e.Debug {
+// retarget creates a copy of the request directed to the next available cluster node
+func (e Es) retarget(req *http.Request) (*http.Request, error) {
+	host := e.hosts.pick()
+	result := req.Clone(req.Context())
+	if req.GetBody != nil {
+		body, err := req.GetBody()
 		if err != nil {
-			fmt.Println("Response error: ", err.Error())
-		} else {
-			fmt.Println("Response:", resp)
+			return nil, err
 		}
+		result.Body = body
 	}
+	result.URL.Scheme = host.url.Scheme
+	result.URL.Host = host.url.Host
+	result.Host = ""
+	return result, nil
+}
+
+func isIdempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
 
-	return resp, err
+// isNodeFailure checks if HTTP status indicates that the node is not able to serve requests. These are statuses
+// returned by proxies in front of unreachable nodes. Elasticsearch itself responds with 503 to cluster-level
+// failures, e.g. when all shards fail or cluster is red, and other nodes would respond the same way
+func isNodeFailure(status int) bool {
+	return status == http.StatusBadGateway || status == http.StatusGatewayTimeout
 }
 
 func (e Es) requestWithBody(method string, path string, data string, contentType string) (map[string]interface{}, error) {
-	pathURL, err := url.Parse(path)
+	bodyBytes, err := e.requestData(method, path, data, contentType)
 	if err != nil {
 		return nil, err
 	}
-	reqURL := e.esURL.ResolveReference(pathURL)
-	var resp *http.Response
-	req, err := http.NewRequest(method, reqURL.String(), strings.NewReader(data))
-	if err != nil {
+
+	var body map[string]interface{}
+
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
 		return nil, err
 	}
-	cl := strconv.FormatInt(int64(len(data)), 10)
-	req.Header.Add("Content-Length", cl)
-	if contentType == "" {
-		req.Header.Add("Content-Type", "application/json")
-	} else {
-		req.Header.Add("Content-Type", contentType)
-	}
+	return body, err
+}
 
-	if e.Debug {
-		dumpRequest(req, data)
+// requestInto executes request with JSON body and decodes response into result
+func (e Es) requestInto(method string, path string, data string, result interface{}) error {
+	bodyBytes, err := e.requestData(method, path, data, "")
+	if err != nil {
+		return err
 	}
+	return decodeResponse(path, bodyBytes, result)
+}
 
-	resp, err = e.client.Do(req)
+// getInto executes GET request and decodes response into result
+func (e Es) getInto(path string, result interface{}) error {
+	bodyBytes, err := e.getData(path)
 	if err != nil {
-		return nil, err
+		return err
 	}
+	return decodeResponse(path, bodyBytes, result)
+}
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if e.Debug {
-		if err != nil {
-			fmt.Println("Response error: ", err.Error())
-		} else {
-			dumpResponse(resp, bodyBytes)
-		}
+// decodeResponse unmarshals JSON response. Responses of unexpected shape are reported as errors
+func decodeResponse(path string, bodyBytes []byte, result interface{}) error {
+	if err := json.Unmarshal(bodyBytes, result); err != nil {
+		return fmt.Errorf("Unexpected response from %s: %s", path, err.Error())
 	}
+	return nil
+}
+
+// requestData executes request with a body and returns raw response body
+func (e Es) requestData(method string, path string, data string, contentType string) ([]byte, error) {
+	_, bodyBytes, err := e.requestResponse(method, path, data, contentType)
+	return bodyBytes, err
+}
 
+// requestResponse executes request with a body and returns response along with its raw body
+func (e Es) requestResponse(method string, path string, data string, contentType string) (*http.Response, []byte, error) {
+	req, err := e.newRequest(method, path, strings.NewReader(data))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
+	}
+	cl := strconv.FormatInt(int64(len(data)), 10)
+	req.Header.Add("Content-Length", cl)
+	if contentType == "" {
+		req.Header.Add("Content-Type", "application/json")
+	} else {
+		req.Header.Add("Content-Type", contentType)
 	}
 
-	var body map[string]interface{}
-
-	if err := json.Unmarshal(bodyBytes, &body); err != nil {
-		return nil, err
+	resp, bodyBytes, err := e.do(req, data)
+	if err != nil {
+		return nil, nil, err
 	}
-	return body, err
+	if err := checkResponse(resp, bodyBytes); err != nil {
+		return nil, nil, err
+	}
+	return resp, bodyBytes, nil
 }
 
 func (e Es) putJSON(path string, data string) (map[string]interface{}, error) {
@@ -123,62 +205,19 @@ func (e Es) getJSONWithBody(path string, data string) (map[string]interface{}, e
 }
 
 func (e Es) getData(path string) ([]byte, error) {
-	pathURL, err := url.Parse(path)
+	req, err := e.newRequest(http.MethodGet, path, nil)
 	if err != nil {
 		return nil, err
 	}
-	reqURL := e.esURL.ResolveReference(pathURL)
-	if e.Debug {
-		fmt.Printf("Request: GET %s\n\n", reqURL.String())
-	}
-	resp, err := e.client.Get(reqURL.String())
-	if err != nil {
-		return nil, err
-	}
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	if e.Debug {
-		if err != nil {
-			fmt.Println("Response error: ", err.Error())
-		} else {
-			dumpResponse(resp, bodyBytes)
-		}
-	}
-
-	return bodyBytes, nil
+	return e.execute(req, "")
 }
 
 func (e Es) getJSON(path string) (map[string]interface{}, error) {
-	pathURL, err := url.Parse(path)
-	if err != nil {
-		return nil, err
-	}
-	reqURL := e.esURL.ResolveReference(pathURL)
-	if e.Debug {
-		fmt.Printf("Request: GET %s\n\n", reqURL.String())
-	}
-	resp, err := e.client.Get(reqURL.String())
-	if err != nil {
-		return nil, err
-	}
-
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	bodyBytes, err := e.getData(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if e.Debug {
-		if err != nil {
-			fmt.Println("Response error: ", err.Error())
-		} else {
-			dumpResponse(resp, bodyBytes)
-		}
-	}
-
 	var body map[string]interface{}
 
 	if err := json.Unmarshal(bodyBytes, &body); err != nil {
@@ -188,37 +227,16 @@ func (e Es) getJSON(path string) (map[string]interface{}, error) {
 }
 
 func (e Es) delete(path string) (map[string]interface{}, error) {
-	pathURL, err := url.Parse(path)
-	if err != nil {
-		return nil, err
-	}
-	reqURL := e.esURL.ResolveReference(pathURL)
-	if e.Debug {
-		fmt.Printf("Request: GET %s\n\n", reqURL.String())
-	}
-	req, err := http.NewRequest(http.MethodDelete, reqURL.String(), nil)
+	req, err := e.newRequest(http.MethodDelete, path, nil)
 	if err != nil {
 		return nil, err
 	}
-	if e.Debug {
-		dumpRequest(req, "")
-	}
-
-	resp, err := e.client.Do(req)
 
-	bodyBytes, err := ioutil.ReadAll(resp.Body)
+	bodyBytes, err := e.execute(req, "")
 	if err != nil {
 		return nil, err
 	}
 
-	if e.Debug {
-		if err != nil {
-			fmt.Println("Response error: ", err.Error())
-		} else {
-			dumpResponse(resp, bodyBytes)
-		}
-	}
-
 	var body map[string]interface{}
 
 	if err := json.Unmarshal(bodyBytes, &body); err != nil {
@@ -227,25 +245,3 @@ func (e Es) delete(path string) (map[string]interface{}, error) {
 
 	return body, err
 }
-
-func dumpRequest(req *http.Request, body string) {
-	fmt.Println(req.Method, req.URL.String(), req.Proto)
-	for key := range req.Header {
-		fmt.Println(key, ":", req.Header.Get(key))
-	}
-	fmt.Println()
-	fmt.Println(body)
-	fmt.Println()
-}
-
-func dumpResponse(resp *http.Response, respBody []byte) {
-	fmt.Println(resp.Status, resp.Proto)
-
-	for key := range resp.Header {
-		fmt.Println(key, ":", resp.Header.Get(key))
-	}
-	fmt.Println()
-
-	fmt.Println(string(respBody))
-	fmt.Println()
-}
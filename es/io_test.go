@@ -0,0 +1,236 @@
+package es
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"net/url"
+	"shelastic/test/esfake"
+	"testing"
+	"time"
+)
+
+// testClient creates client sending requests to given servers without connecting to them
+func testClient(t *testing.T, servers ...string) *Es {
+	var urls []*url.URL
+	for _, server := range servers {
+		u, err := url.Parse(server)
+		if err != nil {
+			t.Fatal(err)
+		}
+		urls = append(urls, u)
+	}
+	return &Es{client: &http.Client{}, hosts: newHostPool(urls)}
+}
+
+func hostAlive(t *testing.T, e *Es, server string) bool {
+	for _, host := range e.Hosts() {
+		if host.URL == server {
+			return host.Alive
+		}
+	}
+	t.Fatalf("Host %s is not in the pool", server)
+	return false
+}
+
+func TestFailoverToLiveNode(t *testing.T) {
+	fake := esfake.New(esfake.ES7)
+	defer fake.Close()
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+
+	conn, ping, err := Connect(dead.URL+","+fake.URL, nil)
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	if ping.ClusterName != fake.ClusterName {
+		t.Errorf("Cluster name is %q, expected %q", ping.ClusterName, fake.ClusterName)
+	}
+	if hostAlive(t, conn, dead.URL) {
+		t.Errorf("Closed node %s is not marked dead", dead.URL)
+	}
+	if !hostAlive(t, conn, fake.URL) {
+		t.Errorf("Live node %s is marked dead", fake.URL)
+	}
+	if _, err := conn.Health(); err != nil {
+		t.Errorf("Request after failover failed: %s", err)
+	}
+}
+
+func TestProxyFailureMarksNodeDead(t *testing.T) {
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"green"}`))
+	}))
+	defer node.Close()
+
+	e := testClient(t, proxy.URL, node.URL)
+	health, err := e.Health()
+	if err != nil {
+		t.Fatalf("Request was not retried on another node: %s", err)
+	}
+	if health.Status != "green" {
+		t.Errorf("Status is %q, expected green", health.Status)
+	}
+	if hostAlive(t, e, proxy.URL) {
+		t.Errorf("Node behind failing proxy is not marked dead")
+	}
+}
+
+func TestClusterErrorKeepsNodesAlive(t *testing.T) {
+	requests := 0
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"error":{"type":"search_phase_execution_exception","reason":"all shards failed"},"status":503}`))
+	})
+	first := httptest.NewServer(handler)
+	defer first.Close()
+	second := httptest.NewServer(handler)
+	defer second.Close()
+
+	e := testClient(t, first.URL, second.URL)
+	_, err := e.getData("/_search")
+	esErr, ok := AsError(err)
+	if !ok {
+		t.Fatalf("Expected Elasticsearch error, got %v", err)
+	}
+	if esErr.Status != http.StatusServiceUnavailable || esErr.Type != "search_phase_execution_exception" {
+		t.Errorf("Unexpected error: %d %s", esErr.Status, esErr.Type)
+	}
+	if requests != 1 {
+		t.Errorf("Request was sent %d times, expected once", requests)
+	}
+	for _, server := range []string{first.URL, second.URL} {
+		if !hostAlive(t, e, server) {
+			t.Errorf("Node %s is marked dead after cluster error", server)
+		}
+	}
+}
+
+func TestUnresponsiveNodeTimesOut(t *testing.T) {
+	fake := esfake.New(esfake.ES7)
+	defer fake.Close()
+	release := make(chan struct{})
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer hung.Close()
+	defer close(release)
+
+	conn, _, err := Connect(hung.URL+","+fake.URL, &ConnectOptions{ResponseTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Request was not retried after timeout: %s", err)
+	}
+	if hostAlive(t, conn, hung.URL) {
+		t.Errorf("Unresponsive node %s is not marked dead", hung.URL)
+	}
+}
+
+func TestSniffedNodesUseSeedScheme(t *testing.T) {
+	fake := esfake.New(esfake.ES7)
+	defer fake.Close()
+	target, err := url.Parse(fake.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// seed node is a proxy, so the fake reports address different from the seed
+	seed := httptest.NewServer(httputil.NewSingleHostReverseProxy(target))
+	defer seed.Close()
+
+	// TLS options would make https the default scheme, but the seed is explicitly plain http
+	options := &ConnectOptions{TLS: &TLSOptions{ServerName: "localhost"}, Sniff: true}
+	conn, _, err := Connect(seed.URL, options)
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	hostAlive(t, conn, seed.URL)
+	hostAlive(t, conn, fake.URL)
+}
+
+func TestListIndicesReportsAliasesError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/_alias" {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"error":{"type":"security_exception","reason":"action [indices:admin/aliases/get] is unauthorized"},"status":403}`))
+			return
+		}
+		w.Write([]byte(`{"indices":{"books":{"primaries":{"docs":{"count":1,"deleted":0},"store":{"size_in_bytes":100}}}}}`))
+	}))
+	defer server.Close()
+
+	e := testClient(t, server.URL)
+	_, err := e.ListIndices()
+	if !IsErrorType(err, "security_exception") {
+		t.Errorf("Expected security_exception, got %v", err)
+	}
+}
+
+func TestAuthenticationHeaders(t *testing.T) {
+	tests := []struct {
+		name     string
+		auth     *AuthOptions
+		expected string
+	}{
+		{"no credentials", nil, ""},
+		{"basic", &AuthOptions{Username: "elastic", Password: "secret"}, "Basic ZWxhc3RpYzpzZWNyZXQ="},
+		{"api key with id", &AuthOptions{APIKey: "VuaCfGcBCdbkQm-e5aOx:ui2lp2axTNmsyakw9tvNnw"},
+			"ApiKey VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw=="},
+		{"encoded api key", &AuthOptions{APIKey: "VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw=="},
+			"ApiKey VnVhQ2ZHY0JDZGJrUW0tZTVhT3g6dWkybHAyYXhUTm1zeWFrdzl0dk5udw=="},
+		{"bearer token", &AuthOptions{BearerToken: "dGhpcyBpcyBub3QgYSByZWFsIHRva2Vu"}, "Bearer dGhpcyBpcyBub3QgYSByZWFsIHRva2Vu"},
+		{"api key takes precedence", &AuthOptions{Username: "elastic", Password: "secret", APIKey: "a2V5", BearerToken: "token"},
+			"ApiKey a2V5"},
+	}
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get("Authorization")
+		w.Write([]byte(`{"status":"green"}`))
+	}))
+	defer server.Close()
+
+	for _, test := range tests {
+		e := testClient(t, server.URL)
+		e.auth = test.auth
+		if _, err := e.Health(); err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if received != test.expected {
+			t.Errorf("%s: Authorization is %q, expected %q", test.name, received, test.expected)
+		}
+	}
+}
+
+func TestAuthenticationErrors(t *testing.T) {
+	tests := []struct {
+		status int
+		body   string
+		reason string
+	}{
+		{http.StatusUnauthorized, `{"error":{"type":"security_exception","reason":"missing authentication credentials for REST request [/]"},"status":401}`,
+			"missing authentication credentials for REST request [/]"},
+		{http.StatusForbidden, `{"error":{"type":"security_exception","reason":"action [cluster:monitor/main] is unauthorized for user [reader]"},"status":403}`,
+			"action [cluster:monitor/main] is unauthorized for user [reader]"},
+		// proxies in front of the cluster may respond without JSON body
+		{http.StatusUnauthorized, "", "Unauthorized"},
+	}
+	for _, test := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(test.status)
+			w.Write([]byte(test.body))
+		}))
+		_, _, err := Connect(server.URL, &ConnectOptions{Auth: &AuthOptions{Username: "reader", Password: "wrong"}})
+		server.Close()
+		esErr, ok := AsError(err)
+		if !ok {
+			t.Errorf("%d: expected Elasticsearch error, got %v", test.status, err)
+			continue
+		}
+		if esErr.Status != test.status || esErr.Error() != test.reason {
+			t.Errorf("%d: unexpected error %d %q", test.status, esErr.Status, esErr.Error())
+		}
+	}
+}
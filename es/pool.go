@@ -0,0 +1,180 @@
+package es
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	initialDeadTimeout = 1 * time.Second
+	maxDeadTimeout     = 60 * time.Second
+)
+
+// poolHost is a single cluster node known to the host pool
+type poolHost struct {
+	url       *url.URL
+	failures  uint
+	deadUntil time.Time
+}
+
+// hostPool keeps list of cluster nodes and selects node to send each request to.
+// Nodes are selected in round-robin fashion, nodes which failed to respond are skipped
+// until their back-off timeout expires
+type hostPool struct {
+	mutex sync.Mutex
+	hosts []*poolHost
+	next  int
+	// answered is the host which served the last successful request
+	answered *poolHost
+}
+
+// parseHosts converts comma-separated list of host names to URLs. Port 9200 is used for hosts without port,
+// defaultScheme is used for hosts without scheme
+func parseHosts(hostList string, defaultScheme string) ([]*url.URL, error) {
+	var result []*url.URL
+	for _, host := range strings.Split(hostList, ",") {
+		host = strings.TrimSpace(host)
+		if host == "" {
+			continue
+		}
+		if !strings.Contains(host, ":") {
+			host = host + ":9200"
+		}
+		if !strings.Contains(host, "://") {
+			host = defaultScheme + "://" + host
+		}
+		u, err := url.Parse(host)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, u)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("No hosts specified")
+	}
+	return result, nil
+}
+
+func newHostPool(urls []*url.URL) *hostPool {
+	pool := &hostPool{}
+	for _, u := range urls {
+		pool.add(u)
+	}
+	return pool
+}
+
+// add adds a new host to the pool, unless the pool already contains host with the same address
+func (p *hostPool) add(u *url.URL) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, h := range p.hosts {
+		if h.url.Host == u.Host {
+			return
+		}
+	}
+	p.hosts = append(p.hosts, &poolHost{url: u})
+}
+
+// pick selects next live host. If all hosts are dead, then host whose back-off timeout expires first is returned
+func (p *hostPool) pick() *poolHost {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	for i := 0; i < len(p.hosts); i++ {
+		h := p.hosts[(p.next+i)%len(p.hosts)]
+		if !h.deadUntil.After(now) {
+			p.next = (p.next + i + 1) % len(p.hosts)
+			return h
+		}
+	}
+	var best *poolHost
+	for _, h := range p.hosts {
+		if best == nil || h.deadUntil.Before(best.deadUntil) {
+			best = h
+		}
+	}
+	return best
+}
+
+// find returns pool host with given address (host:port) or nil if there is no such host in the pool
+func (p *hostPool) find(address string) *poolHost {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	for _, h := range p.hosts {
+		if h.url.Host == address {
+			return h
+		}
+	}
+	return nil
+}
+
+// markDead marks host as failed. Host will not be used until back-off timeout expires.
+// Timeout doubles with every consecutive failure
+func (p *hostPool) markDead(h *poolHost) {
+	if h == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	timeout := initialDeadTimeout << h.failures
+	if timeout > maxDeadTimeout || timeout <= 0 {
+		timeout = maxDeadTimeout
+	} else {
+		h.failures++
+	}
+	h.deadUntil = time.Now().Add(timeout)
+}
+
+// markAlive resets failure counter of the host
+func (p *hostPool) markAlive(h *poolHost) {
+	if h == nil {
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	h.failures = 0
+	h.deadUntil = time.Time{}
+	p.answered = h
+}
+
+// lastAnswered returns URL of the host which served the last successful request or nil if there was no such request
+func (p *hostPool) lastAnswered() *url.URL {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	if p.answered == nil {
+		return nil
+	}
+	return p.answered.url
+}
+
+// size returns number of hosts in the pool
+func (p *hostPool) size() int {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	return len(p.hosts)
+}
+
+// HostStatus contains address of cluster node known to Es client and its availability
+type HostStatus struct {
+	URL       string    `json:"url"`
+	Alive     bool      `json:"alive"`
+	DeadUntil time.Time `json:"dead_until"`
+}
+
+func (p *hostPool) status() []HostStatus {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	now := time.Now()
+	result := make([]HostStatus, len(p.hosts))
+	for i, h := range p.hosts {
+		result[i] = HostStatus{
+			URL:       h.url.String(),
+			Alive:     !h.deadUntil.After(now),
+			DeadUntil: h.deadUntil,
+		}
+	}
+	return result
+}
@@ -0,0 +1,179 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Types in this file mirror responses of Elasticsearch APIs used by shelastic. Only the fields shelastic
+// needs are declared, so that responses of different Elasticsearch versions can be decoded into the same types
+
+// rootResponse is a response to GET /
+type rootResponse struct {
+	Name        string `json:"name"`
+	ClusterName string `json:"cluster_name"`
+	Version     struct {
+		Number string `json:"number"`
+		// Distribution is only reported by OpenSearch
+		Distribution string `json:"distribution"`
+	} `json:"version"`
+}
+
+// statsResponse is a response to GET /_stats
+type statsResponse struct {
+	Indices map[string]struct {
+		Primaries indexStats `json:"primaries"`
+		Total     indexStats `json:"total"`
+	} `json:"indices"`
+}
+
+type indexStats struct {
+	Docs struct {
+		Count   int `json:"count"`
+		Deleted int `json:"deleted"`
+	} `json:"docs"`
+	Store struct {
+		SizeInBytes int `json:"size_in_bytes"`
+	} `json:"store"`
+}
+
+// catIndicesResponse is a response to GET /_cat/indices?format=json. All values are returned as strings,
+// document counts and sizes are empty for closed indices
+type catIndicesResponse []struct {
+	Health           string `json:"health"`
+	Status           string `json:"status"`
+	Index            string `json:"index"`
+	UUID             string `json:"uuid"`
+	Primaries        string `json:"pri"`
+	Replicas         string `json:"rep"`
+	DocsCount        string `json:"docs.count"`
+	DocsDeleted      string `json:"docs.deleted"`
+	StoreSize        string `json:"store.size"`
+	PrimaryStoreSize string `json:"pri.store.size"`
+	CreationDate     string `json:"creation.date"`
+}
+
+// clusterStateResponse is a response to GET /_cluster/state/metadata
+type clusterStateResponse struct {
+	Metadata struct {
+		Indices map[string]struct {
+			State    string                 `json:"state"`
+			Settings map[string]interface{} `json:"settings"`
+		} `json:"indices"`
+	} `json:"metadata"`
+}
+
+// indicesHealthResponse is a response to GET /_cluster/health?level=indices
+type indicesHealthResponse struct {
+	Indices map[string]struct {
+		Status string `json:"status"`
+	} `json:"indices"`
+}
+
+// aliasesResponse is a response to GET /_alias and GET /{index}/_alias/*
+type aliasesResponse map[string]struct {
+	Aliases map[string]map[string]interface{} `json:"aliases"`
+}
+
+// mappingsResponse is a response to GET /{index}/_mapping
+type mappingsResponse map[string]map[string]interface{}
+
+// settingsResponse is a response to GET /{index}/_settings
+type settingsResponse map[string]struct {
+	Settings struct {
+		Index map[string]interface{} `json:"index"`
+	} `json:"settings"`
+}
+
+// segmentsResponse is a response to GET /{index}/_segments
+type segmentsResponse struct {
+	Indices map[string]struct {
+		Shards map[string][]*ShardInfo `json:"shards"`
+	} `json:"indices"`
+}
+
+// nodesResponse is a response to GET /_nodes
+type nodesResponse struct {
+	Nodes map[string]struct {
+		Name             string `json:"name"`
+		TransportAddress string `json:"transport_address"`
+		Host             string `json:"host"`
+		IP               string `json:"ip"`
+		HTTPAddress      string `json:"http_address"`
+		HTTP             struct {
+			PublishAddress string `json:"publish_address"`
+		} `json:"http"`
+	} `json:"nodes"`
+}
+
+// documentResponse is a response to document index and delete APIs.
+// ES 5.x+ report outcome in "result" field, older versions use "created" and "found" flags
+type documentResponse struct {
+	Result  string `json:"result"`
+	Created *bool  `json:"created"`
+	Found   *bool  `json:"found"`
+}
+
+// searchResponse is a response to search and scroll APIs
+type searchResponse struct {
+	ScrollID string `json:"_scroll_id"`
+	// PitID is returned by searches within point in time, it may differ from the id the search was made with
+	PitID string `json:"pit_id"`
+	Hits  struct {
+		Total hitsTotal   `json:"total"`
+		Hits  []searchHit `json:"hits"`
+	} `json:"hits"`
+	Aggregations map[string]interface{} `json:"aggregations"`
+}
+
+// pitResponse is a response to open point in time API
+type pitResponse struct {
+	ID string `json:"id"`
+}
+
+// searchHit is a single search result. Hits are kept as generic maps as they are exported and printed as is
+type searchHit map[string]interface{}
+
+// str returns string field of a hit or empty string if there is no such field
+func (h searchHit) str(name string) string {
+	s, _ := h[name].(string)
+	return s
+}
+
+// hitsTotal is a total number of hits. ES 7.x+ returns it as an object with value and relation,
+// earlier versions return plain number
+type hitsTotal struct {
+	Value    int
+	Relation string
+}
+
+func (t *hitsTotal) UnmarshalJSON(data []byte) error {
+	var value int
+	if err := json.Unmarshal(data, &value); err == nil {
+		t.Value = value
+		t.Relation = "eq"
+		return nil
+	}
+	var total struct {
+		Value    int    `json:"value"`
+		Relation string `json:"relation"`
+	}
+	if err := json.Unmarshal(data, &total); err != nil {
+		return fmt.Errorf("Cannot parse hits.total: %s", string(data))
+	}
+	t.Value = total.Value
+	t.Relation = total.Relation
+	return nil
+}
+
+// bulkResponse is a response to _bulk API
+type bulkResponse struct {
+	Errors *bool `json:"errors"`
+}
+
+// snapshotsResponse is a response to GET /_snapshot/{repo}/_all
+type snapshotsResponse struct {
+	Snapshots []struct {
+		Snapshot string `json:"snapshot"`
+	} `json:"snapshots"`
+}
@@ -0,0 +1,225 @@
+package es
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// Fixtures in testdata are responses recorded from clusters of the version the directory is named after
+
+// fixture reads recorded response of a given cluster version. Returns nil if there is no such response
+func fixture(t *testing.T, version string, name string) []byte {
+	data, err := ioutil.ReadFile(filepath.Join("testdata", version, name+".json"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+var fixtureVersions = []string{"1.7.6", "2.4.6", "5.6.16", "6.8.23", "7.17.9", "8.11.1"}
+
+func TestDecodeClusterHealth(t *testing.T) {
+	tests := map[string]ClusterHealth{
+		"1.7.6":  {ClusterName: "elasticsearch", Status: "yellow", NodeCount: 1, DataNodeCount: 1, ActiveShards: 5, ActivePrimaryShards: 5, UnassignedShards: 5},
+		"2.4.6":  {ClusterName: "elasticsearch", Status: "yellow", NodeCount: 1, DataNodeCount: 1, ActiveShards: 5, ActivePrimaryShards: 5, UnassignedShards: 5},
+		"5.6.16": {ClusterName: "elasticsearch", Status: "yellow", NodeCount: 1, DataNodeCount: 1, ActiveShards: 5, ActivePrimaryShards: 5, UnassignedShards: 5},
+		"6.8.23": {ClusterName: "docker-cluster", Status: "yellow", NodeCount: 1, DataNodeCount: 1, ActiveShards: 5, ActivePrimaryShards: 5, UnassignedShards: 5},
+		"7.17.9": {ClusterName: "docker-cluster", Status: "green", NodeCount: 3, DataNodeCount: 3, ActiveShards: 8, ActivePrimaryShards: 4},
+		"8.11.1": {ClusterName: "docker-cluster", Status: "yellow", NodeCount: 1, DataNodeCount: 1, ActiveShards: 2, ActivePrimaryShards: 2, UnassignedShards: 1},
+	}
+	for _, version := range fixtureVersions {
+		var health ClusterHealth
+		if err := decodeResponse("/_cluster/health", fixture(t, version, "health"), &health); err != nil {
+			t.Errorf("%s: %s", version, err)
+			continue
+		}
+		if health != tests[version] {
+			t.Errorf("%s:\n got      %+v\n expected %+v", version, health, tests[version])
+		}
+	}
+}
+
+func TestDecodeCatIndices(t *testing.T) {
+	for _, version := range fixtureVersions {
+		data := fixture(t, version, "cat_indices")
+		if data == nil {
+			// _cat APIs do not support JSON before 5.0
+			continue
+		}
+		var body catIndicesResponse
+		if err := decodeResponse("/_cat/indices", data, &body); err != nil {
+			t.Errorf("%s: %s", version, err)
+			continue
+		}
+		if len(body) == 0 || body[0].Index != "books" {
+			t.Errorf("%s: books index is not decoded: %+v", version, body)
+			continue
+		}
+		books := body[0]
+		if parseCatNumber(books.DocsCount) != 2 || parseCatNumber(books.PrimaryStoreSize) != 8234 ||
+			parseEpochMillis(books.CreationDate).Unix() != 1697000000 {
+			t.Errorf("%s: unexpected books index summary %+v", version, books)
+		}
+		for _, row := range body[1:] {
+			// counts and sizes of closed indices are null
+			if row.Status == "close" && (row.DocsCount != "" || parseCatNumber(row.StoreSize) != 0) {
+				t.Errorf("%s: closed index %s has counts %+v", version, row.Index, row)
+			}
+		}
+	}
+}
+
+func TestDecodeClusterState(t *testing.T) {
+	for _, version := range fixtureVersions {
+		data := fixture(t, version, "cluster_state")
+		if data == nil {
+			continue
+		}
+		var state clusterStateResponse
+		if err := decodeResponse("/_cluster/state/metadata", data, &state); err != nil {
+			t.Errorf("%s: %s", version, err)
+			continue
+		}
+		books, ok := state.Metadata.Indices["books"]
+		if !ok {
+			t.Errorf("%s: books index is not decoded", version)
+			continue
+		}
+		// 1.x reports settings with flat names, 2.x nests them
+		if indexSetting(books.Settings, "uuid") != "pHZbqW8PTp2Wn4y4Ktf7Ig" || indexSetting(books.Settings, "number_of_shards") != "5" ||
+			indexSetting(books.Settings, "creation_date") != "1697000000000" || books.State != "open" {
+			t.Errorf("%s: unexpected books index metadata %+v", version, books)
+		}
+	}
+}
+
+func TestDecodeSearchHits(t *testing.T) {
+	tests := map[string]struct {
+		total    hitsTotal
+		scrollID bool
+		pitID    bool
+	}{
+		"1.7.6":  {hitsTotal{2, "eq"}, true, false},
+		"2.4.6":  {hitsTotal{2, "eq"}, true, false},
+		"5.6.16": {hitsTotal{2, "eq"}, true, false},
+		"6.8.23": {hitsTotal{2, "eq"}, true, false},
+		"7.17.9": {hitsTotal{10000, "gte"}, false, true},
+		"8.11.1": {hitsTotal{2, "eq"}, false, true},
+	}
+	for _, version := range fixtureVersions {
+		var body searchResponse
+		if err := decodeResponse("/books/_search", fixture(t, version, "search"), &body); err != nil {
+			t.Errorf("%s: %s", version, err)
+			continue
+		}
+		expected := tests[version]
+		if body.Hits.Total != expected.total {
+			t.Errorf("%s: hits.total is %+v, expected %+v", version, body.Hits.Total, expected.total)
+		}
+		if (body.ScrollID != "") != expected.scrollID || (body.PitID != "") != expected.pitID {
+			t.Errorf("%s: unexpected scroll id %q or pit id %q", version, body.ScrollID, body.PitID)
+		}
+		var ids []string
+		for _, hit := range body.Hits.Hits {
+			ids = append(ids, hit.str("_id"))
+		}
+		if !reflect.DeepEqual(ids, []string{"1", "2"}) {
+			t.Errorf("%s: hit ids are %v", version, ids)
+		}
+	}
+
+	var body searchResponse
+	if err := decodeResponse("/_search", []byte(`{"hits":{"total":"many","hits":[]}}`), &body); err == nil {
+		t.Errorf("Invalid hits.total is decoded without error")
+	}
+}
+
+func TestDecodeMappings(t *testing.T) {
+	tests := map[string]struct {
+		doc      string
+		textType string
+		typeless bool
+	}{
+		"1.7.6":  {doc: "book", textType: "string"},
+		"2.4.6":  {doc: "book", textType: "string"},
+		"5.6.16": {doc: "book", textType: "text"},
+		"6.8.23": {doc: "_doc", textType: "text"},
+		"7.17.9": {textType: "text", typeless: true},
+		"8.11.1": {textType: "text", typeless: true},
+	}
+	for _, version := range fixtureVersions {
+		data := fixture(t, version, "mapping")
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write(data)
+		}))
+		expected := tests[version]
+		e := testClient(t, server.URL)
+		e.Capabilities.Typeless = expected.typeless
+
+		properties, err := e.ListProperties("books", expected.doc)
+		server.Close()
+		if err != nil {
+			t.Errorf("%s: %s", version, err)
+			continue
+		}
+		sort.Slice(properties, func(i, j int) bool { return properties[i].Name < properties[j].Name })
+		want := []DocumentProperty{{Name: "title", Type: expected.textType}, {Name: "year", Type: "long"}}
+		if !reflect.DeepEqual(properties, want) {
+			t.Errorf("%s: properties are %+v, expected %+v", version, properties, want)
+		}
+	}
+}
+
+func TestDecodeErrorBody(t *testing.T) {
+	tests := map[string]Error{
+		"1.7.6":  {Status: 404, Type: "index_missing_exception", Reason: "[missing] missing"},
+		"2.4.6":  {Status: 404, Type: "index_not_found_exception", Reason: "no such index", Index: "missing"},
+		"5.6.16": {Status: 404, Type: "index_not_found_exception", Reason: "no such index", Index: "missing"},
+		"6.8.23": {Status: 404, Type: "index_not_found_exception", Reason: "no such index", Index: "missing"},
+		"7.17.9": {Status: 404, Type: "index_not_found_exception", Reason: "no such index [missing]", Index: "missing"},
+		"8.11.1": {Status: 404, Type: "index_not_found_exception", Reason: "no such index [missing]", Index: "missing"},
+	}
+	for _, version := range fixtureVersions {
+		err := checkResponse(&http.Response{StatusCode: http.StatusNotFound}, fixture(t, version, "error"))
+		esErr, ok := AsError(err)
+		if !ok {
+			t.Errorf("%s: expected Elasticsearch error, got %v", version, err)
+			continue
+		}
+		expected := tests[version]
+		if esErr.Status != expected.Status || esErr.Type != expected.Type || esErr.Reason != expected.Reason || esErr.Index != expected.Index {
+			t.Errorf("%s:\n got      %+v\n expected %+v", version, *esErr, expected)
+		}
+		if version != "1.7.6" && (len(esErr.RootCause) != 1 || esErr.RootCause[0].Type != expected.Type) {
+			t.Errorf("%s: root cause is %+v", version, esErr.RootCause)
+		}
+	}
+}
+
+func TestDecodeShardFailures(t *testing.T) {
+	err := checkResponse(&http.Response{StatusCode: http.StatusBadRequest}, fixture(t, "7.17.9", "search_error"))
+	esErr, ok := AsError(err)
+	if !ok {
+		t.Fatalf("Expected Elasticsearch error, got %v", err)
+	}
+	if esErr.Status != http.StatusBadRequest || esErr.Type != "search_phase_execution_exception" {
+		t.Errorf("Unexpected error: %d %s", esErr.Status, esErr.Type)
+	}
+	if len(esErr.ShardFailures) != 1 {
+		t.Fatalf("Expected one shard failure, got %+v", esErr.ShardFailures)
+	}
+	failure := esErr.ShardFailures[0]
+	if failure.Index != "books" || failure.Node != "BjDTkydqQWGjNMaFplHsgw" || failure.Reason == nil ||
+		failure.Reason.CausedBy == nil || failure.Reason.CausedBy.Type != "number_format_exception" {
+		t.Errorf("Unexpected shard failure %+v", failure)
+	}
+}
@@ -0,0 +1,43 @@
+package es
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// RestResponse is a response to arbitrary REST request
+type RestResponse struct {
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IsJSON checks if response body contains JSON
+func (r *RestResponse) IsJSON() bool {
+	return strings.Contains(r.ContentType, "json") && json.Valid(r.Body)
+}
+
+// Rest executes arbitrary request. Path may include query string, leading slash is optional.
+// Bodies of _bulk and _msearch requests are sent as NDJSON
+func (e Es) Rest(method string, path string, body string) (*RestResponse, error) {
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	contentType := ""
+	endpoint := strings.SplitN(path, "?", 2)[0]
+	if e.Capabilities.NDJSONContentType && (strings.HasSuffix(endpoint, "/_bulk") || strings.HasSuffix(endpoint, "/_msearch")) {
+		contentType = "application/x-ndjson"
+		if !strings.HasSuffix(body, "\n") {
+			body += "\n"
+		}
+	}
+	resp, bodyBytes, err := e.requestResponse(strings.ToUpper(method), path, body, contentType)
+	if err != nil {
+		return nil, err
+	}
+	return &RestResponse{
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		Body:        bodyBytes,
+	}, nil
+}
@@ -0,0 +1,213 @@
+package es
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// pitKeepAlive is how long point in time is kept between requests for pages
+const pitKeepAlive = "5m"
+
+// SearchOptions are paging, source filtering and highlighting parameters of a search
+type SearchOptions struct {
+	// From is an offset of the first page
+	From int
+	// Size is a number of hits on a page
+	Size int
+	// Fields limits _source of hits to the fields, all fields are returned if it is empty
+	Fields []string
+	// Highlight lists fields which matches are returned in highlight of the hits
+	Highlight []string
+}
+
+// SearchPager keeps state of paging through search results. Pages are retrieved with FetchPage.
+// On clusters supporting point in time, pages after the first one are retrieved with search_after within point in time,
+// so that results do not shift when documents change and deep pages are cheap. Other clusters are paged with from and size
+type SearchPager struct {
+	Index string
+	// Size is a number of hits on a page
+	Size int
+	// From is an offset of the first page
+	From int
+	// Page is a number of the last retrieved page, starting with 0. It is -1 before the first page is retrieved
+	Page int
+
+	doc string
+	// body is a query without paging parameters, urlQuery is a query of URL search
+	body     map[string]interface{}
+	urlQuery string
+	pitID    string
+	// cursors contain search_after values of the pages reached so far, the first page has none
+	cursors [][]interface{}
+	// sortAdded is set if sort was added to the query for search_after. Sort values are removed from hits then
+	sortAdded bool
+	more      bool
+}
+
+// NewQueryPager prepares paging through results of Query DSL search. Paging parameters of the query are replaced
+// with from and size. The query is passed to query recorder
+func (e Es) NewQueryPager(index string, doc string, query string, options SearchOptions) (*SearchPager, error) {
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(query), &body); err != nil {
+		return nil, fmt.Errorf("Invalid query JSON: %s", err.Error())
+	}
+	p, err := e.newPager(index, doc, body, "", options)
+	if err == nil {
+		e.recordQuery(index, query)
+	}
+	return p, err
+}
+
+// NewSearchPager prepares paging through results of URL search
+func (e Es) NewSearchPager(index string, doc string, query string, options SearchOptions) (*SearchPager, error) {
+	return e.newPager(index, doc, map[string]interface{}{}, query, options)
+}
+
+func (e Es) newPager(index string, doc string, body map[string]interface{}, urlQuery string, options SearchOptions) (*SearchPager, error) {
+	if options.Size < 0 {
+		return nil, fmt.Errorf("Page size must not be negative")
+	}
+	if options.From < 0 {
+		return nil, fmt.Errorf("Offset must not be negative")
+	}
+	for _, param := range []string{"from", "size", "search_after", "pit"} {
+		delete(body, param)
+	}
+	if _, ok := body["track_total_hits"]; !ok && e.Capabilities.TrackTotalHits {
+		body["track_total_hits"] = true
+	}
+	if len(options.Fields) > 0 {
+		body["_source"] = options.Fields
+	}
+	if len(options.Highlight) > 0 {
+		fields := make(map[string]interface{}, len(options.Highlight))
+		for _, field := range options.Highlight {
+			fields[field] = map[string]interface{}{}
+		}
+		body["highlight"] = map[string]interface{}{"fields": fields}
+	}
+	p := &SearchPager{Index: index, Size: options.Size, From: options.From, Page: -1, doc: doc, body: body,
+		urlQuery: urlQuery, cursors: [][]interface{}{nil}}
+
+	// without hits, e.g. when only aggregations are requested, there is nothing to page through.
+	// Point in time is opened for an index, search across all indices is paged with from and size
+	if e.Capabilities.PointInTime && options.Size > 0 && index != "" {
+		var response pitResponse
+		err := e.requestInto(http.MethodPost, fmt.Sprintf("/%s/_pit?keep_alive=%s", index, pitKeepAlive), "", &response)
+		if err != nil {
+			return nil, err
+		}
+		p.pitID = response.ID
+		if _, ok := body["sort"]; !ok {
+			// search_after needs sort values, relevance order is kept and ties are broken by position in the shard
+			body["sort"] = []interface{}{"_score", "_shard_doc"}
+			p.sortAdded = true
+		}
+	}
+	return p, nil
+}
+
+// FetchPage retrieves page of search results. Within point in time only pages up to the one following the last
+// retrieved page can be requested
+func (e Es) FetchPage(p *SearchPager, page int) (*SearchResult, error) {
+	if page < 0 || (p.pitID != "" && page >= len(p.cursors)) {
+		return nil, fmt.Errorf("Page %d is not available", page+1)
+	}
+	offset := p.From + page*p.Size
+
+	body := make(map[string]interface{}, len(p.body)+4)
+	for k, v := range p.body {
+		body[k] = v
+	}
+	body["size"] = p.Size
+	if page > 0 {
+		// aggregations do not depend on the page, they are computed with the first page only
+		delete(body, "aggs")
+		delete(body, "aggregations")
+	}
+	var path string
+	if p.pitID != "" {
+		path = "/_search"
+		body["pit"] = map[string]interface{}{"id": p.pitID, "keep_alive": pitKeepAlive}
+		if page == 0 {
+			body["from"] = p.From
+		} else {
+			body["search_after"] = p.cursors[page]
+		}
+	} else {
+		path = e.searchPath(p.Index, p.doc)
+		body["from"] = offset
+	}
+	if p.urlQuery != "" {
+		path += "?q=" + url.QueryEscape(p.urlQuery)
+	}
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to repack query JSON: %s", err.Error())
+	}
+	var response searchResponse
+	if err := e.requestInto(http.MethodPost, path, string(data), &response); err != nil {
+		return nil, err
+	}
+	if response.PitID != "" {
+		p.pitID = response.PitID
+	}
+
+	result := newSearchResult(&response)
+	result.From = offset
+	result.Aggregations = parseAggregations(subAggregations(p.body), response.Aggregations)
+	p.Page = page
+	p.more = p.Size > 0 && len(result.Hits) == p.Size && (result.TotalRelation == "gte" || offset+len(result.Hits) < result.Total)
+	if p.pitID != "" && p.more {
+		sortValues, _ := result.Hits[len(result.Hits)-1]["sort"].([]interface{})
+		if sortValues == nil {
+			return nil, fmt.Errorf("Search hits have no sort values, next page cannot be requested")
+		}
+		p.cursors = append(p.cursors[:page+1], sortValues)
+	}
+	if p.sortAdded {
+		for _, hit := range result.Hits {
+			delete(hit, "sort")
+		}
+	}
+	return result, nil
+}
+
+// searchPath returns path of search request. Search without index runs across all indices
+func (e Es) searchPath(index string, doc string) string {
+	docPath := e.typePath(doc)
+	if index == "" {
+		if docPath == "" {
+			return "/_search"
+		}
+		index = "_all"
+	}
+	return fmt.Sprintf("/%s%s/_search", index, docPath)
+}
+
+// HasNext checks if there are hits after the last retrieved page
+func (p *SearchPager) HasNext() bool {
+	return p.more
+}
+
+// HasPrevious checks if there are pages before the last retrieved page
+func (p *SearchPager) HasPrevious() bool {
+	return p.Page > 0
+}
+
+// ClosePager releases point in time of the pager, if there is one
+func (e Es) ClosePager(p *SearchPager) error {
+	if p.pitID == "" {
+		return nil
+	}
+	data, err := json.Marshal(map[string]string{"id": p.pitID})
+	if err != nil {
+		return err
+	}
+	p.pitID = ""
+	_, err = e.requestData(http.MethodDelete, "/_pit", string(data), "")
+	return err
+}
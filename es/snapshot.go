@@ -14,6 +14,9 @@ type RepositoryList map[string]interface{}
 // ListRepository lists all available repositories
 func (e Es) ListRepository() (*RepositoryList, error) {
 	data, err := e.getJSON("/_snapshot/")
+	if err == nil {
+		err = checkError(data)
+	}
 	if err == nil {
 		result := &RepositoryList{}
 		utils.DictToAny(data, result)
@@ -82,11 +85,28 @@ func (e Es) GetSnapshotInfo(repo string, snapshotName string) (*SnapshotInfo, er
 	return result, nil
 }
 
+// ListSnapshots returns names of all snapshots in a repository
+func (e Es) ListSnapshots(repo string) ([]string, error) {
+	var body snapshotsResponse
+	err := e.getInto(fmt.Sprintf("/_snapshot/%s/_all", repo), &body)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, len(body.Snapshots))
+	for i, snapshot := range body.Snapshots {
+		result[i] = snapshot.Snapshot
+	}
+	return result, nil
+}
+
 // DeleteSnapshot deletes a snapshot with a given name from a repository
 func (e Es) DeleteSnapshot(repo string, snapshotName string) error {
 	url := fmt.Sprintf("/_snapshot/%s/%s", repo, snapshotName)
-	_, err := e.delete(url)
-	return err
+	resp, err := e.delete(url)
+	if err != nil {
+		return err
+	}
+	return checkError(resp)
 }
 
 // RestoreSnapshot restores a snapshot with a given name from a repository
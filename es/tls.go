@@ -0,0 +1,61 @@
+package es
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+)
+
+// TLSOptions contains TLS configuration used to connect to Elasticsearch cluster over HTTPS
+type TLSOptions struct {
+	CACert     string
+	ClientCert string
+	ClientKey  string
+	ServerName string
+	Insecure   bool
+}
+
+// Enabled returns true if any of TLS options is set
+func (t *TLSOptions) Enabled() bool {
+	if t == nil {
+		return false
+	}
+	return t.CACert != "" || t.ClientCert != "" || t.ClientKey != "" || t.ServerName != "" || t.Insecure
+}
+
+// config builds tls.Config from TLS options. Returns nil if no TLS options were set
+func (t *TLSOptions) config() (*tls.Config, error) {
+	if !t.Enabled() {
+		return nil, nil
+	}
+	config := &tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.Insecure,
+	}
+
+	if t.CACert != "" {
+		pem, err := ioutil.ReadFile(t.CACert)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to read CA certificate: %s", err.Error())
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No valid certificates found in %s", t.CACert)
+		}
+		config.RootCAs = pool
+	}
+
+	if t.ClientCert != "" || t.ClientKey != "" {
+		if t.ClientCert == "" || t.ClientKey == "" {
+			return nil, fmt.Errorf("Both client certificate and client key must be specified")
+		}
+		cert, err := tls.LoadX509KeyPair(t.ClientCert, t.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to load client certificate: %s", err.Error())
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
@@ -0,0 +1,153 @@
+package es
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http/httptest"
+	"path/filepath"
+	"shelastic/test/esfake"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testCertificate is a certificate with its key, signed by parent or self-signed if parent is nil
+type testCertificate struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+func newTestCertificate(t *testing.T, name string, isCA bool, parent *testCertificate) *testCertificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCertificate{cert: cert, key: key, der: der}
+}
+
+// writePEM writes PEM blocks to a file in test directory and returns its name
+func writePEM(t *testing.T, name string, blockType string, data []byte) string {
+	fileName := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(fileName, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: data}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return fileName
+}
+
+// startTLSFake starts fake cluster behind HTTPS. Address of the server is returned without scheme
+func startTLSFake(t *testing.T, configure func(*tls.Config)) (*httptest.Server, string) {
+	fake := esfake.New(esfake.ES7)
+	t.Cleanup(fake.Close)
+	server := httptest.NewUnstartedServer(fake)
+	// failed handshakes are expected
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.TLS = &tls.Config{}
+	if configure != nil {
+		configure(server.TLS)
+	}
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server, strings.TrimPrefix(server.URL, "https://")
+}
+
+func TestTrustedCA(t *testing.T) {
+	server, address := startTLSFake(t, nil)
+	caFile := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+
+	// https is used for host without scheme when TLS options are given
+	conn, _, err := Connect(address, &ConnectOptions{TLS: &TLSOptions{CACert: caFile}})
+	if err != nil {
+		t.Fatalf("Connect with trusted CA failed: %s", err)
+	}
+	if hosts := conn.Hosts(); len(hosts) != 1 || !strings.HasPrefix(hosts[0].URL, "https://") {
+		t.Errorf("Expected https host, got %v", hosts)
+	}
+}
+
+func TestUntrustedCA(t *testing.T) {
+	_, address := startTLSFake(t, nil)
+	other := newTestCertificate(t, "other-ca", true, nil)
+	caFile := writePEM(t, "other-ca.pem", "CERTIFICATE", other.der)
+
+	_, _, err := Connect(address, &ConnectOptions{TLS: &TLSOptions{CACert: caFile}})
+	if err == nil {
+		t.Fatalf("Connect with untrusted CA succeeded")
+	}
+	if !strings.Contains(err.Error(), "certificate") {
+		t.Errorf("Expected certificate error, got %s", err)
+	}
+}
+
+func TestInsecure(t *testing.T) {
+	_, address := startTLSFake(t, nil)
+	if _, _, err := Connect(address, &ConnectOptions{TLS: &TLSOptions{Insecure: true}}); err != nil {
+		t.Fatalf("Connect with --insecure failed: %s", err)
+	}
+}
+
+func TestPlainHTTPByDefault(t *testing.T) {
+	_, address := startTLSFake(t, nil)
+	if _, _, err := Connect(address, nil); err == nil {
+		t.Fatalf("Plain HTTP connection to HTTPS server succeeded")
+	}
+}
+
+func TestClientCertificate(t *testing.T) {
+	ca := newTestCertificate(t, "client-ca", true, nil)
+	client := newTestCertificate(t, "client", false, ca)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	_, address := startTLSFake(t, func(config *tls.Config) {
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	})
+	keyBytes, err := x509.MarshalECPrivateKey(client.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile := writePEM(t, "client.pem", "CERTIFICATE", client.der)
+	keyFile := writePEM(t, "client.key", "EC PRIVATE KEY", keyBytes)
+
+	if _, _, err := Connect(address, &ConnectOptions{TLS: &TLSOptions{Insecure: true}}); err == nil {
+		t.Errorf("Connect without client certificate succeeded")
+	}
+	options := &TLSOptions{Insecure: true, ClientCert: certFile, ClientKey: keyFile}
+	if _, _, err := Connect(address, &ConnectOptions{TLS: options}); err != nil {
+		t.Errorf("Connect with client certificate failed: %s", err)
+	}
+	options = &TLSOptions{Insecure: true, ClientCert: certFile}
+	if _, _, err := Connect(address, &ConnectOptions{TLS: options}); err == nil {
+		t.Errorf("Client certificate without key is accepted")
+	}
+}
@@ -0,0 +1,389 @@
+package es
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TraceText records requests and responses in human-readable form
+	TraceText = "text"
+	// TraceCurl records requests as curl command lines which can be executed outside of shelastic
+	TraceCurl = "curl"
+	// TraceHAR records requests and responses in HTTP Archive format
+	TraceHAR = "har"
+)
+
+const redactedValue = "[REDACTED]"
+
+// redactedHeaders contains headers which carry credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// harFooter closes HAR document. It is written after every entry so that trace file is valid at any time
+const harFooter = "\n]}}\n"
+
+// Tracer records every request sent to the cluster and its response to a file
+type Tracer struct {
+	mutex    sync.Mutex
+	file     *os.File
+	format   string
+	redact   bool
+	entries  int
+	FileName string
+}
+
+// TraceStatus is a state of the tracer at the moment it was taken
+type TraceStatus struct {
+	FileName string
+	Format   string
+	// Entries is a number of requests recorded so far
+	Entries int
+}
+
+// traceEntry is a single request-response exchange
+type traceEntry struct {
+	started     time.Time
+	duration    time.Duration
+	method      string
+	url         string
+	proto       string
+	reqHeaders  http.Header
+	reqBody     string
+	status      int
+	statusText  string
+	respProto   string
+	respHeaders http.Header
+	respBody    string
+	err         error
+}
+
+// StartTrace creates trace file and returns Tracer writing to it in a given format. If redact is true then
+// values of authentication headers and passwords in URLs are not recorded
+func StartTrace(fileName string, format string, redact bool) (*Tracer, error) {
+	switch format {
+	case TraceText, TraceCurl, TraceHAR:
+	default:
+		return nil, fmt.Errorf("Unsupported trace format '%s'", format)
+	}
+	file, err := os.Create(fileName)
+	if err != nil {
+		return nil, err
+	}
+	t := &Tracer{file: file, format: format, redact: redact, FileName: fileName}
+	if format == TraceHAR {
+		err = t.writeHARHeader()
+		if err != nil {
+			file.Close()
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Format returns format of the trace file
+func (t *Tracer) Format() string {
+	return t.format
+}
+
+// Snapshot returns current state of the tracer. It is safe to call while requests are recorded concurrently
+func (t *Tracer) Snapshot() TraceStatus {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return TraceStatus{FileName: t.FileName, Format: t.format, Entries: t.entries}
+}
+
+// Close stops recording and closes trace file
+func (t *Tracer) Close() error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.file.Close()
+}
+
+// record writes request and response to the trace file. Response is nil if request failed
+func (t *Tracer) record(req *http.Request, reqBody string, resp *http.Response, respBody []byte, started time.Time, err error) {
+	requestURL := req.URL.String()
+	if t.redact {
+		// password in user info of host URL is replaced too
+		requestURL = req.URL.Redacted()
+	}
+	entry := &traceEntry{
+		started:    started,
+		duration:   time.Since(started),
+		method:     req.Method,
+		url:        requestURL,
+		proto:      req.Proto,
+		reqHeaders: t.headers(req.Header),
+		reqBody:    reqBody,
+		err:        err,
+	}
+	if resp != nil {
+		entry.status = resp.StatusCode
+		entry.statusText = http.StatusText(resp.StatusCode)
+		entry.respProto = resp.Proto
+		entry.respHeaders = t.headers(resp.Header)
+		entry.respBody = string(respBody)
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	var werr error
+	switch t.format {
+	case TraceText:
+		_, werr = t.file.WriteString(entry.text())
+	case TraceCurl:
+		_, werr = t.file.WriteString(entry.curl())
+	case TraceHAR:
+		werr = t.writeHAREntry(entry)
+	}
+	if werr == nil {
+		t.entries++
+	}
+}
+
+// headers copies headers, redacting credentials if required
+func (t *Tracer) headers(headers http.Header) http.Header {
+	result := headers.Clone()
+	if result == nil {
+		result = http.Header{}
+	}
+	if t.redact {
+		for name := range result {
+			if redactedHeaders[http.CanonicalHeaderKey(name)] {
+				result[name] = []string{redactedValue}
+			}
+		}
+	}
+	return result
+}
+
+// sortedHeaders returns header names in alphabetical order, so that trace output is stable
+func sortedHeaders(headers http.Header) []string {
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (entry *traceEntry) text() string {
+	var buffer bytes.Buffer
+	buffer.WriteString(fmt.Sprintf("### %s %s %s (%d ms)\n", entry.started.Format(time.RFC3339Nano), entry.method, entry.url,
+		entry.duration.Milliseconds()))
+	buffer.WriteString(fmt.Sprintf("> %s %s %s\n", entry.method, entry.url, entry.proto))
+	for _, name := range sortedHeaders(entry.reqHeaders) {
+		for _, value := range entry.reqHeaders[name] {
+			buffer.WriteString(fmt.Sprintf("> %s: %s\n", name, value))
+		}
+	}
+	if entry.reqBody != "" {
+		buffer.WriteString(">\n")
+		buffer.WriteString(entry.reqBody)
+		buffer.WriteString("\n")
+	}
+	if entry.err != nil {
+		buffer.WriteString(fmt.Sprintf("! %s\n", entry.err.Error()))
+	}
+	if entry.status != 0 {
+		buffer.WriteString(fmt.Sprintf("< %s %d %s\n", entry.respProto, entry.status, entry.statusText))
+		for _, name := range sortedHeaders(entry.respHeaders) {
+			for _, value := range entry.respHeaders[name] {
+				buffer.WriteString(fmt.Sprintf("< %s: %s\n", name, value))
+			}
+		}
+		if entry.respBody != "" {
+			buffer.WriteString("<\n")
+			buffer.WriteString(entry.respBody)
+			buffer.WriteString("\n")
+		}
+	}
+	buffer.WriteString("\n")
+	return buffer.String()
+}
+
+func (entry *traceEntry) curl() string {
+	var buffer bytes.Buffer
+	outcome := fmt.Sprintf("%d %s", entry.status, entry.statusText)
+	if entry.err != nil {
+		outcome = entry.err.Error()
+	}
+	buffer.WriteString(fmt.Sprintf("# %s %s (%d ms)\n", entry.started.Format(time.RFC3339Nano), outcome, entry.duration.Milliseconds()))
+	buffer.WriteString(fmt.Sprintf("curl -X %s %s", entry.method, shellQuote(entry.url)))
+	for _, name := range sortedHeaders(entry.reqHeaders) {
+		if name == "Content-Length" {
+			continue
+		}
+		for _, value := range entry.reqHeaders[name] {
+			buffer.WriteString(" \\\n  -H " + shellQuote(name+": "+value))
+		}
+	}
+	if entry.reqBody != "" {
+		buffer.WriteString(" \\\n  --data-binary " + shellQuote(entry.reqBody))
+	}
+	buffer.WriteString("\n\n")
+	return buffer.String()
+}
+
+// shellQuote quotes string for POSIX shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+// HAR 1.2 structures, see http://www.softwareishard.com/blog/har-12-spec/
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	Cookies     []harNameValue `json:"cookies"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harContent struct {
+	Size     int    `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Headers     []harNameValue `json:"headers"`
+	Cookies     []harNameValue `json:"cookies"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int            `json:"bodySize"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+type harEntry struct {
+	StartedDateTime string                 `json:"startedDateTime"`
+	Time            float64                `json:"time"`
+	Request         harRequest             `json:"request"`
+	Response        harResponse            `json:"response"`
+	Cache           map[string]interface{} `json:"cache"`
+	Timings         harTimings             `json:"timings"`
+	Comment         string                 `json:"comment,omitempty"`
+}
+
+func harHeaders(headers http.Header) []harNameValue {
+	result := make([]harNameValue, 0, len(headers))
+	for _, name := range sortedHeaders(headers) {
+		for _, value := range headers[name] {
+			result = append(result, harNameValue{Name: name, Value: value})
+		}
+	}
+	return result
+}
+
+func (t *Tracer) writeHARHeader() error {
+	_, err := t.file.WriteString("{\"log\":{\"version\":\"1.2\",\"creator\":{\"name\":\"shelastic\",\"version\":\"\"},\"entries\":[")
+	if err != nil {
+		return err
+	}
+	return t.writeHARFooter()
+}
+
+// writeHARFooter writes end of the HAR document and moves file position back to the end of the last entry
+func (t *Tracer) writeHARFooter() error {
+	if _, err := t.file.WriteString(harFooter); err != nil {
+		return err
+	}
+	_, err := t.file.Seek(-int64(len(harFooter)), io.SeekEnd)
+	return err
+}
+
+func (t *Tracer) writeHAREntry(entry *traceEntry) error {
+	elapsed := float64(entry.duration.Microseconds()) / 1000
+	har := harEntry{
+		StartedDateTime: entry.started.Format(time.RFC3339Nano),
+		Time:            elapsed,
+		Request: harRequest{
+			Method:      entry.method,
+			URL:         entry.url,
+			HTTPVersion: entry.proto,
+			Headers:     harHeaders(entry.reqHeaders),
+			QueryString: []harNameValue{},
+			Cookies:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    len(entry.reqBody),
+		},
+		Response: harResponse{
+			Status:      entry.status,
+			StatusText:  entry.statusText,
+			HTTPVersion: entry.respProto,
+			Headers:     harHeaders(entry.respHeaders),
+			Cookies:     []harNameValue{},
+			Content: harContent{
+				Size:     len(entry.respBody),
+				MimeType: entry.respHeaders.Get("Content-Type"),
+				Text:     entry.respBody,
+			},
+			HeadersSize: -1,
+			BodySize:    len(entry.respBody),
+		},
+		Cache:   map[string]interface{}{},
+		Timings: harTimings{Send: 0, Wait: elapsed, Receive: 0},
+	}
+	if entry.reqBody != "" {
+		har.Request.PostData = &harPostData{MimeType: entry.reqHeaders.Get("Content-Type"), Text: entry.reqBody}
+	}
+	if entry.err != nil {
+		har.Comment = entry.err.Error()
+	}
+	if u, err := url.Parse(entry.url); err == nil {
+		har.Request.QueryString = harHeaders(http.Header(u.Query()))
+	}
+
+	data, err := json.Marshal(har)
+	if err != nil {
+		return err
+	}
+	if t.entries > 0 {
+		if _, err := t.file.WriteString(","); err != nil {
+			return err
+		}
+	}
+	if _, err := t.file.WriteString("\n"); err != nil {
+		return err
+	}
+	if _, err := t.file.Write(data); err != nil {
+		return err
+	}
+	return t.writeHARFooter()
+}
@@ -0,0 +1,229 @@
+package es
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"path/filepath"
+	"shelastic/test/esfake"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestSnapshotWhileRecording(t *testing.T) {
+	fake := esfake.New(esfake.ES7)
+	defer fake.Close()
+	tracer, err := StartTrace(filepath.Join(t.TempDir(), "trace.har"), TraceHAR, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tracer.Close()
+	conn, _, err := Connect(fake.URL, &ConnectOptions{Tracer: tracer})
+	if err != nil {
+		t.Fatalf("Connect failed: %s", err)
+	}
+	connected := tracer.Snapshot().Entries
+
+	const requests = 20
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := conn.Health(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	for i := 0; i < requests; i++ {
+		tracer.Snapshot()
+	}
+	wg.Wait()
+
+	status := tracer.Snapshot()
+	if status.Entries != connected+requests {
+		t.Errorf("%d requests recorded, expected %d", status.Entries, connected+requests)
+	}
+	if status.Format != TraceHAR {
+		t.Errorf("Format is %q, expected %q", status.Format, TraceHAR)
+	}
+}
+
+// tracedClient creates client sending requests to the server and recording them with a new tracer
+func tracedClient(t *testing.T, server string, format string, redact bool) (*Es, *Tracer) {
+	tracer, err := StartTrace(filepath.Join(t.TempDir(), "trace."+format), format, redact)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tracer.Close() })
+	e := testClient(t, server)
+	e.Tracer = tracer
+	return e, tracer
+}
+
+// sendWithCredentials sends request carrying credentials in all the headers which are redacted
+func sendWithCredentials(t *testing.T, e *Es, body string) {
+	req, err := e.newRequest(http.MethodPost, "/books/_search?q=title:dune", strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Basic c2VjcmV0")
+	req.Header.Set("Proxy-Authorization", "Basic cHJveHk=")
+	req.Header.Set("Cookie", "session=secret")
+	if _, _, err := e.do(req, body); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func credentialsServer(t *testing.T) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Write([]byte(`{"hits":{"total":0,"hits":[]}}`))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+type harLog struct {
+	Log struct {
+		Version string     `json:"version"`
+		Entries []harEntry `json:"entries"`
+	} `json:"log"`
+}
+
+func readHAR(t *testing.T, tracer *Tracer) harLog {
+	data, err := ioutil.ReadFile(tracer.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var har harLog
+	if err := json.Unmarshal(data, &har); err != nil {
+		t.Fatalf("Trace is not valid JSON: %s\n%s", err, data)
+	}
+	return har
+}
+
+func harHeader(headers []harNameValue, name string) string {
+	for _, header := range headers {
+		if header.Name == name {
+			return header.Value
+		}
+	}
+	return ""
+}
+
+func TestHARIsValidAfterEveryEntry(t *testing.T) {
+	server := credentialsServer(t)
+	e, tracer := tracedClient(t, server.URL, TraceHAR, true)
+	if har := readHAR(t, tracer); har.Log.Version != "1.2" || len(har.Log.Entries) != 0 {
+		t.Errorf("Unexpected empty trace %+v", har)
+	}
+	for i := 1; i <= 3; i++ {
+		sendWithCredentials(t, e, `{"query":{"match_all":{}}}`)
+		har := readHAR(t, tracer)
+		if len(har.Log.Entries) != i {
+			t.Fatalf("%d entries in trace, expected %d", len(har.Log.Entries), i)
+		}
+		entry := har.Log.Entries[i-1]
+		if entry.Request.Method != http.MethodPost || entry.Response.Status != http.StatusOK {
+			t.Errorf("Unexpected entry %s %d", entry.Request.Method, entry.Response.Status)
+		}
+		if entry.Request.PostData == nil || entry.Request.PostData.Text != `{"query":{"match_all":{}}}` {
+			t.Errorf("Request body is not recorded: %+v", entry.Request.PostData)
+		}
+		if harHeader(entry.Request.QueryString, "q") != "title:dune" {
+			t.Errorf("Query string is not recorded: %+v", entry.Request.QueryString)
+		}
+	}
+}
+
+func TestTraceRedaction(t *testing.T) {
+	server := credentialsServer(t)
+	for _, redact := range []bool{true, false} {
+		e, tracer := tracedClient(t, server.URL, TraceHAR, redact)
+		sendWithCredentials(t, e, "{}")
+		entry := readHAR(t, tracer).Log.Entries[0]
+		expected := map[string]string{
+			"Authorization":       "Basic c2VjcmV0",
+			"Proxy-Authorization": "Basic cHJveHk=",
+			"Cookie":              "session=secret",
+		}
+		if redact {
+			for name := range expected {
+				expected[name] = redactedValue
+			}
+		}
+		for name, value := range expected {
+			if actual := harHeader(entry.Request.Headers, name); actual != value {
+				t.Errorf("redact=%v: %s is %q, expected %q", redact, name, actual, value)
+			}
+		}
+		if setCookie := harHeader(entry.Response.Headers, "Set-Cookie"); redact != (setCookie == redactedValue) {
+			t.Errorf("redact=%v: Set-Cookie is %q", redact, setCookie)
+		}
+	}
+}
+
+func TestTraceRedactsURLPassword(t *testing.T) {
+	server := credentialsServer(t)
+	withPassword := strings.Replace(server.URL, "http://", "http://elastic:secret@", 1)
+	for _, redact := range []bool{true, false} {
+		e, tracer := tracedClient(t, withPassword, TraceText, redact)
+		if _, err := e.getData("/"); err != nil {
+			t.Fatal(err)
+		}
+		data, err := ioutil.ReadFile(tracer.FileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "elastic:secret@") == redact {
+			t.Errorf("redact=%v: unexpected URL in trace\n%s", redact, data)
+		}
+	}
+}
+
+func TestCurlTrace(t *testing.T) {
+	server := credentialsServer(t)
+	e, tracer := tracedClient(t, server.URL, TraceCurl, true)
+	body := `{"query":{"match":{"title":"Ender's Game"}}}`
+	sendWithCredentials(t, e, body)
+	data, err := ioutil.ReadFile(tracer.FileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trace := string(data)
+	expected := []string{
+		"curl -X POST '" + server.URL + "/books/_search?q=title:dune'",
+		"-H 'Authorization: [REDACTED]'",
+		"-H 'Content-Type: application/json'",
+		`--data-binary '{"query":{"match":{"title":"Ender'\''s Game"}}}'`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(trace, line) {
+			t.Errorf("Trace does not contain %s\n%s", line, trace)
+		}
+	}
+	if strings.Contains(trace, "Content-Length") {
+		t.Errorf("Content-Length is recorded\n%s", trace)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh is not available")
+	}
+	for _, s := range []string{"", "plain", "it's", "''", `"double" and 'single' $HOME \n`, "multi\nline"} {
+		out, err := exec.Command(sh, "-c", "printf %s "+shellQuote(s)).Output()
+		if err != nil {
+			t.Fatalf("%q: %s", s, err)
+		}
+		if string(out) != s {
+			t.Errorf("%q is quoted as %s, shell reads it as %q", s, shellQuote(s), out)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"os"
 	"runtime"
 	"shelastic/cmd"
 
@@ -20,12 +21,10 @@ func main() {
 	shell.SetPrompt("$> ")
 	shell.ShowPrompt(true)
 
-	// display welcome info.
-	shell.Println("Shelastic [Elasticsearch shell]", "v"+Version)
-
 	for _, c := range cmd.Commands {
 		shell.AddCmd(c)
 	}
+	shell.CustomCompleter(cmd.NewCompleter("help", "exit", "clear"))
 
 	settings := &cmd.Settings{}
 
@@ -35,9 +34,56 @@ func main() {
 		settings.NoColor = true
 	}
 
-	if err == nil {
-		cmd.Initialize(settings)
-		shell.Start()
+	if err != nil {
+		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	cmd.Initialize(settings)
+
+	if settings.Host != "" && settings.Profile != "" {
+		shell.Println("Only one of --host and --profile can be used")
+		os.Exit(1)
+	}
+
+	input, err := settings.BatchInput()
+	if err != nil {
+		shell.Println("Failed to read commands:", err.Error())
+		os.Exit(1)
+	}
+
+	if input == nil {
+		// display welcome info.
+		shell.Println("Shelastic [Elasticsearch shell]", "v"+Version)
+	} else {
+		// batch mode, commands are executed without starting interactive shell
+		cmd.StartBatch(input)
+	}
+
+	var connect []string
+	if settings.Host != "" {
+		connect = []string{"connect", settings.Host}
+	} else if settings.Profile != "" {
+		connect = []string{"connect", "--profile", settings.Profile}
+	}
+	if connect != nil {
+		shell.Process(connect...)
+		if input != nil && !cmd.Connected() {
+			input.Close()
+			os.Exit(1)
+		}
+	}
+
+	if input != nil {
+		succeeded := cmd.RunBatch(shell)
+		input.Close()
+		if !succeeded {
+			os.Exit(1)
+		}
+		return
 	}
 
+	shell.Start()
 }
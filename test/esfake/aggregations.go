@@ -0,0 +1,136 @@
+package esfake
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// aggregate computes aggregations of the search over matched documents. Only top-level source fields are
+// supported by terms, histogram, percentiles and simple metric aggregations, others produce empty results
+func aggregate(docs []*Document, aggs map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(aggs))
+	for name, value := range aggs {
+		definition, _ := value.(map[string]interface{})
+		sub, _ := definition["aggs"].(map[string]interface{})
+		for aggType, params := range definition {
+			if aggType == "aggs" {
+				continue
+			}
+			p, _ := params.(map[string]interface{})
+			field, _ := p["field"].(string)
+			result[name] = aggregation(docs, aggType, field, p, sub)
+		}
+	}
+	return result
+}
+
+func aggregation(docs []*Document, aggType string, field string, params map[string]interface{}, sub map[string]interface{}) map[string]interface{} {
+	numbers := numericValues(docs, field)
+	switch aggType {
+	case "terms":
+		groups := make(map[string][]*Document)
+		for _, doc := range docs {
+			if value, ok := doc.Source[field]; ok {
+				key := fmt.Sprintf("%v", value)
+				groups[key] = append(groups[key], doc)
+			}
+		}
+		keys := make([]string, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(groups[keys[i]]) != len(groups[keys[j]]) {
+				return len(groups[keys[i]]) > len(groups[keys[j]])
+			}
+			return keys[i] < keys[j]
+		})
+		buckets := make([]interface{}, len(keys))
+		for i, key := range keys {
+			buckets[i] = bucket(key, groups[key], sub)
+		}
+		return map[string]interface{}{"doc_count_error_upper_bound": 0, "sum_other_doc_count": 0, "buckets": buckets}
+	case "histogram":
+		interval, _ := params["interval"].(float64)
+		if interval <= 0 {
+			interval = 1
+		}
+		groups := make(map[float64][]*Document)
+		for _, doc := range docs {
+			if value, ok := doc.Source[field].(float64); ok {
+				key := math.Floor(value/interval) * interval
+				groups[key] = append(groups[key], doc)
+			}
+		}
+		var buckets []interface{}
+		if len(groups) > 0 {
+			min, max := math.Inf(1), math.Inf(-1)
+			for key := range groups {
+				min, max = math.Min(min, key), math.Max(max, key)
+			}
+			// empty buckets between the first and the last are returned, as min_doc_count is 0 by default
+			for key := min; key <= max; key += interval {
+				buckets = append(buckets, bucket(key, groups[key], sub))
+			}
+		}
+		return map[string]interface{}{"buckets": buckets}
+	case "value_count":
+		return map[string]interface{}{"value": len(numbers)}
+	case "cardinality":
+		distinct := make(map[string]bool)
+		for _, doc := range docs {
+			if value, ok := doc.Source[field]; ok {
+				distinct[fmt.Sprintf("%v", value)] = true
+			}
+		}
+		return map[string]interface{}{"value": len(distinct)}
+	case "avg", "min", "max", "sum":
+		return map[string]interface{}{"value": stats(numbers)[aggType]}
+	case "stats":
+		return stats(numbers)
+	case "percentiles":
+		values := make(map[string]interface{})
+		sort.Float64s(numbers)
+		for _, percent := range []float64{1, 5, 25, 50, 75, 95, 99} {
+			var value interface{}
+			if len(numbers) > 0 {
+				value = numbers[int(percent/100*float64(len(numbers)-1))]
+			}
+			values[fmt.Sprintf("%.1f", percent)] = value
+		}
+		return map[string]interface{}{"values": values}
+	}
+	return map[string]interface{}{}
+}
+
+func bucket(key interface{}, docs []*Document, sub map[string]interface{}) map[string]interface{} {
+	result := aggregate(docs, sub)
+	result["key"] = key
+	result["doc_count"] = len(docs)
+	return result
+}
+
+// stats returns count, min, max, avg and sum of values, metrics other than count are null without values
+func stats(values []float64) map[string]interface{} {
+	result := map[string]interface{}{"count": len(values), "min": nil, "max": nil, "avg": nil, "sum": 0.0}
+	if len(values) == 0 {
+		return result
+	}
+	min, max, sum := math.Inf(1), math.Inf(-1), 0.0
+	for _, v := range values {
+		min, max, sum = math.Min(min, v), math.Max(max, v), sum+v
+	}
+	result["min"], result["max"], result["sum"], result["avg"] = min, max, sum, sum/float64(len(values))
+	return result
+}
+
+func numericValues(docs []*Document, field string) []float64 {
+	var result []float64
+	for _, doc := range docs {
+		if value, ok := doc.Source[field].(float64); ok {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,936 @@
+package esfake
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const nodeID = "fake-node-id"
+
+func (s *Server) root(w http.ResponseWriter, r *request) {
+	version := map[string]interface{}{
+		"number": s.Personality.Version,
+	}
+	if s.Personality.Distribution != "" {
+		version["distribution"] = s.Personality.Distribution
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"name":         "fake-node",
+		"cluster_name": s.ClusterName,
+		"version":      version,
+		"tagline":      "You Know, for Search",
+	})
+}
+
+func (s *Server) cluster(w http.ResponseWriter, r *request) {
+	if len(r.path) < 2 {
+		s.badRequest(w, r)
+		return
+	}
+	switch r.path[1] {
+	case "health":
+		health := map[string]interface{}{
+			"cluster_name":          s.ClusterName,
+			"status":                "green",
+			"number_of_nodes":       1,
+			"number_of_data_nodes":  1,
+			"active_primary_shards": len(s.indices),
+			"active_shards":         len(s.indices),
+		}
+		if r.URL.Query().Get("level") == "indices" {
+			indices := make(map[string]interface{})
+			for _, name := range s.indexNames() {
+				if !s.indices[name].Closed {
+					indices[name] = map[string]interface{}{"status": "green", "number_of_shards": 1, "number_of_replicas": 0}
+				}
+			}
+			health["indices"] = indices
+		}
+		writeJSON(w, http.StatusOK, health)
+	case "state":
+		indices := make(map[string]interface{})
+		for _, name := range s.indexNames() {
+			idx := s.indices[name]
+			state := "open"
+			if idx.Closed {
+				state = "close"
+			}
+			indices[name] = map[string]interface{}{
+				"state":    state,
+				"settings": map[string]interface{}{"index": idx.Settings},
+				"aliases":  idx.aliasNames(),
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"cluster_name": s.ClusterName,
+			"metadata":     map[string]interface{}{"indices": indices},
+		})
+	case "settings":
+		if r.Method == http.MethodGet {
+			writeJSON(w, http.StatusOK, map[string]interface{}{"persistent": map[string]interface{}{}, "transient": map[string]interface{}{}})
+		} else {
+			s.acknowledge(w)
+		}
+	default:
+		s.badRequest(w, r)
+	}
+}
+
+// address formats node address as reported by the emulated version. ES 1.x uses "inet[/ip:port]" form
+func (s *Server) address(hostPort string) string {
+	if s.esMajor == 1 {
+		return fmt.Sprintf("inet[/%s]", hostPort)
+	}
+	return hostPort
+}
+
+func (s *Server) nodes(w http.ResponseWriter, r *request) {
+	httpAddress := strings.TrimPrefix(s.URL, "http://")
+	node := map[string]interface{}{
+		"name":              "fake-node",
+		"transport_address": s.address("127.0.0.1:9300"),
+		"host":              "127.0.0.1",
+		"ip":                "127.0.0.1",
+		"version":           s.Personality.Version,
+	}
+	if len(r.path) > 1 && (r.path[1] == "stats" || (len(r.path) > 2 && r.path[2] == "stats")) {
+		docs := 0
+		for _, idx := range s.indices {
+			docs += len(idx.Documents)
+		}
+		node["indices"] = map[string]interface{}{"docs": map[string]interface{}{"count": docs, "deleted": 0}}
+		node["jvm"] = map[string]interface{}{
+			"uptime_in_millis": 1000,
+			"mem":              map[string]interface{}{"heap_used_in_bytes": 1024, "heap_max_in_bytes": 4096},
+			"threads":          map[string]interface{}{"count": 10, "peak_count": 12},
+		}
+		node["fs"] = map[string]interface{}{"total": map[string]interface{}{"total_in_bytes": 8192, "free_in_bytes": 4096, "available_in_bytes": 4096}}
+	} else {
+		if s.esMajor == 1 {
+			node["http_address"] = s.address(httpAddress)
+		} else {
+			node["http"] = map[string]interface{}{"publish_address": httpAddress}
+		}
+		node["os"] = map[string]interface{}{"name": "Linux", "arch": "amd64", "version": "5.0", "allocated_processors": 1}
+		node["jvm"] = map[string]interface{}{"version": "11", "vm_name": "Fake VM", "vm_version": "11", "vm_vendor": "shelastic"}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"cluster_name": s.ClusterName,
+		"nodes":        map[string]interface{}{nodeID: node},
+	})
+}
+
+// stats handles GET /_stats. As in real clusters, closed indices are not included
+func (s *Server) stats(w http.ResponseWriter, r *request) {
+	indices := make(map[string]interface{})
+	for _, name := range s.indexNames() {
+		idx := s.indices[name]
+		if idx.Closed {
+			continue
+		}
+		stats := map[string]interface{}{
+			"docs":  map[string]interface{}{"count": len(idx.Documents), "deleted": 0},
+			"store": map[string]interface{}{"size_in_bytes": idx.size()},
+		}
+		indices[name] = map[string]interface{}{"primaries": stats, "total": stats}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"indices": indices})
+}
+
+// cat handles GET /_cat/indices. JSON output is supported since 5.0, earlier versions return text table
+func (s *Server) cat(w http.ResponseWriter, r *request) {
+	if len(r.path) < 2 || r.path[1] != "indices" {
+		s.badRequest(w, r)
+		return
+	}
+	var rows []map[string]interface{}
+	for _, name := range s.indexNames() {
+		idx := s.indices[name]
+		row := map[string]interface{}{
+			"health":         "green",
+			"status":         "open",
+			"index":          name,
+			"uuid":           idx.Settings["uuid"],
+			"pri":            idx.Settings["number_of_shards"],
+			"rep":            idx.Settings["number_of_replicas"],
+			"docs.count":     strconv.Itoa(len(idx.Documents)),
+			"docs.deleted":   "0",
+			"store.size":     strconv.Itoa(idx.size()),
+			"pri.store.size": strconv.Itoa(idx.size()),
+			"creation.date":  idx.Settings["creation_date"],
+		}
+		if idx.Closed {
+			row["status"] = "close"
+			for _, column := range []string{"docs.count", "docs.deleted", "store.size", "pri.store.size"} {
+				row[column] = nil
+			}
+		}
+		rows = append(rows, row)
+	}
+	if r.URL.Query().Get("format") == "json" && s.esMajor >= 5 {
+		if rows == nil {
+			rows = []map[string]interface{}{}
+		}
+		writeJSON(w, http.StatusOK, rows)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=UTF-8")
+	for _, row := range rows {
+		fmt.Fprintf(w, "%s %s %s %v %v %v %v\n", row["health"], row["status"], row["index"], row["pri"], row["rep"], row["docs.count"], row["store.size"])
+	}
+}
+
+// aliases handles GET /_alias, GET /{index}/_alias/* and PUT/DELETE /{index}/_alias/{alias}
+func (s *Server) aliases(w http.ResponseWriter, r *request, index string) {
+	pos := 1
+	if index != "" {
+		pos = 2
+	}
+	if r.Method == http.MethodPut || r.Method == http.MethodDelete {
+		idx := s.resolve(index)
+		if idx == nil || len(r.path) <= pos {
+			s.indexNotFound(w, index)
+			return
+		}
+		if r.Method == http.MethodPut {
+			idx.Aliases[r.path[pos]] = map[string]interface{}{}
+		} else {
+			delete(idx.Aliases, r.path[pos])
+		}
+		s.acknowledge(w)
+		return
+	}
+	result := make(map[string]interface{})
+	for _, idx := range s.selectIndices(index) {
+		aliases := make(map[string]interface{})
+		for alias, filter := range idx.Aliases {
+			aliases[alias] = filter
+		}
+		result[idx.Name] = map[string]interface{}{"aliases": aliases}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// mapping handles GET /{index}/_mapping[/{type}]. Mappings of typeless versions do not contain document type
+func (s *Server) mapping(w http.ResponseWriter, r *request, index string) {
+	result := make(map[string]interface{})
+	for _, idx := range s.selectIndices(index) {
+		result[idx.Name] = map[string]interface{}{"mappings": s.indexMappings(idx)}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) indexMappings(idx *Index) map[string]interface{} {
+	if s.Typeless() {
+		return map[string]interface{}{"properties": idx.Properties}
+	}
+	types := make(map[string]interface{})
+	for _, doc := range idx.Documents {
+		types[doc.Type] = map[string]interface{}{"properties": idx.Properties}
+	}
+	if len(types) == 0 {
+		types["doc"] = map[string]interface{}{"properties": idx.Properties}
+	}
+	return types
+}
+
+// settings handles GET and PUT /{index}/_settings
+func (s *Server) settings(w http.ResponseWriter, r *request, index string) {
+	if r.Method == http.MethodPut {
+		body, err := r.json()
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+			return
+		}
+		if nested, ok := body["index"].(map[string]interface{}); ok {
+			body = nested
+		}
+		for _, idx := range s.selectIndices(index) {
+			for k, v := range body {
+				idx.Settings[strings.TrimPrefix(k, "index.")] = fmt.Sprintf("%v", v)
+			}
+		}
+		s.acknowledge(w)
+		return
+	}
+	result := make(map[string]interface{})
+	for _, idx := range s.selectIndices(index) {
+		result[idx.Name] = map[string]interface{}{"settings": map[string]interface{}{"index": idx.Settings}}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// index handles APIs with index name as first part of the path
+func (s *Server) index(w http.ResponseWriter, r *request) {
+	name := r.path[0]
+	if len(r.path) == 1 {
+		s.indexOperation(w, r, name)
+		return
+	}
+	idx := s.resolve(name)
+	api := r.path[1]
+	if idx == nil && !strings.ContainsAny(name, ",*") && name != "_all" {
+		isWrite := r.Method == http.MethodPut || r.Method == http.MethodPost
+		if isWrite && (api == "_doc" || api == "_create" || !strings.HasPrefix(api, "_")) {
+			// documents can be indexed into non-existing index, it is created automatically
+			idx = s.createIndex(name, nil)
+		} else {
+			s.indexNotFound(w, name)
+			return
+		}
+	}
+	// searches of typed clusters have /{index}/{type}/_search path
+	isSearch := api == "_search" || (len(r.path) == 3 && r.path[2] == "_search")
+	if idx == nil && api != "_mapping" && api != "_mappings" && api != "_settings" && api != "_alias" && api != "_aliases" && !isSearch {
+		s.indexNotFound(w, name)
+		return
+	}
+	switch api {
+	case "_mapping", "_mappings":
+		s.mapping(w, r, name)
+	case "_settings":
+		s.settings(w, r, name)
+	case "_alias", "_aliases":
+		s.aliases(w, r, name)
+	case "_search":
+		s.search(w, r, name)
+	case "_pit":
+		s.openPit(w, r, name)
+	case "_segments":
+		s.segments(w, []*Index{idx})
+	case "_open", "_close":
+		idx.Closed = api == "_close"
+		s.acknowledge(w)
+	case "_refresh", "_flush", "_cache", "_forcemerge", "_optimize":
+		s.acknowledge(w)
+	case "_ilm":
+		s.ilm(w, r, idx)
+	case "_doc", "_create", "_update":
+		if !s.Typeless() && api != "_doc" {
+			s.badRequest(w, r)
+			return
+		}
+		if len(r.path) > 2 {
+			s.document(w, r, idx, "_doc", r.path[2], api)
+		} else {
+			s.document(w, r, idx, "_doc", "", api)
+		}
+	default:
+		s.typedDocument(w, r, idx, api)
+	}
+}
+
+// typedDocument handles /{index}/{type}[/{id}[/_create|_update]] paths of versions before 8.0
+func (s *Server) typedDocument(w http.ResponseWriter, r *request, idx *Index, docType string) {
+	if s.esMajor >= 8 || strings.HasPrefix(docType, "_") {
+		s.badRequest(w, r)
+		return
+	}
+	switch len(r.path) {
+	case 2:
+		s.document(w, r, idx, docType, "", "_doc")
+	case 3:
+		if r.path[2] == "_search" {
+			// index is nil for searches across all indices, e.g. /_all/{type}/_search
+			s.search(w, r, r.path[0])
+		} else {
+			s.document(w, r, idx, docType, r.path[2], "_doc")
+		}
+	case 4:
+		s.document(w, r, idx, docType, r.path[2], r.path[3])
+	default:
+		s.badRequest(w, r)
+	}
+}
+
+// indexOperation handles GET, HEAD, PUT, POST and DELETE /{index}
+func (s *Server) indexOperation(w http.ResponseWriter, r *request, name string) {
+	idx := s.resolve(name)
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		if idx != nil {
+			s.writeError(w, http.StatusBadRequest, "resource_already_exists_exception", fmt.Sprintf("index [%s] already exists", name), name)
+			return
+		}
+		body, err := r.json()
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+			return
+		}
+		idx = s.createIndex(name, nil)
+		if mappings, ok := body["mappings"].(map[string]interface{}); ok {
+			if props, ok := mappings["properties"].(map[string]interface{}); ok {
+				idx.Properties = props
+			} else {
+				for _, m := range mappings {
+					if typed, ok := m.(map[string]interface{}); ok {
+						if props, ok := typed["properties"].(map[string]interface{}); ok {
+							idx.Properties = props
+						}
+					}
+				}
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"acknowledged": true, "index": name})
+	case http.MethodDelete:
+		if idx == nil {
+			s.indexNotFound(w, name)
+			return
+		}
+		delete(s.indices, idx.Name)
+		s.acknowledge(w)
+	default:
+		if idx == nil {
+			s.indexNotFound(w, name)
+			return
+		}
+		aliases := make(map[string]interface{})
+		for alias, filter := range idx.Aliases {
+			aliases[alias] = filter
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			idx.Name: map[string]interface{}{
+				"aliases":  aliases,
+				"mappings": s.indexMappings(idx),
+				"settings": map[string]interface{}{"index": idx.Settings},
+			},
+		})
+	}
+}
+
+// documentResult creates response to document API in format of the emulated version
+func (s *Server) documentResult(idx *Index, docType string, id string, result string) map[string]interface{} {
+	body := map[string]interface{}{
+		"_index":   idx.Name,
+		"_id":      id,
+		"_version": 1,
+	}
+	if s.esMajor < 8 {
+		body["_type"] = docType
+	}
+	if s.esMajor >= 5 {
+		body["result"] = result
+	} else {
+		switch result {
+		case "created":
+			body["created"] = true
+		case "updated":
+			body["created"] = false
+		case "deleted":
+			body["found"] = true
+		case "not_found":
+			body["found"] = false
+		}
+	}
+	return body
+}
+
+// document handles get, index, create, update and delete document APIs
+func (s *Server) document(w http.ResponseWriter, r *request, idx *Index, docType string, id string, api string) {
+	if s.Typeless() {
+		docType = "_doc"
+	}
+	switch {
+	case r.Method == http.MethodGet || r.Method == http.MethodHead:
+		doc := idx.find(id)
+		if doc == nil {
+			body := map[string]interface{}{"_index": idx.Name, "_id": id, "found": false}
+			writeJSON(w, http.StatusNotFound, body)
+			return
+		}
+		body := s.documentResult(idx, doc.Type, id, "")
+		delete(body, "result")
+		body["found"] = true
+		body["_source"] = doc.Source
+		writeJSON(w, http.StatusOK, body)
+	case r.Method == http.MethodDelete:
+		if !idx.remove(id) {
+			writeJSON(w, http.StatusNotFound, s.documentResult(idx, docType, id, "not_found"))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.documentResult(idx, docType, id, "deleted"))
+	case api == "_update":
+		doc := idx.find(id)
+		if doc == nil {
+			s.writeError(w, http.StatusNotFound, "document_missing_exception", fmt.Sprintf("[%s][%s]: document missing", docType, id), idx.Name)
+			return
+		}
+		body, err := r.json()
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+			return
+		}
+		partial, _ := body["doc"].(map[string]interface{})
+		for k, v := range partial {
+			doc.Source[k] = v
+		}
+		writeJSON(w, http.StatusOK, s.documentResult(idx, doc.Type, id, "updated"))
+	default:
+		if id == "" && r.Method != http.MethodPost {
+			s.badRequest(w, r)
+			return
+		}
+		if api == "_create" && idx.find(id) != nil {
+			s.writeError(w, http.StatusConflict, "version_conflict_engine_exception",
+				fmt.Sprintf("[%s]: version conflict, document already exists", id), idx.Name)
+			return
+		}
+		source, err := r.json()
+		if err != nil {
+			s.writeError(w, http.StatusBadRequest, "mapper_parsing_exception", "failed to parse", idx.Name)
+			return
+		}
+		id, created := s.storeDocument(idx, docType, id, source)
+		status := http.StatusOK
+		result := "updated"
+		if created {
+			status = http.StatusCreated
+			result = "created"
+		}
+		writeJSON(w, status, s.documentResult(idx, docType, id, result))
+	}
+}
+
+// search handles search requests. Query DSL is not evaluated, all documents match it. URL search supports
+// "field:value" and "value" queries which match documents with a field equal to the value.
+// Sorted searches return position of the hit as the last sort value, search_after continues after that position
+func (s *Server) search(w http.ResponseWriter, r *request, index string) {
+	if len(r.path) > 0 && r.path[len(r.path)-1] == "scroll" {
+		s.scroll(w, r)
+		return
+	}
+	body, err := r.json()
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+		return
+	}
+	size := 10
+	if v, ok := body["size"].(float64); ok {
+		size = int(v)
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("size")); err == nil {
+		size = v
+	}
+	from := 0
+	if v, ok := body["from"].(float64); ok {
+		from = int(v)
+	}
+	if v, err := strconv.Atoi(r.URL.Query().Get("from")); err == nil {
+		from = v
+	}
+	if after, ok := body["search_after"].([]interface{}); ok && len(after) > 0 {
+		if position, ok := after[len(after)-1].(float64); ok {
+			from = int(position) + 1
+		}
+	}
+	pitID := ""
+	if pit, ok := body["pit"].(map[string]interface{}); ok {
+		pitID, _ = pit["id"].(string)
+		pitIndex, ok := s.pits[pitID]
+		if !ok {
+			s.writeError(w, http.StatusNotFound, "search_context_missing_exception", fmt.Sprintf("No search context found for id [%s]", pitID), "")
+			return
+		}
+		index = pitIndex
+	}
+	_, sorted := body["sort"]
+	query := r.URL.Query().Get("q")
+
+	var hits []map[string]interface{}
+	var matched []*Document
+	for _, idx := range s.selectIndices(index) {
+		for _, doc := range idx.Documents {
+			if query != "" && !matches(doc, query) {
+				continue
+			}
+			matched = append(matched, doc)
+			hit := map[string]interface{}{
+				"_index":  idx.Name,
+				"_id":     doc.ID,
+				"_score":  1.0,
+				"_source": filterSource(doc.Source, body["_source"]),
+			}
+			if highlight := highlightFields(doc, body["highlight"], query); len(highlight) > 0 {
+				hit["highlight"] = highlight
+			}
+			if s.esMajor < 8 {
+				hit["_type"] = doc.Type
+			}
+			if sorted {
+				hit["sort"] = []interface{}{1.0, len(hits)}
+			}
+			hits = append(hits, hit)
+		}
+	}
+
+	if scrollParam := r.URL.Query().Get("scroll"); scrollParam != "" {
+		s.nextID++
+		scrollID := fmt.Sprintf("fake-scroll-%d", s.nextID)
+		sc := &scroll{hits: hits, size: size}
+		s.scrolls[scrollID] = sc
+		if s.esMajor == 1 {
+			// 1.x returns no hits in the first response of scroll search
+			writeJSON(w, http.StatusOK, s.searchResult(scrollID, len(hits), nil))
+			return
+		}
+		writeJSON(w, http.StatusOK, s.searchResult(scrollID, len(hits), sc.next()))
+		return
+	}
+
+	page := hits[:0]
+	if from < len(hits) {
+		page = hits[from:]
+	}
+	if len(page) > size {
+		page = page[:size]
+	}
+	result := s.searchResult("", len(hits), page)
+	if pitID != "" {
+		result["pit_id"] = pitID
+	}
+	if aggs, ok := body["aggs"].(map[string]interface{}); ok {
+		result["aggregations"] = aggregate(matched, aggs)
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// openPit handles POST /{index}/_pit
+func (s *Server) openPit(w http.ResponseWriter, r *request, index string) {
+	if r.Method != http.MethodPost {
+		s.badRequest(w, r)
+		return
+	}
+	s.nextID++
+	id := fmt.Sprintf("fake-pit-%d", s.nextID)
+	s.pits[id] = index
+	writeJSON(w, http.StatusOK, map[string]interface{}{"id": id})
+}
+
+// closePit handles DELETE /_pit with point in time id in body
+func (s *Server) closePit(w http.ResponseWriter, r *request) {
+	body, err := r.json()
+	id, _ := body["id"].(string)
+	if err != nil || r.Method != http.MethodDelete {
+		s.badRequest(w, r)
+		return
+	}
+	freed := 0
+	if _, ok := s.pits[id]; ok {
+		delete(s.pits, id)
+		freed = 1
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"succeeded": true, "num_freed": freed})
+}
+
+// filterSource returns fields of the source listed in _source parameter of search. Only top-level fields are filtered
+func filterSource(source map[string]interface{}, filter interface{}) map[string]interface{} {
+	fields, ok := filter.([]interface{})
+	if !ok {
+		return source
+	}
+	result := make(map[string]interface{})
+	for _, f := range fields {
+		name, _ := f.(string)
+		if value, ok := source[strings.SplitN(name, ".", 2)[0]]; ok {
+			result[strings.SplitN(name, ".", 2)[0]] = value
+		}
+	}
+	return result
+}
+
+// highlightFields wraps value of URL search query in requested string fields with <em> tags
+func highlightFields(doc *Document, highlight interface{}, query string) map[string]interface{} {
+	params, _ := highlight.(map[string]interface{})
+	fields, _ := params["fields"].(map[string]interface{})
+	if query == "" || len(fields) == 0 {
+		return nil
+	}
+	value := query
+	if parts := strings.SplitN(query, ":", 2); len(parts) == 2 {
+		value = parts[1]
+	}
+	result := make(map[string]interface{})
+	for name := range fields {
+		if text, ok := doc.Source[name].(string); ok && strings.Contains(text, value) {
+			result[name] = []interface{}{strings.Replace(text, value, "<em>"+value+"</em>", -1)}
+		}
+	}
+	return result
+}
+
+func matches(doc *Document, query string) bool {
+	field := ""
+	value := query
+	if parts := strings.SplitN(query, ":", 2); len(parts) == 2 {
+		field, value = parts[0], parts[1]
+	}
+	for k, v := range doc.Source {
+		if (field == "" || field == k) && fmt.Sprintf("%v", v) == value {
+			return true
+		}
+	}
+	return false
+}
+
+// next returns next page of scroll hits
+func (sc *scroll) next() []map[string]interface{} {
+	end := sc.position + sc.size
+	if end > len(sc.hits) {
+		end = len(sc.hits)
+	}
+	page := sc.hits[sc.position:end]
+	sc.position = end
+	return page
+}
+
+// searchResult creates search response. hits.total is an object since 7.0
+func (s *Server) searchResult(scrollID string, total int, hits []map[string]interface{}) map[string]interface{} {
+	if hits == nil {
+		hits = []map[string]interface{}{}
+	}
+	var totalValue interface{} = total
+	if s.Typeless() {
+		totalValue = map[string]interface{}{"value": total, "relation": "eq"}
+	}
+	result := map[string]interface{}{
+		"took":      1,
+		"timed_out": false,
+		"hits": map[string]interface{}{
+			"total":     totalValue,
+			"max_score": 1.0,
+			"hits":      hits,
+		},
+	}
+	if scrollID != "" {
+		result["_scroll_id"] = scrollID
+	}
+	return result
+}
+
+// scroll handles GET/POST /_search/scroll with scroll id in URL or body, and DELETE /_search/scroll
+func (s *Server) scroll(w http.ResponseWriter, r *request) {
+	scrollID := r.URL.Query().Get("scroll_id")
+	if scrollID == "" {
+		body, err := r.json()
+		if err == nil {
+			scrollID, _ = body["scroll_id"].(string)
+		} else {
+			scrollID = strings.TrimSpace(string(r.body))
+		}
+	}
+	sc, ok := s.scrolls[scrollID]
+	if !ok {
+		s.writeError(w, http.StatusNotFound, "search_context_missing_exception", fmt.Sprintf("No search context found for id [%s]", scrollID), "")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		delete(s.scrolls, scrollID)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"succeeded": true, "num_freed": 1})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.searchResult(scrollID, len(sc.hits), sc.next()))
+}
+
+// bulk handles POST /_bulk. Document type is required before 7.0 and rejected since 8.0
+func (s *Server) bulk(w http.ResponseWriter, r *request) {
+	scanner := bufio.NewScanner(bytes.NewReader(r.body))
+	scanner.Buffer(make([]byte, 64*1024), len(r.body)+1)
+	var items []interface{}
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var action map[string]map[string]interface{}
+		if err := json.Unmarshal([]byte(text), &action); err != nil || len(action) != 1 {
+			s.writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("Malformed action/metadata line [%d]", line), "")
+			return
+		}
+		for op, meta := range action {
+			index, _ := meta["_index"].(string)
+			docType, _ := meta["_type"].(string)
+			id, _ := meta["_id"].(string)
+			if docType != "" && s.esMajor >= 8 {
+				s.writeError(w, http.StatusBadRequest, "illegal_argument_exception",
+					fmt.Sprintf("Action/metadata line [%d] contains an unknown parameter [_type]", line), "")
+				return
+			}
+			if docType == "" && !s.Typeless() {
+				s.writeError(w, http.StatusBadRequest, "action_request_validation_exception", "Validation Failed: 1: type is missing;", "")
+				return
+			}
+			idx := s.resolve(index)
+			if idx == nil {
+				idx = s.createIndex(index, nil)
+			}
+			item := map[string]interface{}{"_index": idx.Name, "_id": id}
+			status := http.StatusOK
+			switch op {
+			case "delete":
+				if !idx.remove(id) {
+					status = http.StatusNotFound
+				}
+			case "index", "create", "update":
+				if !scanner.Scan() {
+					s.writeError(w, http.StatusBadRequest, "illegal_argument_exception", "The bulk request must be terminated by a newline [\\n]", "")
+					return
+				}
+				line++
+				var source map[string]interface{}
+				if err := json.Unmarshal(scanner.Bytes(), &source); err != nil {
+					s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+					return
+				}
+				if op == "update" {
+					source, _ = source["doc"].(map[string]interface{})
+				}
+				var created bool
+				item["_id"], created = s.storeDocument(idx, docType, id, source)
+				if created {
+					status = http.StatusCreated
+				}
+			default:
+				s.writeError(w, http.StatusBadRequest, "illegal_argument_exception", fmt.Sprintf("Unknown action [%s]", op), "")
+				return
+			}
+			item["status"] = status
+			items = append(items, map[string]interface{}{op: item})
+		}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"took": 1, "errors": false, "items": items})
+}
+
+// segments handles GET /{index}/_segments
+// segments handles GET /{index}/_segments and GET /_segments. Closed indices are not included
+func (s *Server) segments(w http.ResponseWriter, indices []*Index) {
+	result := make(map[string]interface{})
+	for _, idx := range indices {
+		if idx.Closed {
+			continue
+		}
+		shard := map[string]interface{}{
+			"routing":                map[string]interface{}{"state": "STARTED", "primary": true, "node": nodeID},
+			"num_committed_segments": 1,
+			"num_search_segments":    1,
+			"segments": map[string]interface{}{
+				"_0": map[string]interface{}{"num_docs": len(idx.Documents), "deleted_docs": 0, "committed": true, "search": true},
+			},
+		}
+		result[idx.Name] = map[string]interface{}{"shards": map[string]interface{}{"0": []interface{}{shard}}}
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"indices": result})
+}
+
+// ilm handles GET /{index}/_ilm/explain of Elasticsearch 6.6+
+func (s *Server) ilm(w http.ResponseWriter, r *request, idx *Index) {
+	if s.Personality.Distribution != "" || s.esMajor < 6 {
+		s.badRequest(w, r)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"indices": map[string]interface{}{idx.Name: map[string]interface{}{"index": idx.Name, "managed": false}},
+	})
+}
+
+// ism handles GET /_plugins/_ism/explain/{index} of OpenSearch
+func (s *Server) ism(w http.ResponseWriter, r *request) {
+	if s.Personality.Distribution == "" || len(r.path) < 4 || r.path[1] != "_ism" || r.path[2] != "explain" {
+		s.badRequest(w, r)
+		return
+	}
+	result := map[string]interface{}{"total_managed_indices": 0}
+	for _, idx := range s.selectIndices(r.path[3]) {
+		result[idx.Name] = map[string]interface{}{"index.plugins.index_state_management.policy_id": nil}
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// snapshot handles repository and snapshot APIs
+func (s *Server) snapshot(w http.ResponseWriter, r *request) {
+	if len(r.path) == 1 {
+		writeJSON(w, http.StatusOK, s.repositories)
+		return
+	}
+	repo := r.path[1]
+	if len(r.path) == 2 {
+		switch r.Method {
+		case http.MethodPut, http.MethodPost:
+			body, err := r.json()
+			if err != nil {
+				s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+				return
+			}
+			s.repositories[repo] = body
+			s.acknowledge(w)
+		case http.MethodDelete:
+			delete(s.repositories, repo)
+			s.acknowledge(w)
+		default:
+			if settings, ok := s.repositories[repo]; ok {
+				writeJSON(w, http.StatusOK, map[string]interface{}{repo: settings})
+			} else {
+				s.repositoryMissing(w, repo)
+			}
+		}
+		return
+	}
+	if _, ok := s.repositories[repo]; !ok {
+		s.repositoryMissing(w, repo)
+		return
+	}
+	name := r.path[2]
+	switch {
+	case name == "_verify":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"nodes": map[string]interface{}{nodeID: map[string]interface{}{"name": "fake-node"}}})
+	case len(r.path) > 3 && r.path[3] == "_restore":
+		writeJSON(w, http.StatusOK, map[string]interface{}{"accepted": true})
+	case r.Method == http.MethodPut || r.Method == http.MethodPost:
+		snapshot := map[string]interface{}{
+			"snapshot": name,
+			"indices":  s.indexNames(),
+			"state":    "SUCCESS",
+		}
+		s.snapshots[repo] = append(s.snapshots[repo], snapshot)
+		writeJSON(w, http.StatusOK, map[string]interface{}{"snapshot": snapshot})
+	case r.Method == http.MethodDelete:
+		snapshots := s.snapshots[repo]
+		for i, snapshot := range snapshots {
+			if snapshot["snapshot"] == name {
+				s.snapshots[repo] = append(snapshots[:i], snapshots[i+1:]...)
+				s.acknowledge(w)
+				return
+			}
+		}
+		s.snapshotMissing(w, repo, name)
+	default:
+		var result []interface{}
+		for _, snapshot := range s.snapshots[repo] {
+			if name == "_all" || snapshot["snapshot"] == name {
+				result = append(result, snapshot)
+			}
+		}
+		if len(result) == 0 && name != "_all" {
+			s.snapshotMissing(w, repo, name)
+			return
+		}
+		if result == nil {
+			result = []interface{}{}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"snapshots": result})
+	}
+}
+
+func (s *Server) repositoryMissing(w http.ResponseWriter, repo string) {
+	s.writeError(w, http.StatusNotFound, "repository_missing_exception", fmt.Sprintf("[%s] missing", repo), "")
+}
+
+func (s *Server) snapshotMissing(w http.ResponseWriter, repo string, name string) {
+	s.writeError(w, http.StatusNotFound, "snapshot_missing_exception", fmt.Sprintf("[%s:%s] is missing", repo, name), "")
+}
@@ -0,0 +1,404 @@
+// Package esfake provides in-process HTTP server emulating subset of Elasticsearch APIs used by shelastic.
+// Server keeps indices and documents in memory and mimics response formats of different Elasticsearch
+// and OpenSearch versions, so that shelastic can be exercised without a real cluster
+package esfake
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Personality describes version of the emulated cluster
+type Personality struct {
+	Version string
+	// Distribution is "opensearch" for OpenSearch clusters and empty for Elasticsearch
+	Distribution string
+}
+
+// Personalities of the most common cluster versions
+var (
+	ES1        = Personality{Version: "1.7.6"}
+	ES2        = Personality{Version: "2.4.6"}
+	ES5        = Personality{Version: "5.6.16"}
+	ES6        = Personality{Version: "6.8.23"}
+	ES7        = Personality{Version: "7.17.9"}
+	ES8        = Personality{Version: "8.11.1"}
+	OpenSearch = Personality{Version: "2.11.0", Distribution: "opensearch"}
+)
+
+// Document is a document stored in fake index
+type Document struct {
+	ID     string
+	Type   string
+	Source map[string]interface{}
+}
+
+// Index is an index of the fake cluster
+type Index struct {
+	Name       string
+	Aliases    map[string]map[string]interface{}
+	Properties map[string]interface{}
+	Settings   map[string]interface{}
+	Closed     bool
+	Documents  []*Document
+}
+
+// Server is a fake Elasticsearch cluster consisting of a single node
+type Server struct {
+	*httptest.Server
+	Personality Personality
+	ClusterName string
+
+	mutex sync.Mutex
+	// esMajor is a major version of Elasticsearch which APIs the server follows. OpenSearch 1.x is compatible with
+	// Elasticsearch 7.10, OpenSearch 2.x removed document types as Elasticsearch 8 did
+	esMajor      int
+	indices      map[string]*Index
+	repositories map[string]map[string]interface{}
+	snapshots    map[string][]map[string]interface{}
+	scrolls      map[string]*scroll
+	pits         map[string]string
+	nextID       int
+	requests     []string
+}
+
+type scroll struct {
+	hits     []map[string]interface{}
+	position int
+	size     int
+}
+
+// New starts fake server with given personality. Server must be closed with Close
+func New(personality Personality) *Server {
+	major, _ := strconv.Atoi(strings.SplitN(personality.Version, ".", 2)[0])
+	if personality.Distribution != "" {
+		major += 6
+	}
+	s := &Server{
+		Personality:  personality,
+		ClusterName:  "fake-cluster",
+		esMajor:      major,
+		indices:      make(map[string]*Index),
+		repositories: make(map[string]map[string]interface{}),
+		snapshots:    make(map[string][]map[string]interface{}),
+		scrolls:      make(map[string]*scroll),
+		pits:         make(map[string]string),
+	}
+	s.Server = httptest.NewServer(s)
+	return s
+}
+
+// Typeless is true if emulated version does not use document types
+func (s *Server) Typeless() bool {
+	return s.esMajor >= 7
+}
+
+// AddIndex creates index with given mapping properties
+func (s *Server) AddIndex(name string, properties map[string]interface{}) *Index {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.createIndex(name, properties)
+}
+
+// AddAlias adds alias to an index
+func (s *Server) AddAlias(index string, alias string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if idx, ok := s.indices[index]; ok {
+		idx.Aliases[alias] = map[string]interface{}{}
+	}
+}
+
+// AddDocument stores document in the index, creating index if necessary. Empty id generates a new one
+func (s *Server) AddDocument(index string, docType string, id string, source map[string]interface{}) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	idx, ok := s.indices[index]
+	if !ok {
+		idx = s.createIndex(index, nil)
+	}
+	id, _ = s.storeDocument(idx, docType, id, source)
+	return id
+}
+
+// Documents returns documents stored in the index
+func (s *Server) Documents(index string) []*Document {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if idx, ok := s.indices[index]; ok {
+		return append([]*Document{}, idx.Documents...)
+	}
+	return nil
+}
+
+// Requests returns list of requests received by the server, each in form "METHOD /path"
+func (s *Server) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *Server) createIndex(name string, properties map[string]interface{}) *Index {
+	if properties == nil {
+		properties = make(map[string]interface{})
+	}
+	idx := &Index{
+		Name:       name,
+		Aliases:    make(map[string]map[string]interface{}),
+		Properties: properties,
+		Settings: map[string]interface{}{
+			"number_of_shards":   "1",
+			"number_of_replicas": "0",
+			"creation_date":      strconv.FormatInt(time.Now().UnixNano()/int64(time.Millisecond), 10),
+			"uuid":               fmt.Sprintf("fake-uuid-%s", name),
+		},
+	}
+	s.indices[name] = idx
+	return idx
+}
+
+// storeDocument creates or replaces document. Returns document id and true if document was created
+func (s *Server) storeDocument(idx *Index, docType string, id string, source map[string]interface{}) (string, bool) {
+	if s.Typeless() || docType == "" {
+		docType = "_doc"
+	}
+	if id == "" {
+		s.nextID++
+		id = fmt.Sprintf("fake-%d", s.nextID)
+	}
+	if doc := idx.find(id); doc != nil {
+		doc.Source = source
+		return id, false
+	}
+	idx.Documents = append(idx.Documents, &Document{ID: id, Type: docType, Source: source})
+	return id, true
+}
+
+// size returns total size of document sources in bytes
+func (idx *Index) size() int {
+	size := 0
+	for _, doc := range idx.Documents {
+		data, _ := json.Marshal(doc.Source)
+		size += len(data)
+	}
+	return size
+}
+
+func (idx *Index) aliasNames() []string {
+	names := []string{}
+	for alias := range idx.Aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (idx *Index) find(id string) *Document {
+	for _, doc := range idx.Documents {
+		if doc.ID == id {
+			return doc
+		}
+	}
+	return nil
+}
+
+func (idx *Index) remove(id string) bool {
+	for i, doc := range idx.Documents {
+		if doc.ID == id {
+			idx.Documents = append(idx.Documents[:i], idx.Documents[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// resolve finds index by its name or alias
+func (s *Server) resolve(name string) *Index {
+	if idx, ok := s.indices[name]; ok {
+		return idx
+	}
+	for _, idx := range s.indices {
+		if _, ok := idx.Aliases[name]; ok {
+			return idx
+		}
+	}
+	return nil
+}
+
+// selectIndices returns indices matching comma-separated list of names, aliases or "_all"
+func (s *Server) selectIndices(names string) []*Index {
+	var result []*Index
+	if names == "" || names == "_all" || names == "*" {
+		for _, name := range s.indexNames() {
+			result = append(result, s.indices[name])
+		}
+		return result
+	}
+	for _, name := range strings.Split(names, ",") {
+		if idx := s.resolve(name); idx != nil {
+			result = append(result, idx)
+		}
+	}
+	return result
+}
+
+func (s *Server) indexNames() []string {
+	names := make([]string, 0, len(s.indices))
+	for name := range s.indices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ServeHTTP dispatches requests to API handlers
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		s.writeError(w, http.StatusBadRequest, "parse_exception", err.Error(), "")
+		return
+	}
+	req := &request{Request: r, body: body, path: splitPath(r.URL.Path)}
+
+	if len(req.path) == 0 {
+		s.root(w, req)
+		return
+	}
+	switch req.path[0] {
+	case "_cluster":
+		s.cluster(w, req)
+	case "_cat":
+		s.cat(w, req)
+	case "_nodes":
+		s.nodes(w, req)
+	case "_stats":
+		s.stats(w, req)
+	case "_segments":
+		s.segments(w, s.selectIndices(""))
+	case "_alias", "_aliases":
+		s.aliases(w, req, "")
+	case "_search":
+		s.search(w, req, "")
+	case "_pit":
+		s.closePit(w, req)
+	case "_bulk":
+		s.bulk(w, req)
+	case "_snapshot":
+		s.snapshot(w, req)
+	case "_mapping":
+		s.mapping(w, req, "")
+	case "_settings":
+		s.settings(w, req, "")
+	case "_refresh", "_flush", "_cache", "_forcemerge", "_optimize":
+		s.acknowledge(w)
+	case "_plugins":
+		s.ism(w, req)
+	default:
+		s.index(w, req)
+	}
+}
+
+type request struct {
+	*http.Request
+	body []byte
+	path []string
+}
+
+func splitPath(path string) []string {
+	var result []string
+	for _, part := range strings.Split(path, "/") {
+		if part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// json decodes request body. Empty body is decoded as empty object
+func (r *request) json() (map[string]interface{}, error) {
+	result := make(map[string]interface{})
+	if len(strings.TrimSpace(string(r.body))) == 0 {
+		return result, nil
+	}
+	err := json.Unmarshal(r.body, &result)
+	return result, err
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// writeError writes error in format of the emulated version. Versions 1.x report errors as strings
+func (s *Server) writeError(w http.ResponseWriter, status int, errorType string, reason string, index string) {
+	if s.esMajor == 1 {
+		writeJSON(w, status, map[string]interface{}{
+			"error":  fmt.Sprintf("%s[%s]", exceptionClass(errorType), reason),
+			"status": status,
+		})
+		return
+	}
+	cause := map[string]interface{}{
+		"type":   errorType,
+		"reason": reason,
+	}
+	if index != "" {
+		cause["index"] = index
+	}
+	errorBody := map[string]interface{}{
+		"root_cause": []interface{}{cause},
+	}
+	for k, v := range cause {
+		errorBody[k] = v
+	}
+	writeJSON(w, status, map[string]interface{}{
+		"error":  errorBody,
+		"status": status,
+	})
+}
+
+// exceptionClass converts error type like "index_not_found_exception" to class name "IndexNotFoundException"
+func exceptionClass(errorType string) string {
+	var result strings.Builder
+	for _, part := range strings.Split(errorType, "_") {
+		if part != "" {
+			result.WriteString(strings.ToUpper(part[:1]) + part[1:])
+		}
+	}
+	return result.String()
+}
+
+func (s *Server) indexNotFound(w http.ResponseWriter, name string) {
+	s.writeError(w, http.StatusNotFound, "index_not_found_exception", fmt.Sprintf("no such index [%s]", name), name)
+}
+
+func (s *Server) badRequest(w http.ResponseWriter, r *request) {
+	s.writeError(w, http.StatusBadRequest, "illegal_argument_exception",
+		fmt.Sprintf("no handler found for uri [%s] and method [%s]", r.URL.Path, r.Method), "")
+}
+
+func (s *Server) acknowledge(w http.ResponseWriter) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"acknowledged": true,
+		"_shards":      map[string]interface{}{"total": 1, "successful": 1, "failed": 0},
+	})
+}
@@ -0,0 +1,34 @@
+// Command fakees runs fake Elasticsearch server from esfake package, so that shelastic can be tried
+// without a real cluster
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"shelastic/test/esfake"
+
+	flags "github.com/jessevdk/go-flags"
+)
+
+func main() {
+	var opts struct {
+		Version      string `long:"version" default:"7.17.9" description:"Emulated Elasticsearch or OpenSearch version"`
+		Distribution string `long:"distribution" choice:"elasticsearch" choice:"opensearch" default:"elasticsearch" description:"Emulated distribution"`
+	}
+	if _, err := flags.Parse(&opts); err != nil {
+		os.Exit(1)
+	}
+	personality := esfake.Personality{Version: opts.Version}
+	if opts.Distribution == "opensearch" {
+		personality.Distribution = opts.Distribution
+	}
+
+	server := esfake.New(personality)
+	defer server.Close()
+	fmt.Printf("Fake %s %s is listening on %s\n", opts.Distribution, opts.Version, server.URL)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt)
+	<-signals
+}